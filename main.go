@@ -3,13 +3,16 @@ package main
 import (
 	"72/perscom_events"
 	"context"
+	"errors"
 	"github.com/disgoorg/disgo"
 	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/cache"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"github.com/disgoorg/disgo/gateway"
 	"github.com/disgoorg/snowflake/v2"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -35,8 +38,13 @@ func main() {
 				gateway.IntentGuilds,
 				gateway.IntentGuildMessages,
 				gateway.IntentDirectMessages,
+				gateway.IntentGuildVoiceStates,
 			),
 		),
+		// Voice attendance looks up op channels and who's in them from the cache
+		bot.WithCacheConfigOpts(
+			cache.WithCaches(cache.FlagChannels, cache.FlagVoiceStates),
+		),
 	)
 
 	if err != nil {
@@ -70,7 +78,19 @@ func main() {
 			client.AddEventListeners(buttonEventHandler.EventListeners...)
 		}
 
+		client.AddEventListeners(perscom_events.GetEventListeners()...)
+
+		commands := make([]discord.ApplicationCommandCreate, 0)
+		for _, slashCommandHandler := range perscom_events.GetSlashCommandHandlers() {
+			commands = append(commands, slashCommandHandler.Command)
+			client.AddEventListeners(slashCommandHandler.EventListeners...)
+		}
+
 		client.AddEventListeners(bot.NewListenerFunc(func(event *events.GuildReady) {
+			if _, err := client.Rest().SetGuildCommands(client.ApplicationID(), event.GuildID, commands); err != nil {
+				slog.Error("error while registering commands", slog.Any("err", err))
+			}
+
 			channels, err := client.Rest().GetGuildChannels(event.GuildID)
 			if err != nil {
 				slog.Error("error while getting channels", slog.Any("err", err))
@@ -113,6 +133,14 @@ func main() {
 		return
 	}
 
+	httpServer := perscom_events.NewHTTPServer()
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("error while serving http", slog.Any("err", err))
+		}
+	}()
+	defer httpServer.Close()
+
 	slog.Info("example is now running. Press CTRL-C to exit.")
 	s := make(chan os.Signal, 1)
 	signal.Notify(s, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
@@ -0,0 +1,193 @@
+package perscom_events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// A2S is the Source engine query protocol ArmA 3 servers answer on their
+// query port (the game port + 1). Only A2S_PLAYER is implemented.
+// https://developer.valvesoftware.com/wiki/Server_queries
+
+const a2sTimeout = 5 * time.Second
+const a2sMaxPacketSize = 1400
+
+const (
+	a2sSinglePacket   = -1
+	a2sSplitPacket    = -2
+	a2sPlayerRequest  = 0x55
+	a2sPlayerResponse = 0x44
+	a2sChallenge      = 0x41
+)
+
+var errA2SMalformed = errors.New("malformed A2S response")
+
+type a2sPlayer struct {
+	Name     string
+	Score    int32
+	Duration time.Duration
+}
+
+// queryA2SPlayers asks the server at address for its player list, answering
+// the challenge the server replies with first.
+func queryA2SPlayers(address string) ([]a2sPlayer, error) {
+	conn, err := net.DialTimeout("udp", address, a2sTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(a2sTimeout)); err != nil {
+		return nil, err
+	}
+
+	challenge := []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	for attempt := 0; attempt < 3; attempt++ {
+		request := append([]byte{0xFF, 0xFF, 0xFF, 0xFF, a2sPlayerRequest}, challenge...)
+		if _, err = conn.Write(request); err != nil {
+			return nil, err
+		}
+
+		response, err := readA2SResponse(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		switch response[0] {
+		case a2sChallenge:
+			if len(response) < 5 {
+				return nil, errA2SMalformed
+			}
+			challenge = response[1:5]
+		case a2sPlayerResponse:
+			return parseA2SPlayers(response[1:])
+		default:
+			return nil, fmt.Errorf("unexpected A2S response type 0x%02x", response[0])
+		}
+	}
+
+	return nil, errors.New("A2S server kept sending challenges")
+}
+
+// readA2SResponse reads one response, reassembling it if the server split it
+// over several packets, and returns it without the packet header.
+func readA2SResponse(conn net.Conn) ([]byte, error) {
+	var parts [][]byte
+	var received, total int
+	var id int32
+
+	for {
+		packet := make([]byte, a2sMaxPacketSize)
+		n, err := conn.Read(packet)
+		if err != nil {
+			return nil, err
+		}
+		packet = packet[:n]
+
+		if len(packet) < 5 {
+			return nil, errA2SMalformed
+		}
+
+		switch int32(binary.LittleEndian.Uint32(packet)) {
+		case a2sSinglePacket:
+			return packet[4:], nil
+		case a2sSplitPacket:
+			// ID, total, number and size follow the header on Source servers
+			if len(packet) < 12 {
+				return nil, errA2SMalformed
+			}
+
+			packetID := int32(binary.LittleEndian.Uint32(packet[4:]))
+			if uint32(packetID)&0x80000000 != 0 {
+				return nil, errors.New("compressed A2S responses aren't supported")
+			}
+
+			if parts == nil {
+				id, total = packetID, int(packet[8])
+				parts = make([][]byte, total)
+			}
+
+			number := int(packet[9])
+			if packetID != id || number >= total || int(packet[8]) != total {
+				return nil, errA2SMalformed
+			}
+
+			if parts[number] == nil {
+				parts[number] = packet[12:]
+				received++
+			}
+
+			if received == total {
+				payload := bytes.Join(parts, nil)
+				if len(payload) < 5 || int32(binary.LittleEndian.Uint32(payload)) != a2sSinglePacket {
+					return nil, errA2SMalformed
+				}
+				return payload[4:], nil
+			}
+		default:
+			return nil, errA2SMalformed
+		}
+	}
+}
+
+func parseA2SPlayers(data []byte) ([]a2sPlayer, error) {
+	if len(data) < 1 {
+		return nil, errA2SMalformed
+	}
+
+	count := int(data[0])
+	reader := bytes.NewReader(data[1:])
+	players := make([]a2sPlayer, 0, count)
+	for i := 0; i < count && reader.Len() > 0; i++ {
+		// Index, which is meaningless on most servers
+		if _, err := reader.ReadByte(); err != nil {
+			return nil, errA2SMalformed
+		}
+
+		name, err := readA2SString(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		var score int32
+		var duration float32
+		if err = binary.Read(reader, binary.LittleEndian, &score); err != nil {
+			return nil, errA2SMalformed
+		}
+		if err = binary.Read(reader, binary.LittleEndian, &duration); err != nil {
+			return nil, errA2SMalformed
+		}
+
+		// Players still connecting show up without a name
+		if name == "" {
+			continue
+		}
+
+		players = append(players, a2sPlayer{
+			Name:     name,
+			Score:    score,
+			Duration: time.Duration(math.Max(float64(duration), 0) * float64(time.Second)),
+		})
+	}
+
+	return players, nil
+}
+
+func readA2SString(reader *bytes.Reader) (string, error) {
+	var value []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", errA2SMalformed
+		}
+		if b == 0 {
+			return string(value), nil
+		}
+		value = append(value, b)
+	}
+}
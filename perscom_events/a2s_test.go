@@ -0,0 +1,184 @@
+package perscom_events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// a2sTestServer stands in for a game server's query port, answering every
+// request with the packets respond returns for it.
+func a2sTestServer(t *testing.T, respond func(request []byte) [][]byte) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buffer := make([]byte, a2sMaxPacketSize)
+		for {
+			n, address, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			for _, packet := range respond(append([]byte(nil), buffer[:n]...)) {
+				if _, err = conn.WriteTo(packet, address); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func a2sTestSinglePacket(payload []byte) []byte {
+	return append([]byte{0xFF, 0xFF, 0xFF, 0xFF}, payload...)
+}
+
+// a2sTestSplitPackets splits the single packet response into parts of at
+// most size bytes, the way Source servers send large responses.
+func a2sTestSplitPackets(payload []byte, size int, id uint32) [][]byte {
+	whole := a2sTestSinglePacket(payload)
+	total := (len(whole) + size - 1) / size
+
+	var packets [][]byte
+	for number := 0; number < total; number++ {
+		header := binary.LittleEndian.AppendUint32(nil, uint32(0xFFFFFFFE))
+		header = binary.LittleEndian.AppendUint32(header, id)
+		header = append(header, byte(total), byte(number))
+		header = binary.LittleEndian.AppendUint16(header, a2sMaxPacketSize)
+
+		end := min((number+1)*size, len(whole))
+		packets = append(packets, append(header, whole[number*size:end]...))
+	}
+
+	return packets
+}
+
+func a2sTestPlayers(players ...a2sPlayer) []byte {
+	payload := []byte{a2sPlayerResponse, byte(len(players))}
+	for i, player := range players {
+		payload = append(payload, byte(i))
+		payload = append(payload, player.Name...)
+		payload = append(payload, 0)
+		payload = binary.LittleEndian.AppendUint32(payload, uint32(player.Score))
+		payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(float32(player.Duration.Seconds())))
+	}
+
+	return payload
+}
+
+func TestQueryA2SPlayers(t *testing.T) {
+	challenge := []byte{a2sChallenge, 0x12, 0x34, 0x56, 0x78}
+	players := a2sTestPlayers(
+		a2sPlayer{Name: "PVT J. Doe", Score: 3, Duration: 90 * time.Second},
+		a2sPlayer{Name: "", Score: 0, Duration: 0},
+		a2sPlayer{Name: "SGT Å. Ström", Score: -1, Duration: 2 * time.Hour},
+	)
+	want := []a2sPlayer{
+		{Name: "PVT J. Doe", Score: 3, Duration: 90 * time.Second},
+		{Name: "SGT Å. Ström", Score: -1, Duration: 2 * time.Hour},
+	}
+
+	// Answers the challenge first, then the player list once it's echoed back
+	withChallenge := func(response [][]byte) func(request []byte) [][]byte {
+		return func(request []byte) [][]byte {
+			if !bytes.Equal(request[5:], challenge[1:]) {
+				return [][]byte{a2sTestSinglePacket(challenge)}
+			}
+			return response
+		}
+	}
+
+	split := a2sTestSplitPackets(players, 16, 1234)
+	reversed := slices.Clone(split)
+	slices.Reverse(reversed)
+
+	tests := []struct {
+		name    string
+		respond func(request []byte) [][]byte
+		want    []a2sPlayer
+		wantErr error
+	}{
+		{
+			name:    "single packet",
+			respond: withChallenge([][]byte{a2sTestSinglePacket(players)}),
+			want:    want,
+		},
+		{
+			name:    "split packets",
+			respond: withChallenge(split),
+			want:    want,
+		},
+		{
+			name:    "split packets out of order and repeated",
+			respond: withChallenge(append(reversed, split[0])),
+			want:    want,
+		},
+		{
+			name:    "split packets from another response",
+			respond: withChallenge([][]byte{split[0], a2sTestSplitPackets(players, 16, 5678)[1]}),
+			wantErr: errA2SMalformed,
+		},
+		{
+			name:    "no challenge",
+			respond: func([]byte) [][]byte { return [][]byte{a2sTestSinglePacket(players)} },
+			want:    want,
+		},
+		{
+			name:    "no players",
+			respond: withChallenge([][]byte{a2sTestSinglePacket(a2sTestPlayers())}),
+			want:    []a2sPlayer{},
+		},
+		{
+			name:    "truncated player",
+			respond: withChallenge([][]byte{a2sTestSinglePacket(players[:len(players)-3])}),
+			wantErr: errA2SMalformed,
+		},
+		{
+			name:    "unterminated name",
+			respond: withChallenge([][]byte{a2sTestSinglePacket([]byte{a2sPlayerResponse, 1, 0, 'A', 'B'})}),
+			wantErr: errA2SMalformed,
+		},
+		{
+			name:    "short packet",
+			respond: func([]byte) [][]byte { return [][]byte{{0xFF, 0xFF}} },
+			wantErr: errA2SMalformed,
+		},
+		{
+			name:    "unknown header",
+			respond: func([]byte) [][]byte { return [][]byte{{0x01, 0x02, 0x03, 0x04, a2sPlayerResponse, 0}} },
+			wantErr: errA2SMalformed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := queryA2SPlayers(a2sTestServer(t, test.respond))
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package perscom_events
+
+import (
+	"errors"
+	"github.com/disgoorg/snowflake/v2"
+	"strings"
+	"time"
+)
+
+// returnDateLayouts are the ways members are allowed to write the approximate
+// return date of a leave of absence.
+var returnDateLayouts = []string{time.DateOnly, "01/02/2006", "1/2/2006", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "2 Jan 2006"}
+
+var errInvalidReturnDate = errors.New("the return date must be a date like 2006-01-30")
+
+type leaveRecord struct {
+	Start     time.Time `json:"start"`
+	Return    time.Time `json:"return"`
+	Reason    string    `json:"reason,omitempty"`
+	Submitted time.Time `json:"submitted"`
+}
+
+// absences holds the temporary passes, keyed by operation and then member,
+// and the leaves of absence of every member.
+type absences struct {
+	TemporaryPasses map[string]map[snowflake.ID]time.Time `json:"temporary_passes"`
+	Leaves          map[snowflake.ID][]leaveRecord        `json:"leaves"`
+}
+
+var absenceStore = newJSONStore("absences", func() absences {
+	return absences{
+		TemporaryPasses: map[string]map[snowflake.ID]time.Time{},
+		Leaves:          map[snowflake.ID][]leaveRecord{},
+	}
+})
+
+func recordTemporaryPass(memberID snowflake.ID, window opWindow) error {
+	return absenceStore.Update(func(a *absences) error {
+		if a.TemporaryPasses == nil {
+			a.TemporaryPasses = map[string]map[snowflake.ID]time.Time{}
+		}
+		if a.TemporaryPasses[window.ID] == nil {
+			a.TemporaryPasses[window.ID] = map[snowflake.ID]time.Time{}
+		}
+
+		a.TemporaryPasses[window.ID][memberID] = time.Now().UTC()
+		return nil
+	})
+}
+
+func recordLeaveOfAbsence(memberID snowflake.ID, returnDate time.Time, reason string) error {
+	return absenceStore.Update(func(a *absences) error {
+		if a.Leaves == nil {
+			a.Leaves = map[snowflake.ID][]leaveRecord{}
+		}
+
+		now := time.Now().UTC()
+		a.Leaves[memberID] = append(a.Leaves[memberID], leaveRecord{
+			Start:     now,
+			Return:    returnDate,
+			Reason:    reason,
+			Submitted: now,
+		})
+		return nil
+	})
+}
+
+// parseReturnDate reads the approximate return date from a leave of absence
+// request. The member is on leave until the end of that day.
+func parseReturnDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range returnDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.Add(24*time.Hour - time.Second), nil
+		}
+	}
+
+	return time.Time{}, errInvalidReturnDate
+}
+
+// getExcusedMembers returns everyone who submitted a temporary pass for the
+// operation or whose leave of absence covers its start.
+func getExcusedMembers(window opWindow) (map[snowflake.ID]bool, error) {
+	excused := map[snowflake.ID]bool{}
+	err := absenceStore.View(func(a *absences) {
+		for memberID := range a.TemporaryPasses[window.ID] {
+			excused[memberID] = true
+		}
+
+		for memberID, leaves := range a.Leaves {
+			for _, leave := range leaves {
+				if !window.Start.Before(leave.Start) && !window.Start.After(leave.Return) {
+					excused[memberID] = true
+				}
+			}
+		}
+	})
+
+	return excused, err
+}
+
+// getLatestLeaves returns the most recently submitted leave of absence of
+// every member who ever took one.
+func getLatestLeaves() (map[snowflake.ID]leaveRecord, error) {
+	latest := map[snowflake.ID]leaveRecord{}
+	err := absenceStore.View(func(a *absences) {
+		for memberID, leaves := range a.Leaves {
+			for _, leave := range leaves {
+				if leave.Submitted.After(latest[memberID].Submitted) {
+					latest[memberID] = leave
+				}
+			}
+		}
+	})
+
+	return latest, err
+}
+
+// countTemporaryPasses counts the temporary passes each member submitted for
+// the operations.
+func countTemporaryPasses(opIDs []string) (map[snowflake.ID]int, error) {
+	counts := map[snowflake.ID]int{}
+	err := absenceStore.View(func(a *absences) {
+		for _, opID := range opIDs {
+			for memberID := range a.TemporaryPasses[opID] {
+				counts[memberID]++
+			}
+		}
+	})
+
+	return counts, err
+}
@@ -0,0 +1,421 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+const accountabilityDigestCommandName = "accountability-digest"
+const accountabilityDMCustomID = "accountability-dm"
+const accountabilityExcuseCustomID = "accountability-excuse"
+const accountabilityDischargeCustomID = "accountability-discharge"
+const accountabilityDischargeConfirmCustomID = "accountability-discharge-confirm"
+const accountabilityDigestPageSize = 5
+
+// The digest goes out once per op, after the op's attendance was finalized or
+// a day after it ended if attendance isn't being tracked.
+const accountabilityDigestDelay = 24 * time.Hour
+
+var inactivityWeeks = envInt("inactivity_weeks", 4)
+
+type accountabilityKind string
+
+const (
+	accountabilityAWOL         accountabilityKind = "awol"
+	accountabilityExpiredLeave accountabilityKind = "expired-leave"
+	accountabilityInactive     accountabilityKind = "inactive"
+)
+
+var accountabilityKinds = []accountabilityKind{accountabilityAWOL, accountabilityExpiredLeave, accountabilityInactive}
+
+// accountabilityEntry is one member S1 should follow up on. OpID is set for
+// AWOL entries so the absence can be excused from the digest.
+type accountabilityEntry struct {
+	MemberID snowflake.ID
+	Name     string
+	Kind     accountabilityKind
+	Detail   string
+	OpID     string
+}
+
+// accountabilityState remembers the last op a digest was posted for so the
+// digest isn't posted twice when the bot restarts.
+type accountabilityState struct {
+	LastDigest string `json:"last_digest"`
+}
+
+var accountabilityStore = newJSONStore("accountability", func() accountabilityState {
+	return accountabilityState{}
+})
+
+var accountabilityDigestCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        accountabilityDigestCommandName,
+		Description: "Post the AWOL, expired leave and inactivity digest to S1 now (staff only)",
+	},
+	EventListeners: []bot.EventListener{accountabilityDigestCommandEventListener},
+}
+
+var accountabilityGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client, guildID := event.Client(), event.GuildID
+	startPeriodicTask(fmt.Sprintf("accountability-digest:%v", guildID), time.Hour, func() {
+		if err := postScheduledAccountabilityDigest(client, guildID, time.Now().UTC()); err != nil {
+			slog.Error("error while posting accountability digest", slog.Any("err", err), slog.Any("guild", guildID))
+		}
+	})
+})
+
+var accountabilityDigestCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != accountabilityDigestCommandName {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	content := ""
+	entries, err := postAccountabilityDigest(event.Client(), *event.GuildID(), time.Now().UTC())
+	if err != nil {
+		content = fmt.Sprintf("Couldn't post the accountability digest: %v.", err)
+	} else {
+		content = fmt.Sprintf("Found %d members to follow up on, the digest was posted to #%v.", entries, s1ChannelName)
+	}
+
+	_, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), discord.NewMessageUpdateBuilder().
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+// accountabilityActionEventListener handles the buttons on the digest. Their
+// custom IDs are the action, the member and, for excusing, the operation.
+// Discharging asks for confirmation with a button of its own first.
+var accountabilityActionEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	action, args, _ := strings.Cut(event.Data.CustomID(), ":")
+	switch action {
+	case accountabilityDMCustomID, accountabilityExcuseCustomID, accountabilityDischargeCustomID, accountabilityDischargeConfirmCustomID:
+	default:
+		return
+	}
+
+	rawMemberID, opID, _ := strings.Cut(args, ":")
+	memberID, err := snowflake.Parse(rawMemberID)
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	content := ""
+	if !isStaff(event.Member()) {
+		content = "Only staff can do that."
+	} else {
+		switch action {
+		case accountabilityDMCustomID:
+			if err = sendAccountabilityDM(event.Client(), memberID); err != nil {
+				content = fmt.Sprintf("Couldn't message <@%v>: %v.", memberID, err)
+			} else {
+				content = fmt.Sprintf("Messaged <@%v> asking them to check in.", memberID)
+			}
+		case accountabilityExcuseCustomID:
+			if err = correctAttendance(opID, memberID, attendanceExcused, event.User().ID); err != nil {
+				content = fmt.Sprintf("Couldn't excuse <@%v>: %v.", memberID, err)
+			} else {
+				content = fmt.Sprintf("Marked <@%v> as excused for the operation on %v.", memberID, opID)
+			}
+		case accountabilityDischargeCustomID:
+			err = event.CreateMessage(discord.NewMessageCreateBuilder().
+				SetEphemeral(true).
+				SetContentf("Start a discharge for <@%v>?", memberID).
+				AddActionRow(discord.NewDangerButton("Confirm Discharge", fmt.Sprintf("%v:%v", accountabilityDischargeConfirmCustomID, memberID))).
+				Build(),
+			)
+
+			if err != nil {
+				slog.Error("error while creating message", slog.Any("err", err))
+			}
+			return
+		case accountabilityDischargeConfirmCustomID:
+			reason := fmt.Sprintf("Started by %v from the accountability digest.", event.User().Username)
+			if err = startDischarge(memberID, event.User().ID, reason); err != nil {
+				content = fmt.Sprintf("Couldn't start a discharge for <@%v>: %v.", memberID, err)
+			} else {
+				content = fmt.Sprintf("Started a discharge for <@%v>.", memberID)
+			}
+
+			err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+				ClearContainerComponents().
+				SetContent(content).
+				Build(),
+			)
+
+			if err != nil {
+				slog.Error("error while updating message", slog.Any("err", err))
+			}
+			return
+		}
+	}
+
+	err = event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetEphemeral(true).
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// postScheduledAccountabilityDigest posts the digest for the last op once it's
+// due and hasn't been posted yet.
+func postScheduledAccountabilityDigest(client bot.Client, guildID snowflake.ID, now time.Time) error {
+	window := previousOpWindow(now)
+
+	var lastDigest string
+	if err := accountabilityStore.View(func(state *accountabilityState) {
+		lastDigest = state.LastDigest
+	}); err != nil || lastDigest == window.ID {
+		return err
+	}
+
+	op, _, err := getAttendance(window.ID)
+	if err != nil {
+		return err
+	}
+	if !op.Finalized && now.Before(window.End.Add(accountabilityDigestDelay)) {
+		return nil
+	}
+
+	if _, err = postAccountabilityDigest(client, guildID, now); err != nil {
+		return err
+	}
+
+	return accountabilityStore.Update(func(state *accountabilityState) error {
+		state.LastDigest = window.ID
+		return nil
+	})
+}
+
+// findAccountabilityEntries lists active members who were AWOL from the last
+// op, members whose leave of absence ran out without them showing up to an op
+// since, and members who haven't attended an op in inactivityWeeks.
+func findAccountabilityEntries(now time.Time) ([]accountabilityEntry, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := getLatestLeaves()
+	if err != nil {
+		return nil, err
+	}
+
+	var ops map[string]opAttendance
+	if err = attendanceStore.View(func(all *map[string]opAttendance) {
+		ops = make(map[string]opAttendance, len(*all))
+		for opID, op := range *all {
+			if op.Finalized {
+				ops[opID] = op
+			}
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	opIDs := make([]string, 0, len(ops))
+	for opID := range ops {
+		opIDs = append(opIDs, opID)
+	}
+	// Op IDs are dates, so they sort oldest first
+	sort.Strings(opIDs)
+
+	inactiveSince := now.Add(-time.Duration(inactivityWeeks) * 7 * day)
+	trackedLongEnough := len(opIDs) > 0 && ops[opIDs[0]].Start.Before(inactiveSince)
+	lastOp := previousOpWindow(now)
+
+	var entries []accountabilityEntry
+	for _, member := range members {
+		if member.Status != rosterStatusActive && member.Status != rosterStatusLOA {
+			continue
+		}
+
+		lastAttended, awolStreak := time.Time{}, 0
+		for i := len(opIDs) - 1; i >= 0; i-- {
+			status := ops[opIDs[i]].Records[member.DiscordID].Status
+			if status == attendancePresent || status == attendancePartial {
+				lastAttended = ops[opIDs[i]].Start
+				break
+			}
+			if status == attendanceAWOL && awolStreak == len(opIDs)-1-i {
+				awolStreak++
+			}
+		}
+
+		entry := accountabilityEntry{MemberID: member.DiscordID, Name: member.Name}
+		leave, onLeave := leaves[member.DiscordID]
+		switch {
+		case ops[lastOp.ID].Records[member.DiscordID].Status == attendanceAWOL:
+			entry.Kind, entry.OpID = accountabilityAWOL, lastOp.ID
+			entry.Detail = fmt.Sprintf("AWOL from the operation on %v", lastOp.ID)
+			if awolStreak > 1 {
+				entry.Detail += fmt.Sprintf(", %d operations in a row", awolStreak)
+			}
+		case onLeave && leave.Return.Before(now) && lastAttended.Before(leave.Return):
+			entry.Kind = accountabilityExpiredLeave
+			entry.Detail = fmt.Sprintf("leave of absence ended <t:%d:R> without checking in", leave.Return.Unix())
+		case trackedLongEnough && member.JoinDate.Before(inactiveSince) && lastAttended.Before(inactiveSince) && !(onLeave && leave.Return.After(now)):
+			entry.Kind = accountabilityInactive
+			if lastAttended.IsZero() {
+				entry.Detail = "hasn't attended a recorded operation"
+			} else {
+				entry.Detail = fmt.Sprintf("last attended an operation <t:%d:R>", lastAttended.Unix())
+			}
+		default:
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// postAccountabilityDigest posts every entry to S1 with buttons to follow up on
+// it, returning how many members were listed.
+func postAccountabilityDigest(client bot.Client, guildID snowflake.ID, now time.Time) (int, error) {
+	entries, err := findAccountabilityEntries(now)
+	if err != nil {
+		return 0, err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return 0, err
+	}
+
+	counts := map[accountabilityKind]int{}
+	for _, entry := range entries {
+		counts[entry.Kind]++
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0xe8b923).
+			SetTitlef("Accountability Digest - %v", now.Format(time.DateOnly)).
+			SetDescriptionf("%d AWOL, %d expired leaves of absence and %d inactive for %d weeks or more.",
+				counts[accountabilityAWOL], counts[accountabilityExpiredLeave], counts[accountabilityInactive], inactivityWeeks).
+			Build(),
+		).
+		Build(),
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, kind := range accountabilityKinds {
+		var ofKind []accountabilityEntry
+		for _, entry := range entries {
+			if entry.Kind == kind {
+				ofKind = append(ofKind, entry)
+			}
+		}
+
+		for i := 0; i < len(ofKind); i += accountabilityDigestPageSize {
+			page := ofKind[i:min(i+accountabilityDigestPageSize, len(ofKind))]
+			if _, err = client.Rest().CreateMessage(channelID, accountabilityDigestPage(kind, page, i, len(ofKind))); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(entries), nil
+}
+
+// accountabilityDigestPage lists a page of entries with a row of buttons for
+// each member.
+func accountabilityDigestPage(kind accountabilityKind, page []accountabilityEntry, offset int, total int) discord.MessageCreate {
+	var description strings.Builder
+	message := discord.NewMessageCreateBuilder()
+	for _, entry := range page {
+		fmt.Fprintf(&description, "**<@%v>** %v\n", entry.MemberID, entry.Detail)
+
+		name := entry.Name
+		if name == "" {
+			name = entry.MemberID.String()
+		}
+
+		buttons := []discord.InteractiveComponent{
+			discord.NewPrimaryButton(buttonLabel("DM "+name), fmt.Sprintf("%v:%v", accountabilityDMCustomID, entry.MemberID)),
+		}
+		if entry.OpID != "" {
+			buttons = append(buttons, discord.NewSuccessButton(buttonLabel("Excuse "+name), fmt.Sprintf("%v:%v:%v", accountabilityExcuseCustomID, entry.MemberID, entry.OpID)))
+		}
+		buttons = append(buttons, discord.NewDangerButton(buttonLabel("Discharge "+name), fmt.Sprintf("%v:%v", accountabilityDischargeCustomID, entry.MemberID)))
+		message.AddActionRow(buttons...)
+	}
+
+	return message.
+		SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0xe8b923).
+			SetTitlef("%v (%d-%d of %d)", kind, offset+1, offset+len(page), total).
+			SetDescription(description.String()).
+			Build(),
+		).
+		Build()
+}
+
+func (k accountabilityKind) String() string {
+	switch k {
+	case accountabilityAWOL:
+		return "Away Without Leave"
+	case accountabilityExpiredLeave:
+		return "Expired Leave of Absence"
+	case accountabilityInactive:
+		return "Inactive"
+	default:
+		return string(k)
+	}
+}
+
+func buttonLabel(label string) string {
+	return truncateText(label, maxButtonLabelLength)
+}
+
+func sendAccountabilityDM(client bot.Client, memberID snowflake.ID) error {
+	err := sendDirectMessage(client, memberID, "Hey, S1 noticed you've been missing from operations without a temporary pass or leave of absence. "+
+		"Please check in with your direct superior, and submit a TPR or LOA from the perscom channel if you'll be away.")
+	if err != nil {
+		slog.Error("error while sending accountability message", slog.Any("err", err), slog.Any("member", memberID))
+		return errors.New("they probably don't accept direct messages from server members")
+	}
+
+	return nil
+}
@@ -0,0 +1,395 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type attendanceStatus string
+
+const (
+	attendancePresent attendanceStatus = "present"
+	attendancePartial attendanceStatus = "partial"
+	attendanceExcused attendanceStatus = "excused"
+	attendanceAWOL    attendanceStatus = "awol"
+)
+
+var attendanceStatuses = []attendanceStatus{attendancePresent, attendancePartial, attendanceExcused, attendanceAWOL}
+
+var (
+	armaServerQueryAddress   = envString("arma_server_query_address", "")
+	attendancePollInterval   = time.Duration(envInt("attendance_poll_interval_seconds", 120)) * time.Second
+	attendancePresentPercent = envInt("attendance_present_percent", 75)
+)
+
+type attendanceRecord struct {
+	Status attendanceStatus `json:"status"`
+	// CorrectedBy is the staff member who overrode the recorded status
+	CorrectedBy snowflake.ID `json:"corrected_by,omitempty"`
+}
+
+// opAttendance is everything recorded for one operation. Samples counts the
+// times the game server was polled and Seen how many of those each member was
+// in. Voice is the seconds each member spent in the op voice channels, and
+// VoiceTracked whether the bot was watching them during the op. Players who
+// couldn't be matched to the roster are kept by name so staff can correct
+// their records by hand.
+type opAttendance struct {
+	Start        time.Time                         `json:"start"`
+	End          time.Time                         `json:"end"`
+	Samples      int                               `json:"samples"`
+	Seen         map[snowflake.ID]int              `json:"seen"`
+	Voice        map[snowflake.ID]int              `json:"voice"`
+	VoiceTracked bool                              `json:"voice_tracked"`
+	Unmatched    map[string]int                    `json:"unmatched"`
+	Records      map[snowflake.ID]attendanceRecord `json:"records"`
+	Finalized    bool                              `json:"finalized"`
+}
+
+var attendanceStore = newJSONStore("attendance", func() map[string]opAttendance {
+	return map[string]opAttendance{}
+})
+
+var attendanceGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	if armaServerQueryAddress == "" && len(opVoiceChannelNames) == 0 {
+		return
+	}
+
+	client, guildID := event.Client(), event.GuildID
+	startPeriodicTask("attendance-poller", attendancePollInterval, func() {
+		pollAttendance(client, guildID, time.Now().UTC())
+	})
+})
+
+func (s attendanceStatus) String() string {
+	switch s {
+	case attendancePresent:
+		return "Present"
+	case attendancePartial:
+		return "Partial"
+	case attendanceExcused:
+		return "Excused"
+	case attendanceAWOL:
+		return "AWOL"
+	default:
+		return string(s)
+	}
+}
+
+// pollAttendance samples the game server and credits voice time while an
+// operation is running and finalizes the previous operation once it's over.
+func pollAttendance(client bot.Client, guildID snowflake.ID, now time.Time) {
+	if window := nextOpWindow(now); window.contains(now) {
+		if len(opVoiceChannelNames) > 0 {
+			err := flushVoiceSessions(now)
+			if err == nil {
+				err = markVoiceTracked(window)
+			}
+			if err != nil {
+				slog.Error("error while recording voice attendance", slog.Any("err", err))
+			}
+		}
+
+		if armaServerQueryAddress == "" {
+			return
+		}
+
+		players, err := queryA2SPlayers(armaServerQueryAddress)
+		if err != nil {
+			slog.Error("error while querying game server", slog.Any("err", err))
+			return
+		}
+
+		if err = recordAttendanceSample(window, players); err != nil {
+			slog.Error("error while recording attendance", slog.Any("err", err))
+		}
+		return
+	}
+
+	window := previousOpWindow(now)
+	if len(opVoiceChannelNames) > 0 && voiceSessionsOverlap(window) {
+		// Credits the last stretch of the op to those still in voice
+		if err := flushVoiceSessions(now); err != nil {
+			slog.Error("error while recording voice attendance", slog.Any("err", err))
+		}
+	}
+
+	finalized, err := finalizeAttendance(window)
+	if err != nil {
+		slog.Error("error while finalizing attendance", slog.Any("err", err))
+		return
+	}
+
+	if finalized {
+		if err = postAttendanceReview(client, guildID, window.ID); err != nil {
+			slog.Error("error while posting attendance review", slog.Any("err", err))
+		}
+	}
+}
+
+func recordAttendanceSample(window opWindow, players []a2sPlayer) error {
+	members, err := listRosterMembers()
+	if err != nil {
+		return err
+	}
+
+	return attendanceStore.Update(func(ops *map[string]opAttendance) error {
+		op := getOpAttendance(*ops, window)
+		op.Samples++
+
+		seen := map[snowflake.ID]bool{}
+		for _, player := range players {
+			memberID, ok := matchPlayerName(player.Name, members)
+			if !ok {
+				op.Unmatched[player.Name]++
+				continue
+			}
+
+			// The same member connected twice only counts once
+			if !seen[memberID] {
+				seen[memberID] = true
+				op.Seen[memberID]++
+			}
+		}
+
+		(*ops)[window.ID] = op
+		return nil
+	})
+}
+
+func getOpAttendance(ops map[string]opAttendance, window opWindow) opAttendance {
+	op, ok := ops[window.ID]
+	if !ok {
+		op = opAttendance{Start: window.Start, End: window.End}
+	}
+	if op.Seen == nil {
+		op.Seen = map[snowflake.ID]int{}
+	}
+	if op.Voice == nil {
+		op.Voice = map[snowflake.ID]int{}
+	}
+	if op.Unmatched == nil {
+		op.Unmatched = map[string]int{}
+	}
+	if op.Records == nil {
+		op.Records = map[snowflake.ID]attendanceRecord{}
+	}
+
+	return op
+}
+
+// finalizeAttendance turns the samples and voice time of an operation into a
+// status for every active member or member on leave, excusing those with a
+// temporary pass or leave of absence. It only runs once per operation, and
+// never for operations the bot didn't sample the game server or watch voice
+// during, and reports whether it ran.
+func finalizeAttendance(window opWindow) (bool, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return false, err
+	}
+
+	excused, err := getExcusedMembers(window)
+	if err != nil {
+		return false, err
+	}
+
+	finalized := false
+	err = attendanceStore.Update(func(ops *map[string]opAttendance) error {
+		op, ok := (*ops)[window.ID]
+		if !ok || op.Finalized || (op.Samples == 0 && !op.VoiceTracked) {
+			return nil
+		}
+
+		op = getOpAttendance(*ops, window)
+		for _, member := range members {
+			if member.Status != rosterStatusActive && member.Status != rosterStatusLOA {
+				continue
+			}
+			if record, ok := op.Records[member.DiscordID]; ok && record.CorrectedBy != 0 {
+				continue
+			}
+
+			status := attendanceStatusFor(op.Seen[member.DiscordID], op.Samples, op.Voice[member.DiscordID])
+			if status == attendanceAWOL && (excused[member.DiscordID] || member.Status == rosterStatusLOA) {
+				status = attendanceExcused
+			}
+
+			op.Records[member.DiscordID] = attendanceRecord{Status: status}
+		}
+
+		op.Finalized = true
+		(*ops)[window.ID] = op
+		finalized = true
+		return nil
+	})
+
+	return finalized, err
+}
+
+// attendanceStatusFor marks a member present when they were on the game server
+// for enough of the samples or in voice for long enough, and partial when they
+// showed up at all. Whether an absence is excused is up to the caller.
+func attendanceStatusFor(seen int, samples int, voiceSeconds int) attendanceStatus {
+	switch {
+	case samples > 0 && seen*100 >= samples*attendancePresentPercent:
+		return attendancePresent
+	case time.Duration(voiceSeconds)*time.Second >= voiceAttendanceMinimum:
+		return attendancePresent
+	case seen > 0 || voiceSeconds > 0:
+		return attendancePartial
+	default:
+		return attendanceAWOL
+	}
+}
+
+// correctAttendance lets staff override the status recorded for a member.
+func correctAttendance(opID string, memberID snowflake.ID, status attendanceStatus, correctedBy snowflake.ID) error {
+	return attendanceStore.Update(func(ops *map[string]opAttendance) error {
+		op, ok := (*ops)[opID]
+		if !ok {
+			return fmt.Errorf("no attendance was recorded for the operation on %v", opID)
+		}
+
+		op = getOpAttendance(*ops, opWindow{ID: opID, Start: op.Start, End: op.End})
+		op.Records[memberID] = attendanceRecord{Status: status, CorrectedBy: correctedBy}
+		(*ops)[opID] = op
+		return nil
+	})
+}
+
+func getAttendance(opID string) (opAttendance, bool, error) {
+	var op opAttendance
+	var ok bool
+	err := attendanceStore.View(func(ops *map[string]opAttendance) {
+		op, ok = (*ops)[opID]
+	})
+
+	return op, ok, err
+}
+
+// matchPlayerName finds the roster member playing under name. In-game names
+// are matched against the platform name, with or without the rank and with or
+// without a unit tag in front, ignoring case, spacing and punctuation.
+// Ambiguous names don't match anyone.
+func matchPlayerName(name string, members []rosterMember) (snowflake.ID, bool) {
+	normalized := normalizePlayerName(name)
+	if normalized == "" {
+		return 0, false
+	}
+
+	var matches []snowflake.ID
+	for _, member := range members {
+		full := normalizePlayerName(member.Name)
+		if full == normalized {
+			return member.DiscordID, true
+		}
+
+		if full != "" && strings.HasSuffix(normalized, full) {
+			matches = append(matches, member.DiscordID)
+		} else if parsed, err := parsePlatformName(member.Name); err == nil {
+			withoutRank := normalizePlayerName(parsed.Initial + parsed.LastName)
+			if strings.HasSuffix(normalized, withoutRank) && len(normalized)-len(withoutRank) <= 4 {
+				matches = append(matches, member.DiscordID)
+			}
+		}
+	}
+
+	if len(matches) != 1 {
+		return 0, false
+	}
+
+	return matches[0], true
+}
+
+func normalizePlayerName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+func attendanceReviewEmbed(opID string, op opAttendance) discord.Embed {
+	byStatus := map[attendanceStatus][]string{}
+	for memberID, record := range op.Records {
+		entry := fmt.Sprintf("<@%v>", memberID)
+		if record.CorrectedBy != 0 {
+			entry += " (corrected)"
+		}
+		byStatus[record.Status] = append(byStatus[record.Status], entry)
+	}
+
+	unmatched := make([]string, 0, len(op.Unmatched))
+	for name := range op.Unmatched {
+		unmatched = append(unmatched, "`"+name+"`")
+	}
+	sort.Strings(unmatched)
+
+	description := fmt.Sprintf("Operation from <t:%d:t> to <t:%d:t>.", op.Start.Unix(), op.End.Unix())
+	if op.Samples > 0 {
+		description += fmt.Sprintf("\nPolled the game server %d times.", op.Samples)
+	}
+	if op.VoiceTracked {
+		description += fmt.Sprintf("\nTracked %d members in the op voice channels.", len(op.Voice))
+	}
+
+	builder := discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Attendance - Operation %v", opID).
+		SetDescription(description)
+
+	for _, status := range attendanceStatuses {
+		entries := byStatus[status]
+		sort.Strings(entries)
+		builder.AddField(fmt.Sprintf("%v (%d)", status, len(entries)), truncateField(orNone(strings.Join(entries, ", "))), false)
+	}
+
+	return builder.
+		AddField("Unmatched Players", truncateField(orNone(strings.Join(unmatched, ", "))), false).
+		Build()
+}
+
+func postAttendanceReview(client bot.Client, guildID snowflake.ID, opID string) error {
+	op, ok, err := getAttendance(opID)
+	if err != nil || !ok {
+		return err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetEmbeds(attendanceReviewEmbed(opID, op)).
+		Build(),
+	)
+
+	return err
+}
+
+// truncateField keeps embed field values within Discord's 1024 character limit
+func truncateField(value string) string {
+	return truncateText(value, 1024)
+}
+
+// truncateText cuts value down to limit characters, ending it with "..." when
+// it had to be cut. Discord counts characters, cutting bytes could split one.
+func truncateText(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,105 @@
+package perscom_events
+
+import (
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"time"
+)
+
+const attendanceCommandName = "attendance"
+
+var attendanceCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        attendanceCommandName,
+		Description: "Review and correct operation attendance (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "review",
+				Description: "Show the attendance recorded for an operation",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "operation", Description: "Operation date as YYYY-MM-DD, defaults to the latest"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "correct",
+				Description: "Override a member's attendance for an operation",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to correct", Required: true},
+					discord.ApplicationCommandOptionString{Name: "status", Description: "Attendance status", Required: true, Choices: attendanceStatusChoices()},
+					discord.ApplicationCommandOptionString{Name: "operation", Description: "Operation date as YYYY-MM-DD, defaults to the latest"},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{attendanceCommandEventListener},
+}
+
+var attendanceCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != attendanceCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	opID, ok := data.OptString("operation")
+	if !ok {
+		opID = latestOpWindow(time.Now().UTC()).ID
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	if !isStaff(event.Member()) {
+		message.SetContent("Only staff can do that.")
+	} else if _, err := time.Parse(time.DateOnly, opID); err != nil {
+		message.SetContent("The operation must be a date as YYYY-MM-DD.")
+	} else {
+		switch *data.SubCommandName {
+		case "review":
+			if op, found, err := getAttendance(opID); err != nil {
+				slog.Error("error while reading attendance", slog.Any("err", err))
+				message.SetContentf("Couldn't read attendance: %v.", err)
+			} else if !found {
+				message.SetContentf("No attendance was recorded for the operation on %v.", opID)
+			} else {
+				message.SetEmbeds(attendanceReviewEmbed(opID, op))
+			}
+		case "correct":
+			user := data.User("member")
+			status := attendanceStatus(data.String("status"))
+			if err := correctAttendance(opID, user.ID, status, event.User().ID); err != nil {
+				message.SetContentf("Couldn't correct attendance: %v.", err)
+			} else {
+				message.SetContentf("Marked %v as %v for the operation on %v.", user.Mention(), status, opID)
+			}
+		default:
+			return
+		}
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// latestOpWindow is the operation running now or, if there isn't one, the
+// last one that ran.
+func latestOpWindow(now time.Time) opWindow {
+	if window := nextOpWindow(now); window.contains(now) {
+		return window
+	}
+
+	return previousOpWindow(now)
+}
+
+func attendanceStatusChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(attendanceStatuses))
+	for _, status := range attendanceStatuses {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: status.String(), Value: string(status)})
+	}
+
+	return choices
+}
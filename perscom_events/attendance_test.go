@@ -0,0 +1,24 @@
+package perscom_events
+
+import (
+	"testing"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		value string
+		limit int
+		want  string
+	}{
+		{"Discharge PVT Doe", 80, "Discharge PVT Doe"},
+		{"abcdefgh", 8, "abcdefgh"},
+		{"abcdefghi", 8, "abcde..."},
+		{"Åström Åström", 8, "Åströ..."},
+	}
+
+	for _, test := range tests {
+		if got := truncateText(test.value, test.limit); got != test.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", test.value, test.limit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // The container image has no zoneinfo of its own
+)
+
+const availabilityTimeZoneCustomID = "availability-time-zone"
+const availabilityDaysCustomID = "availability-days"
+const availabilityBlocksCustomID = "availability-blocks"
+
+// Members pick the blocks of the day they're free in, in their own time
+const availabilityBlockHours = 3
+
+const hoursPerWeek = 7 * 24
+
+// availabilityTimeZones are the time zones members can pick from, a select
+// menu takes at most 25 options.
+var availabilityTimeZones = []struct {
+	Label    string
+	Location string
+}{
+	{"Hawaii", "Pacific/Honolulu"},
+	{"US Alaska", "America/Anchorage"},
+	{"US Pacific", "America/Los_Angeles"},
+	{"US Mountain", "America/Denver"},
+	{"US Arizona", "America/Phoenix"},
+	{"US Central", "America/Chicago"},
+	{"US Eastern", "America/New_York"},
+	{"Atlantic", "America/Halifax"},
+	{"Brazil", "America/Sao_Paulo"},
+	{"UTC", "UTC"},
+	{"UK & Ireland", "Europe/London"},
+	{"Central Europe", "Europe/Berlin"},
+	{"Eastern Europe", "Europe/Helsinki"},
+	{"Moscow & Turkey", "Europe/Moscow"},
+	{"Gulf", "Asia/Dubai"},
+	{"India", "Asia/Kolkata"},
+	{"Indochina", "Asia/Bangkok"},
+	{"Singapore & Philippines", "Asia/Singapore"},
+	{"Japan & Korea", "Asia/Tokyo"},
+	{"Australia Western", "Australia/Perth"},
+	{"Australia Central", "Australia/Adelaide"},
+	{"Australia Queensland", "Australia/Brisbane"},
+	{"Australia Eastern", "Australia/Sydney"},
+	{"New Zealand", "Pacific/Auckland"},
+}
+
+// memberAvailability is when a member is usually free each week, every one of
+// Blocks on every one of Days in their TimeZone.
+type memberAvailability struct {
+	TimeZone string         `json:"time_zone"`
+	Days     []time.Weekday `json:"days"`
+	Blocks   []int          `json:"blocks"`
+	Updated  time.Time      `json:"updated"`
+}
+
+var availabilityStore = newJSONStore("availability", func() map[snowflake.ID]memberAvailability {
+	return map[snowflake.ID]memberAvailability{}
+})
+
+var availabilitySelectEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	customID, courseKey, _ := strings.Cut(event.Data.CustomID(), ":")
+	if customID != availabilityTimeZoneCustomID && customID != availabilityDaysCustomID && customID != availabilityBlocksCustomID {
+		return
+	}
+
+	values := event.StringSelectMenuInteractionData().Values
+	availability, err := updateMemberAvailability(event.User().ID, func(a *memberAvailability) {
+		switch customID {
+		case availabilityTimeZoneCustomID:
+			a.TimeZone = values[0]
+		case availabilityDaysCustomID:
+			a.Days = nil
+			for _, value := range values {
+				if day, err := strconv.Atoi(value); err == nil {
+					a.Days = append(a.Days, time.Weekday(day))
+				}
+			}
+		case availabilityBlocksCustomID:
+			a.Blocks = nil
+			for _, value := range values {
+				if block, err := strconv.Atoi(value); err == nil {
+					a.Blocks = append(a.Blocks, block)
+				}
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("error while saving availability", slog.Any("err", err))
+		if err = event.CreateMessage(discord.NewMessageCreateBuilder().SetEphemeral(true).SetContentf("Couldn't save your availability: %v.", err).Build()); err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	c, _ := findCourse(courseKey)
+	err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		SetContent(availabilityContent(availability, c)).
+		SetContainerComponents(availabilityComponents(availability, c)...).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating message", slog.Any("err", err))
+	}
+})
+
+func getMemberAvailability(memberID snowflake.ID) (memberAvailability, error) {
+	var availability memberAvailability
+	err := availabilityStore.View(func(stored *map[snowflake.ID]memberAvailability) {
+		availability = (*stored)[memberID]
+	})
+
+	return availability, err
+}
+
+func updateMemberAvailability(memberID snowflake.ID, fn func(a *memberAvailability)) (memberAvailability, error) {
+	var availability memberAvailability
+	err := availabilityStore.Update(func(stored *map[snowflake.ID]memberAvailability) error {
+		availability = (*stored)[memberID]
+		fn(&availability)
+		slices.Sort(availability.Days)
+		slices.Sort(availability.Blocks)
+		availability.Updated = time.Now().UTC()
+		(*stored)[memberID] = availability
+		return nil
+	})
+
+	return availability, err
+}
+
+// complete reports whether the member picked a time zone, days and blocks.
+func (a memberAvailability) complete() bool {
+	return a.TimeZone != "" && len(a.Days) > 0 && len(a.Blocks) > 0
+}
+
+func (a memberAvailability) location() *time.Location {
+	location, err := time.LoadLocation(a.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// weeklyHours returns the UTC hours of the week, counted from Sunday midnight,
+// the member is free for the whole of. Daylight saving is taken as it is the
+// week of now. Free time is marked in half hours first so time zones on the
+// half hour only count the UTC hours that fall entirely inside their blocks.
+func (a memberAvailability) weeklyHours(now time.Time) [hoursPerWeek]bool {
+	var hours [hoursPerWeek]bool
+	if !a.complete() {
+		return hours
+	}
+
+	var halfHours [2 * hoursPerWeek]bool
+	location := a.location()
+	local := now.In(location)
+	for _, day := range a.Days {
+		for _, block := range a.Blocks {
+			for minute := 0; minute < availabilityBlockHours*60; minute += 30 {
+				start := time.Date(local.Year(), local.Month(), local.Day()-int(local.Weekday())+int(day), block*availabilityBlockHours, minute, 0, 0, location)
+				halfHours[2*hourOfWeek(start)+start.UTC().Minute()/30] = true
+			}
+		}
+	}
+
+	for hour := range hours {
+		hours[hour] = halfHours[2*hour] && halfHours[2*hour+1]
+	}
+
+	return hours
+}
+
+// summary describes the availability in the member's own time, merging blocks
+// that follow each other, like "Mon, Wed 18:00-24:00 (US Eastern)".
+func (a memberAvailability) summary() string {
+	if !a.complete() {
+		return "Not set"
+	}
+
+	days := make([]string, 0, len(a.Days))
+	for _, day := range a.Days {
+		days = append(days, day.String()[:3])
+	}
+
+	var spans []string
+	for i := 0; i < len(a.Blocks); {
+		j := i
+		for j+1 < len(a.Blocks) && a.Blocks[j+1] == a.Blocks[j]+1 {
+			j++
+		}
+		spans = append(spans, blockSpan(a.Blocks[i], a.Blocks[j]))
+		i = j + 1
+	}
+
+	return fmt.Sprintf("%v %v (%v)", strings.Join(days, ", "), strings.Join(spans, ", "), timeZoneLabel(a.TimeZone))
+}
+
+func blockSpan(first int, last int) string {
+	return fmt.Sprintf("%02d:00-%02d:00", first*availabilityBlockHours, (last+1)*availabilityBlockHours)
+}
+
+func timeZoneLabel(name string) string {
+	for _, zone := range availabilityTimeZones {
+		if zone.Location == name {
+			return zone.Label
+		}
+	}
+
+	return name
+}
+
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// availabilityContent explains the availability editor, c being the course
+// being requested, if any.
+func availabilityContent(availability memberAvailability, c course) string {
+	content := "Pick your time zone, the days you can usually make it each week and the times you're free on them, in your own time."
+	if c.Key != "" {
+		content = fmt.Sprintf("Before requesting %v, tell S4 when you can attend. %v", c.Name, content)
+	}
+
+	return content + "\n**Saved:** " + availability.summary()
+}
+
+// availabilityComponents are the selects to edit the availability with, the
+// selections saved as they're made. When a course is being requested a button
+// to submit the request follows once the availability is complete.
+func availabilityComponents(availability memberAvailability, c course) []discord.ContainerComponent {
+	zones := make([]discord.StringSelectMenuOption, 0, len(availabilityTimeZones))
+	for _, zone := range availabilityTimeZones {
+		zones = append(zones, discord.NewStringSelectMenuOption(zone.Label, zone.Location).
+			WithDescription(zone.Location).
+			WithDefault(zone.Location == availability.TimeZone))
+	}
+
+	days := make([]discord.StringSelectMenuOption, 0, 7)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		days = append(days, discord.NewStringSelectMenuOption(day.String(), strconv.Itoa(int(day))).
+			WithDefault(slices.Contains(availability.Days, day)))
+	}
+
+	blocks := make([]discord.StringSelectMenuOption, 0, 24/availabilityBlockHours)
+	for block := 0; block < 24/availabilityBlockHours; block++ {
+		blocks = append(blocks, discord.NewStringSelectMenuOption(blockSpan(block, block), strconv.Itoa(block)).
+			WithDefault(slices.Contains(availability.Blocks, block)))
+	}
+
+	suffix := ":" + c.Key
+	components := []discord.ContainerComponent{
+		discord.NewActionRow(discord.NewStringSelectMenu(availabilityTimeZoneCustomID+suffix, "Your time zone", zones...)),
+		discord.NewActionRow(discord.NewStringSelectMenu(availabilityDaysCustomID+suffix, "Days you can usually make it", days...).
+			WithMaxValues(len(days))),
+		discord.NewActionRow(discord.NewStringSelectMenu(availabilityBlocksCustomID+suffix, "Times you're free, in your time zone", blocks...).
+			WithMaxValues(len(blocks))),
+	}
+
+	if c.Key != "" {
+		components = append(components, discord.NewActionRow(
+			discord.NewPrimaryButton("Request "+buttonLabel(c.Name), courseRequestSubmitCustomID+suffix).
+				WithDisabled(!availability.complete()),
+		))
+	}
+
+	return components
+}
+
+// sessionTime is a weekly time a class could start at and the members who
+// are free for all of it.
+type sessionTime struct {
+	Start     time.Time
+	Available []snowflake.ID
+}
+
+// rankSessionTimes finds the weekly times to start a class of the given length
+// at that most of the attendees are free for, only counting the times the
+// instructor is free when they've set their availability. Times overlapping a
+// better one are left out and ties go to the soonest, each start being its
+// next occurrence after now.
+func rankSessionTimes(instructor memberAvailability, attendees map[snowflake.ID]memberAvailability, length time.Duration, now time.Time, limit int) []sessionTime {
+	hours := max(1, int((length+time.Hour-1)/time.Hour))
+	free := func(week [hoursPerWeek]bool, start int) bool {
+		for hour := start; hour < start+hours; hour++ {
+			if !week[hour%hoursPerWeek] {
+				return false
+			}
+		}
+		return true
+	}
+
+	instructorHours := instructor.weeklyHours(now)
+	if !instructor.complete() {
+		for hour := range instructorHours {
+			instructorHours[hour] = true
+		}
+	}
+
+	attendeeHours := make(map[snowflake.ID][hoursPerWeek]bool, len(attendees))
+	for memberID, availability := range attendees {
+		attendeeHours[memberID] = availability.weeklyHours(now)
+	}
+
+	now = now.UTC()
+	weekStart := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, time.UTC)
+
+	var candidates []sessionTime
+	for hour := 0; hour < hoursPerWeek; hour++ {
+		if !free(instructorHours, hour) {
+			continue
+		}
+
+		candidate := sessionTime{Start: weekStart.Add(time.Duration(hour) * time.Hour)}
+		if candidate.Start.Before(now) {
+			candidate.Start = candidate.Start.AddDate(0, 0, 7)
+		}
+
+		for memberID, week := range attendeeHours {
+			if free(week, hour) {
+				candidate.Available = append(candidate.Available, memberID)
+			}
+		}
+
+		if len(candidate.Available) > 0 {
+			slices.Sort(candidate.Available)
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b sessionTime) int {
+		if len(a.Available) != len(b.Available) {
+			return len(b.Available) - len(a.Available)
+		}
+		return a.Start.Compare(b.Start)
+	})
+
+	var ranked []sessionTime
+	for _, candidate := range candidates {
+		if len(ranked) == limit {
+			break
+		}
+
+		overlaps := slices.ContainsFunc(ranked, func(picked sessionTime) bool {
+			gap := candidate.Start.Sub(picked.Start) % (7 * 24 * time.Hour)
+			if gap < 0 {
+				gap += 7 * 24 * time.Hour
+			}
+			span := time.Duration(hours) * time.Hour
+			return gap < span || 7*24*time.Hour-gap < span
+		})
+		if !overlaps {
+			ranked = append(ranked, candidate)
+		}
+	}
+
+	return ranked
+}
+
+// memberAvailabilities reads the saved availability of the members, leaving
+// out those who haven't set it.
+func memberAvailabilities(memberIDs []snowflake.ID) (map[snowflake.ID]memberAvailability, error) {
+	availabilities := map[snowflake.ID]memberAvailability{}
+	err := availabilityStore.View(func(stored *map[snowflake.ID]memberAvailability) {
+		for _, memberID := range memberIDs {
+			if availability, ok := (*stored)[memberID]; ok && availability.complete() {
+				availabilities[memberID] = availability
+			}
+		}
+	})
+
+	return availabilities, err
+}
+
+// formatSessionTimes lists the ranked times out of how many attendees there
+// are, with the UTC start to pass to /class schedule.
+func formatSessionTimes(times []sessionTime, attendees int) string {
+	lines := make([]string, 0, len(times))
+	for i, t := range times {
+		mentions := make([]string, 0, len(t.Available))
+		for _, memberID := range t.Available {
+			mentions = append(mentions, fmt.Sprintf("<@%v>", memberID))
+		}
+
+		lines = append(lines, fmt.Sprintf("**%d.** <t:%d:F> `%v` - %d/%d free: %v", i+1, t.Start.Unix(), t.Start.Format(classTimeLayout), len(t.Available), attendees, strings.Join(mentions, " ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
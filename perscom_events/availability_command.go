@@ -0,0 +1,40 @@
+package perscom_events
+
+import (
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+)
+
+const availabilityCommandName = "availability"
+
+var availabilityCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        availabilityCommandName,
+		Description: "Set when you're usually free each week for classes",
+	},
+	EventListeners: []bot.EventListener{availabilityCommandEventListener},
+}
+
+var availabilityCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != availabilityCommandName {
+		return
+	}
+
+	availability, err := getMemberAvailability(event.User().ID)
+	if err != nil {
+		slog.Error("error while reading availability", slog.Any("err", err))
+	}
+
+	err = event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetEphemeral(true).
+		SetContent(availabilityContent(availability, course{})).
+		SetContainerComponents(availabilityComponents(availability, course{})...).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
@@ -0,0 +1,659 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const awardVoteCustomID = "award-vote"
+const awardDowngradeCustomID = "award-downgrade"
+
+var (
+	awardBoardChannelName = envString("award_board_channel", "award-board")
+	awardBoardRoleName    = envString("award_board_role", "Awards Board")
+	awardBoardQuorum      = envInt("award_board_quorum", 3)
+	awardBoardDeadline    = time.Duration(envInt("award_board_deadline_hours", 72)) * time.Hour
+)
+
+type awardVoteKind string
+
+const (
+	awardVoteApprove   awardVoteKind = "approve"
+	awardVoteReject    awardVoteKind = "reject"
+	awardVoteDowngrade awardVoteKind = "downgrade"
+)
+
+// awardVote is one board member's vote. Award is the lower award they'd give
+// instead when downgrading.
+type awardVote struct {
+	Kind  awardVoteKind `json:"kind"`
+	Award string        `json:"award,omitempty"`
+	Cast  time.Time     `json:"cast"`
+}
+
+type awardRecommendationStatus string
+
+const (
+	awardRecommendationPending  awardRecommendationStatus = "pending"
+	awardRecommendationApproved awardRecommendationStatus = "approved"
+	awardRecommendationRejected awardRecommendationStatus = "rejected"
+	awardRecommendationFailed   awardRecommendationStatus = "failed"
+)
+
+var errAwardRecommendationDecided = errors.New("the board has already decided on that recommendation")
+
+// awardRecommendationRecord is a recommendation in front of the awards board.
+// RecommenderID is zero for recommendations the bot made itself. Awarded is
+// the award the board settled on, which is lower than Award when downgraded.
+// Failure is why an approved award couldn't be recorded.
+type awardRecommendationRecord struct {
+	ID            int                        `json:"id"`
+	GuildID       snowflake.ID               `json:"guild_id"`
+	Award         string                     `json:"award"`
+	RecipientID   snowflake.ID               `json:"recipient_id"`
+	RecommenderID snowflake.ID               `json:"recommender_id,omitempty"`
+	Operation     string                     `json:"operation,omitempty"`
+	Citation      string                     `json:"citation"`
+	Submitted     time.Time                  `json:"submitted"`
+	Deadline      time.Time                  `json:"deadline"`
+	ChannelID     snowflake.ID               `json:"channel_id,omitempty"`
+	MessageID     snowflake.ID               `json:"message_id,omitempty"`
+	Votes         map[snowflake.ID]awardVote `json:"votes"`
+	Status        awardRecommendationStatus  `json:"status"`
+	Decided       time.Time                  `json:"decided,omitempty"`
+	Awarded       string                     `json:"awarded,omitempty"`
+	Failure       string                     `json:"failure,omitempty"`
+}
+
+type awardRecommendationData struct {
+	NextID          int                         `json:"next_id"`
+	Recommendations []awardRecommendationRecord `json:"recommendations"`
+}
+
+var awardRecommendations = newJSONStore("award_recommendations", func() awardRecommendationData {
+	return awardRecommendationData{NextID: 1}
+})
+
+var awardBoardGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client := event.Client()
+	startPeriodicTask("award-board-deadlines", time.Hour, func() {
+		closeExpiredAwardRecommendations(client, time.Now().UTC())
+	})
+})
+
+var awardVoteEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	action, args, _ := strings.Cut(event.Data.CustomID(), ":")
+	if action != awardVoteCustomID && action != awardDowngradeCustomID {
+		return
+	}
+
+	if event.GuildID() == nil {
+		return
+	}
+
+	rawID, kind, _ := strings.Cut(args, ":")
+	recommendationID, err := strconv.Atoi(rawID)
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	reply := func(content string) {
+		if err := event.CreateMessage(discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()); err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+	}
+
+	allowed, err := canVoteOnAwards(event.Client(), *event.GuildID(), event.Member())
+	if err != nil {
+		slog.Error("error while checking awards board membership", slog.Any("err", err))
+		reply(fmt.Sprintf("Couldn't check whether you're on the awards board: %v.", err))
+		return
+	}
+	if !allowed {
+		reply("Only the awards board can vote on award recommendations.")
+		return
+	}
+
+	vote := awardVote{Kind: awardVoteKind(kind), Cast: time.Now().UTC()}
+	if action == awardVoteCustomID && vote.Kind == awardVoteDowngrade {
+		// Ask which award they'd give instead before counting the vote
+		recommendation, err := findAwardRecommendation(recommendationID)
+		if err != nil {
+			reply(fmt.Sprintf("Couldn't vote: %v.", err))
+			return
+		}
+
+		options := awardDowngradeOptions(recommendation.Award)
+		if len(options) == 0 {
+			reply("There's no lower award to downgrade to.")
+			return
+		}
+
+		err = event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Which award should they get instead?").
+			AddActionRow(discord.NewStringSelectMenu(fmt.Sprintf("%v:%d", awardDowngradeCustomID, recommendationID), "Select an award...", options...)).
+			Build(),
+		)
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if action == awardDowngradeCustomID {
+		vote = awardVote{Kind: awardVoteDowngrade, Award: event.StringSelectMenuInteractionData().Values[0], Cast: vote.Cast}
+	}
+
+	recommendation, decided, err := voteOnAwardRecommendation(recommendationID, event.User().ID, vote)
+	if err != nil {
+		reply(fmt.Sprintf("Couldn't vote: %v.", err))
+		return
+	}
+
+	// Finishing a decided recommendation can take longer than Discord waits
+	// for a response
+	if err = event.DeferUpdateMessage(); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	var warnings []string
+	if decided {
+		if recommendation, warnings, err = finishAwardRecommendation(event.Client(), recommendation); err != nil {
+			slog.Error("error while recording award", slog.Any("err", err), slog.Int("recommendation", recommendation.ID))
+		}
+	}
+
+	if _, err = event.Client().Rest().UpdateMessage(recommendation.ChannelID, recommendation.MessageID, awardBoardMessageUpdate(recommendation, warnings)); err != nil {
+		slog.Error("error while updating award board message", slog.Any("err", err))
+	}
+	if action == awardVoteCustomID {
+		return
+	}
+
+	// The downgrade select is on its own ephemeral message
+	a, _ := findAward(vote.Award)
+	_, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), discord.NewMessageUpdateBuilder().
+		ClearContainerComponents().
+		SetContentf("Voted to downgrade to the %v.", a.Name).
+		Build(),
+	)
+	if err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+// canVoteOnAwards reports whether the member holds the awards board role.
+// Staff vote in its place when the guild doesn't have the role.
+func canVoteOnAwards(client bot.Client, guildID snowflake.ID, member *discord.ResolvedMember) (bool, error) {
+	if member == nil {
+		return false, nil
+	}
+
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return false, err
+	}
+
+	roleID, ok := roleIDs[awardBoardRoleName]
+	if !ok {
+		return isStaff(member), nil
+	}
+
+	return slices.Contains(member.RoleIDs, roleID), nil
+}
+
+// awardDowngradeOptions lists the awards below the recommended one, closest
+// first.
+func awardDowngradeOptions(awardKey string) []discord.StringSelectMenuOption {
+	recommended, ok := findAward(awardKey)
+	if !ok {
+		return nil
+	}
+
+	var options []discord.StringSelectMenuOption
+	for _, a := range awards {
+		if a.Precedence > recommended.Precedence && len(options) < awardsPerPage {
+			options = append(options, discord.NewStringSelectMenuOption(a.Name, a.Key))
+		}
+	}
+
+	return options
+}
+
+// submitAwardRecommendation checks the recommendation and posts it to the
+// awards board for a vote.
+func submitAwardRecommendation(client bot.Client, guildID snowflake.ID, recommendation awardRecommendationRecord) (awardRecommendationRecord, error) {
+	a, ok := findAward(recommendation.Award)
+	if !ok {
+		return recommendation, fmt.Errorf("unknown award %q", recommendation.Award)
+	}
+
+	if recommendation.RecipientID == recommendation.RecommenderID {
+		return recommendation, errors.New("you can't recommend yourself")
+	}
+
+	recipient, err := getRosterMember(recommendation.RecipientID)
+	if errors.Is(err, errRosterMemberNotFound) {
+		return recommendation, errors.New("the recipient isn't on the roster")
+	} else if err != nil {
+		return recommendation, err
+	}
+
+	if !a.MultipleAllowed && recipient.awardCount(a.Name) > 0 {
+		return recommendation, fmt.Errorf("%v already has the %v and it can only be received once", recipient.Name, a.Name)
+	}
+
+	if recommendation.Operation, err = checkOperationReference(recommendation.Operation); err != nil {
+		return recommendation, err
+	}
+
+	if recommendation.RecommenderID != 0 {
+		recommender, err := getRosterMember(recommendation.RecommenderID)
+		if err != nil {
+			return recommendation, err
+		}
+
+		if !a.canRecommend(recommender.Rank) {
+			return recommendation, fmt.Errorf("the %v can only be recommended by a %v or above", a.Name, a.RecommenderRank)
+		}
+	}
+
+	recommendation.GuildID = guildID
+	recommendation.Submitted = time.Now().UTC()
+	recommendation.Deadline = recommendation.Submitted.Add(awardBoardDeadline)
+	recommendation.Votes = map[snowflake.ID]awardVote{}
+	recommendation.Status = awardRecommendationPending
+
+	err = awardRecommendations.Update(func(data *awardRecommendationData) error {
+		recommendation.ID = data.NextID
+		data.NextID++
+		data.Recommendations = append(data.Recommendations, recommendation)
+		return nil
+	})
+	if err != nil {
+		return recommendation, err
+	}
+
+	// Nobody would ever vote on it, let the recommender try again
+	discard := func(err error) (awardRecommendationRecord, error) {
+		removeErr := awardRecommendations.Update(func(data *awardRecommendationData) error {
+			data.Recommendations = slices.DeleteFunc(data.Recommendations, func(existing awardRecommendationRecord) bool {
+				return existing.ID == recommendation.ID
+			})
+			return nil
+		})
+		if removeErr != nil {
+			slog.Error("error while discarding award recommendation", slog.Any("err", removeErr))
+		}
+		return recommendation, err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, awardBoardChannelName)
+	if err != nil {
+		return discard(err)
+	}
+
+	content := "A new award recommendation needs your vote."
+	if roleIDs, err := getGuildRoleIDs(client, guildID); err == nil {
+		if roleID, ok := roleIDs[awardBoardRoleName]; ok {
+			content = fmt.Sprintf("<@&%v>, a new award recommendation needs your vote.", roleID)
+		}
+	}
+
+	message, err := client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetContent(content).
+		SetEmbeds(awardBoardEmbed(recommendation, nil)).
+		SetContainerComponents(awardBoardComponents(recommendation)...).
+		Build(),
+	)
+	if err != nil {
+		return discard(err)
+	}
+
+	recommendation.ChannelID, recommendation.MessageID = channelID, message.ID
+	err = updateAwardRecommendation(recommendation.ID, func(existing *awardRecommendationRecord) error {
+		existing.ChannelID, existing.MessageID = channelID, message.ID
+		return nil
+	})
+
+	return recommendation, err
+}
+
+func findAwardRecommendation(recommendationID int) (awardRecommendationRecord, error) {
+	var recommendation awardRecommendationRecord
+	found := false
+	err := awardRecommendations.View(func(data *awardRecommendationData) {
+		for _, existing := range data.Recommendations {
+			if existing.ID == recommendationID {
+				recommendation, found = existing, true
+			}
+		}
+	})
+
+	if err == nil && !found {
+		err = fmt.Errorf("there's no award recommendation #%d", recommendationID)
+	}
+
+	return recommendation, err
+}
+
+func updateAwardRecommendation(recommendationID int, fn func(recommendation *awardRecommendationRecord) error) error {
+	return awardRecommendations.Update(func(data *awardRecommendationData) error {
+		for i := range data.Recommendations {
+			if data.Recommendations[i].ID == recommendationID {
+				return fn(&data.Recommendations[i])
+			}
+		}
+
+		return fmt.Errorf("there's no award recommendation #%d", recommendationID)
+	})
+}
+
+// voteOnAwardRecommendation records the vote, replacing any earlier vote by
+// the same member, and decides the recommendation once the board agrees.
+// decided is only true for the vote that decided it.
+func voteOnAwardRecommendation(recommendationID int, voterID snowflake.ID, vote awardVote) (awardRecommendationRecord, bool, error) {
+	var recommendation awardRecommendationRecord
+	decided := false
+	err := updateAwardRecommendation(recommendationID, func(existing *awardRecommendationRecord) error {
+		if existing.Status != awardRecommendationPending {
+			return errAwardRecommendationDecided
+		}
+
+		if voterID == existing.RecommenderID || voterID == existing.RecipientID {
+			return errors.New("you can't vote on a recommendation you made or received")
+		}
+
+		switch vote.Kind {
+		case awardVoteApprove, awardVoteReject:
+			vote.Award = ""
+		case awardVoteDowngrade:
+			recommended, _ := findAward(existing.Award)
+			suggested, ok := findAward(vote.Award)
+			if !ok || suggested.Precedence <= recommended.Precedence {
+				return fmt.Errorf("a downgrade has to be to an award below the %v", recommended.Name)
+			}
+		default:
+			return fmt.Errorf("unknown vote %q", vote.Kind)
+		}
+
+		existing.Votes[voterID] = vote
+		decided = existing.decide(time.Now().UTC(), false)
+		recommendation = *existing
+		return nil
+	})
+
+	return recommendation, decided, err
+}
+
+// decide applies the board's decision if it has made one. Once the deadline
+// passed (final) a recommendation without quorum or majority is rejected.
+func (r *awardRecommendationRecord) decide(now time.Time, final bool) bool {
+	status, awarded := tallyAwardVotes(*r, final)
+	if status == awardRecommendationPending {
+		return false
+	}
+
+	r.Status, r.Awarded, r.Decided = status, awarded, now
+	return true
+}
+
+// tallyAwardVotes works out the board's decision once quorum is reached. An
+// approval backs the recommended award and any award below it, a downgrade
+// backs the suggested award and any award below that, so the recommendation
+// is approved as the highest award a majority of the board backs.
+func tallyAwardVotes(recommendation awardRecommendationRecord, final bool) (awardRecommendationStatus, string) {
+	undecided := awardRecommendationPending
+	if final {
+		undecided = awardRecommendationRejected
+	}
+
+	if len(recommendation.Votes) < awardBoardQuorum {
+		return undecided, ""
+	}
+
+	recommended, ok := findAward(recommendation.Award)
+	if !ok {
+		return undecided, ""
+	}
+
+	majority := len(recommendation.Votes)/2 + 1
+	rejections := 0
+	for _, vote := range recommendation.Votes {
+		if vote.Kind == awardVoteReject {
+			rejections++
+		}
+	}
+	if rejections >= majority {
+		return awardRecommendationRejected, ""
+	}
+
+	for _, a := range awards {
+		if a.Precedence < recommended.Precedence {
+			continue
+		}
+
+		backing := 0
+		for _, vote := range recommendation.Votes {
+			switch vote.Kind {
+			case awardVoteApprove:
+				backing++
+			case awardVoteDowngrade:
+				if suggested, ok := findAward(vote.Award); ok && a.Precedence >= suggested.Precedence {
+					backing++
+				}
+			}
+		}
+
+		if backing >= majority {
+			return awardRecommendationApproved, a.Key
+		}
+	}
+
+	return undecided, ""
+}
+
+// closeExpiredAwardRecommendations decides every recommendation whose
+// deadline has passed with the votes it has.
+func closeExpiredAwardRecommendations(client bot.Client, now time.Time) {
+	var closed []awardRecommendationRecord
+	err := awardRecommendations.Update(func(data *awardRecommendationData) error {
+		for i := range data.Recommendations {
+			recommendation := &data.Recommendations[i]
+			if recommendation.Status == awardRecommendationPending && !now.Before(recommendation.Deadline) && recommendation.decide(now, true) {
+				closed = append(closed, *recommendation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error while closing award recommendations", slog.Any("err", err))
+		return
+	}
+
+	for _, recommendation := range closed {
+		recommendation, warnings, err := finishAwardRecommendation(client, recommendation)
+		if err != nil {
+			slog.Error("error while recording award", slog.Any("err", err), slog.Int("recommendation", recommendation.ID))
+		}
+		if recommendation.MessageID == 0 {
+			continue
+		}
+
+		if _, err = client.Rest().UpdateMessage(recommendation.ChannelID, recommendation.MessageID, awardBoardMessageUpdate(recommendation, warnings)); err != nil {
+			slog.Error("error while updating award board message", slog.Any("err", err), slog.Int("recommendation", recommendation.ID))
+		}
+	}
+}
+
+// finishAwardRecommendation records an approved award and lets the
+// recommender and recipient know the outcome. Like promotions, anything that
+// goes wrong after the award is recorded is returned as a warning. When it
+// can't be recorded nobody is told it was awarded, the recommendation is
+// marked failed instead and returned with the error.
+func finishAwardRecommendation(client bot.Client, recommendation awardRecommendationRecord) (awardRecommendationRecord, []string, error) {
+	var warnings []string
+	recommended, _ := findAward(recommendation.Award)
+	recipient := fmt.Sprintf("<@%v>", recommendation.RecipientID)
+
+	var recommenderContent string
+	if recommendation.Status == awardRecommendationApproved {
+		awarded, _ := findAward(recommendation.Awarded)
+		if err := recordAward(recommendation.RecipientID, awarded, recommendation.Citation, recommendation.Operation, recommendation.RecommenderID); err != nil {
+			recommendation.Status, recommendation.Failure = awardRecommendationFailed, err.Error()
+			if updateErr := updateAwardRecommendation(recommendation.ID, func(existing *awardRecommendationRecord) error {
+				existing.Status, existing.Failure = recommendation.Status, recommendation.Failure
+				return nil
+			}); updateErr != nil {
+				slog.Error("error while updating award recommendation", slog.Any("err", updateErr), slog.Int("recommendation", recommendation.ID))
+			}
+			return recommendation, nil, err
+		}
+
+		if err := sendDirectMessage(client, recommendation.RecipientID, fmt.Sprintf("Congratulations, you've been awarded the %v!\n> %v", awarded.Name, recommendation.Citation)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't message the recipient: %v", err))
+		}
+
+		if err := announceAward(client, recommendation.GuildID, recommendation.RecipientID, awarded, recommendation.Citation); err != nil {
+			slog.Error("error while announcing award", slog.Any("err", err), slog.Int("recommendation", recommendation.ID))
+			warnings = append(warnings, fmt.Sprintf("couldn't announce the award: %v", err))
+		}
+
+		recommenderContent = fmt.Sprintf("The awards board approved your recommendation of %v for the %v.", recipient, recommended.Name)
+		if awarded.Key != recommended.Key {
+			recommenderContent = fmt.Sprintf("The awards board approved your recommendation of %v as the %v instead of the %v.", recipient, awarded.Name, recommended.Name)
+		}
+	} else {
+		recommenderContent = fmt.Sprintf("The awards board didn't approve your recommendation of %v for the %v.", recipient, recommended.Name)
+	}
+
+	if recommendation.RecommenderID != 0 {
+		if err := sendDirectMessage(client, recommendation.RecommenderID, recommenderContent); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't message the recommender: %v", err))
+		}
+	}
+
+	return recommendation, warnings, nil
+}
+
+func (r awardRecommendationRecord) statusText() string {
+	switch r.Status {
+	case awardRecommendationApproved:
+		if r.Awarded != r.Award {
+			awarded, _ := findAward(r.Awarded)
+			return fmt.Sprintf("Approved as the %v", awarded.Name)
+		}
+		return "Approved"
+	case awardRecommendationRejected:
+		if len(r.Votes) < awardBoardQuorum {
+			return "Rejected, the board didn't reach quorum in time"
+		}
+		return "Rejected"
+	case awardRecommendationFailed:
+		awarded, _ := findAward(r.Awarded)
+		return fmt.Sprintf("Approved as the %v but not finished, it couldn't be recorded on their profile: %v", awarded.Name, r.Failure)
+	default:
+		return fmt.Sprintf("Pending, closes <t:%d:R>", r.Deadline.Unix())
+	}
+}
+
+// awardVoteSummary lists who voted which way, downgrades with the award they
+// suggested.
+func awardVoteSummary(recommendation awardRecommendationRecord) string {
+	votes := map[awardVoteKind][]string{}
+	for voterID, vote := range recommendation.Votes {
+		voter := fmt.Sprintf("<@%v>", voterID)
+		if vote.Kind == awardVoteDowngrade {
+			suggested, _ := findAward(vote.Award)
+			voter += fmt.Sprintf(" (%v)", suggested.Name)
+		}
+		votes[vote.Kind] = append(votes[vote.Kind], voter)
+	}
+
+	lines := make([]string, 0, 3)
+	for _, kind := range []awardVoteKind{awardVoteApprove, awardVoteReject, awardVoteDowngrade} {
+		slices.Sort(votes[kind])
+		line := fmt.Sprintf("**%v** (%d)", kind.String(), len(votes[kind]))
+		if len(votes[kind]) > 0 {
+			line += ": " + strings.Join(votes[kind], ", ")
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (k awardVoteKind) String() string {
+	switch k {
+	case awardVoteApprove:
+		return "Approve"
+	case awardVoteReject:
+		return "Reject"
+	case awardVoteDowngrade:
+		return "Downgrade"
+	default:
+		return string(k)
+	}
+}
+
+func awardBoardEmbed(recommendation awardRecommendationRecord, warnings []string) discord.Embed {
+	recommended, _ := findAward(recommendation.Award)
+	recommender := "Automatic"
+	if recommendation.RecommenderID != 0 {
+		recommender = fmt.Sprintf("<@%v>", recommendation.RecommenderID)
+	}
+
+	status := recommendation.statusText()
+	if len(warnings) > 0 {
+		status += ", however:\n- " + strings.Join(warnings, "\n- ")
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Award Recommendation #%d", recommendation.ID).
+		AddField("Award", recommended.Name, true).
+		AddField("Recipient", fmt.Sprintf("<@%v>", recommendation.RecipientID), true).
+		AddField("Recommended By", recommender, true).
+		AddField("Operation", orNone(recommendation.Operation), true).
+		AddField("Quorum", fmt.Sprintf("%d/%d votes", len(recommendation.Votes), awardBoardQuorum), true).
+		AddField("Citation", truncateField(orNone(recommendation.Citation)), false).
+		AddField("Votes", awardVoteSummary(recommendation), false).
+		AddField("Status", truncateField(status), false).
+		SetTimestamp(recommendation.Submitted).
+		Build()
+}
+
+func awardBoardComponents(recommendation awardRecommendationRecord) []discord.ContainerComponent {
+	customID := func(kind awardVoteKind) string {
+		return fmt.Sprintf("%v:%d:%v", awardVoteCustomID, recommendation.ID, kind)
+	}
+
+	return []discord.ContainerComponent{discord.NewActionRow(
+		discord.NewSuccessButton("Approve", customID(awardVoteApprove)),
+		discord.NewDangerButton("Reject", customID(awardVoteReject)),
+		discord.NewSecondaryButton("Downgrade", customID(awardVoteDowngrade)),
+	)}
+}
+
+// awardBoardMessageUpdate refreshes the board post, taking the voting buttons
+// away once the recommendation is decided.
+func awardBoardMessageUpdate(recommendation awardRecommendationRecord, warnings []string) discord.MessageUpdate {
+	message := discord.NewMessageUpdateBuilder().SetEmbeds(awardBoardEmbed(recommendation, warnings))
+	if recommendation.Status != awardRecommendationPending {
+		message.ClearContainerComponents()
+	}
+
+	return message.Build()
+}
@@ -0,0 +1,136 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+)
+
+const awardCommandName = "award"
+
+// Discord doesn't show more than 25 autocomplete choices
+const maxAutocompleteChoices = 25
+
+var awardCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        awardCommandName,
+		Description: "Look up and grant awards",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "list",
+				Description: "List every award in order of precedence",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "grant",
+				Description: "Record an approved award on a member's profile (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member receiving the award", Required: true},
+					discord.ApplicationCommandOptionString{Name: "award", Description: "Award to grant", Required: true, Autocomplete: true},
+					discord.ApplicationCommandOptionString{Name: "citation", Description: "Citation to read at the ceremony", Required: true},
+					discord.ApplicationCommandOptionString{Name: "operation", Description: "Operation number the award was earned in"},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{awardCommandEventListener, awardAutocompleteEventListener},
+}
+
+var awardCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != awardCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	switch *data.SubCommandName {
+	case "list":
+		lines := make([]string, 0, len(awards))
+		for _, a := range awards {
+			line := fmt.Sprintf("%d. **%v** - %v+", a.Precedence, a.Name, a.RecommenderRank)
+			if !a.MultipleAllowed {
+				line += ", once only"
+			}
+			lines = append(lines, line+"\n"+a.Criteria)
+		}
+
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Awards").
+			SetDescription(strings.Join(lines, "\n")).
+			Build(),
+		)
+	case "grant":
+		if !isStaff(event.Member()) {
+			message.SetContent("Only staff can do that.")
+			break
+		}
+
+		user := data.User("member")
+		a, ok := findAward(data.String("award"))
+		if !ok {
+			message.SetContentf("There's no award called \"%v\".", data.String("award"))
+			break
+		}
+
+		operation, err := checkOperationReference(data.String("operation"))
+		if err != nil {
+			message.SetContentf("Couldn't record the award: %v.", err)
+			break
+		}
+
+		err = recordAward(user.ID, a, strings.TrimSpace(data.String("citation")), operation, event.User().ID)
+		if errors.Is(err, errRosterMemberNotFound) {
+			message.SetContentf("%v isn't on the roster yet.", user.Mention())
+		} else if errors.Is(err, errAwardAlreadyHeld) {
+			message.SetContentf("%v already has the %v, %v.", user.Mention(), a.Name, err)
+		} else if err != nil {
+			slog.Error("error while recording award", slog.Any("err", err))
+			message.SetContentf("Couldn't record the award: %v.", err)
+		} else if event.GuildID() == nil {
+			message.SetContentf("Awarded the %v to %v.", a.Name, user.Mention())
+		} else if err = announceAward(event.Client(), *event.GuildID(), user.ID, a, data.String("citation")); err != nil {
+			slog.Error("error while announcing award", slog.Any("err", err))
+			message.SetContentf("Awarded the %v to %v, however I couldn't announce it: %v.", a.Name, user.Mention(), err)
+		} else {
+			message.SetContentf("Awarded the %v to %v.", a.Name, user.Mention())
+		}
+	default:
+		return
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// awardAutocompleteEventListener suggests awards whose name contains what's
+// been typed so far, since there are too many for fixed choices.
+var awardAutocompleteEventListener = bot.NewListenerFunc(func(event *events.AutocompleteInteractionCreate) {
+	if event.Data.CommandName != awardCommandName || event.Data.Focused().Name != "award" {
+		return
+	}
+
+	typed := strings.ToLower(event.Data.String("award"))
+	choices := make([]discord.AutocompleteChoice, 0, maxAutocompleteChoices)
+	for _, a := range awards {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+
+		if strings.Contains(strings.ToLower(a.Name), typed) || strings.EqualFold(a.Key, typed) {
+			choices = append(choices, discord.AutocompleteChoiceString{Name: a.Name, Value: a.Key})
+		}
+	}
+
+	if err := event.AutocompleteResult(choices); err != nil {
+		slog.Error("error while responding to autocomplete", slog.Any("err", err))
+	}
+})
@@ -2,81 +2,224 @@ package perscom_events
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
 	"log/slog"
+	"strconv"
 	"strings"
 )
 
 const awardRecommendationCustomID = "award-recommendation"
+const awardRecommendationPageCustomID = "award-recommendation-page"
 const awardRecommendationModalCustomID = "award-recommendation-modal"
+const awardRecommendationRecipientCustomID = "award-recommendation-recipient"
 const awardRecommendationModalSubmitCustomID = "award-recommendation-modal-submit"
 
+// Discord select menus can't have more than 25 options
+const awardsPerPage = 25
+
+const maxSelectOptionDescriptionLength = 100
+
 //go:embed award_recommendation_description.txt
 var awardRecommendationDescription string
 
 var awardRecommendation = ButtonEventHandler{
 	Button:         discord.NewPrimaryButton("Award Rec", awardRecommendationCustomID),
-	EventListeners: []bot.EventListener{awardRecommendationEventListener, awardRecommendationModalEventListener, awardRecommendationModalSubmitEventListener},
+	EventListeners: []bot.EventListener{awardRecommendationEventListener, awardRecommendationPageEventListener, awardRecommendationModalEventListener, awardRecommendationRecipientEventListener, awardRecommendationModalSubmitEventListener, awardVoteEventListener},
 }
 
 var awardRecommendationEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == awardRecommendationCustomID {
-		err := event.CreateMessage(discord.NewMessageCreateBuilder().
-			SetEphemeral(true).
+		builder := discord.NewMessageCreateBuilder().SetEphemeral(true)
+
+		member, err := getRosterMember(event.User().ID)
+		if errors.Is(err, errRosterMemberNotFound) {
+			builder.SetContent("You aren't on the roster yet, ask S1 to add you before recommending awards.")
+		} else if err != nil {
+			slog.Error("error while reading roster", slog.Any("err", err))
+			builder.SetContentf("Couldn't read the roster: %v.", err)
+		} else if eligible := recommendableAwards(member.Rank); len(eligible) == 0 {
+			builder.SetContent("Award recommendations can be made at the minimum rank of Corporal or Specialist.")
+		} else {
+			builder.
+				SetEmbeds(awardRecommendationEmbed()).
+				SetContainerComponents(awardRecommendationComponents(eligible, 0)...)
+		}
+
+		if err = event.CreateMessage(builder.Build()); err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+	}
+})
+
+var awardRecommendationPageEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if strings.HasPrefix(event.Data.CustomID(), awardRecommendationPageCustomID+":") {
+		page, err := strconv.Atoi(strings.TrimPrefix(event.Data.CustomID(), awardRecommendationPageCustomID+":"))
+		if err != nil {
+			slog.Error("error while parsing award page", slog.Any("err", err))
+			return
+		}
+
+		member, err := getRosterMember(event.User().ID)
+		if err != nil {
+			slog.Error("error while reading roster", slog.Any("err", err))
+			err = event.CreateMessage(discord.NewMessageCreateBuilder().
+				SetEphemeral(true).
+				SetContentf("Couldn't read the roster: %v.", err).
+				Build(),
+			)
+			if err != nil {
+				slog.Error("error while creating message", slog.Any("err", err))
+			}
+			return
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+			SetEmbeds(awardRecommendationEmbed()).
+			SetContainerComponents(awardRecommendationComponents(recommendableAwards(member.Rank), page)...).
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while updating message", slog.Any("err", err))
+		}
+	}
+})
+
+func awardRecommendationEmbed() discord.Embed {
+	return discord.NewEmbedBuilder().
+		SetTitle(":military_medal: Award Recommendation :military_medal:").
+		SetColor(0x5765f2).
+		SetDescription(awardRecommendationDescription).
+		Build()
+}
+
+// awardRecommendationComponents is the award select for one page of the
+// eligible awards, with buttons to move between pages when they don't fit.
+func awardRecommendationComponents(eligible []award, page int) []discord.ContainerComponent {
+	pages := (len(eligible) + awardsPerPage - 1) / awardsPerPage
+	page = max(0, min(page, pages-1))
+
+	start := page * awardsPerPage
+	end := min(start+awardsPerPage, len(eligible))
+	options := make([]discord.StringSelectMenuOption, 0, end-start)
+	for _, a := range eligible[start:end] {
+		option := discord.NewStringSelectMenuOption(a.Name, a.Key)
+		if a.Criteria != "" {
+			option = option.WithDescription(selectOptionDescription(a.Criteria))
+		}
+		options = append(options, option)
+	}
+
+	placeholder := "Select an award..."
+	if pages > 1 {
+		placeholder = fmt.Sprintf("Select an award... (page %d of %d)", page+1, pages)
+	}
+
+	components := []discord.ContainerComponent{
+		discord.NewActionRow(discord.NewStringSelectMenu(awardRecommendationModalCustomID, placeholder, options...)),
+	}
+
+	if pages > 1 {
+		components = append(components, discord.NewActionRow(
+			discord.NewSecondaryButton("Previous", fmt.Sprintf("%v:%d", awardRecommendationPageCustomID, page-1)).WithDisabled(page == 0),
+			discord.NewSecondaryButton("Next", fmt.Sprintf("%v:%d", awardRecommendationPageCustomID, page+1)).WithDisabled(page == pages-1),
+		))
+	}
+
+	return components
+}
+
+func selectOptionDescription(description string) string {
+	return truncateText(description, maxSelectOptionDescriptionLength)
+}
+
+var awardRecommendationModalEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if event.Data.CustomID() == awardRecommendationModalCustomID {
+		a, ok := findAward(event.StringSelectMenuInteractionData().Values[0])
+		if !ok {
+			slog.Error("unknown award", slog.String("award", event.StringSelectMenuInteractionData().Values[0]))
+			return
+		}
+
+		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			SetEmbeds(discord.NewEmbedBuilder().
-				SetTitle(":military_medal: Award Recommendation :military_medal:").
+				SetTitle(a.Name).
 				SetColor(0x5765f2).
-				SetDescription(awardRecommendationDescription).
+				SetDescription(a.Criteria).
+				SetThumbnail(a.RibbonURL()).
 				Build(),
 			).
-			AddActionRow(discord.NewStringSelectMenu(awardRecommendationModalCustomID, "Select an option...",
-				discord.NewStringSelectMenuOption("Air Service Medal", "Air Service Medal"),
-				discord.NewStringSelectMenuOption("Army Achievement Medal", "Army Achievement Medal"),
-				discord.NewStringSelectMenuOption("Army Commendation Medal", "Army Commendation Medal"),
-				discord.NewStringSelectMenuOption("Army NCODEV Ribbon", "Army NCODEV Ribbon"),
-				discord.NewStringSelectMenuOption("Bronze Star Medal", "Bronze Star Medal"),
-			)).
+			SetContainerComponents(discord.NewActionRow(discord.NewUserSelectMenu(awardRecommendationRecipientCustomID+":"+a.Key, "Who are you recommending?"))).
 			Build(),
 		)
 
 		if err != nil {
-			slog.Error("error while creating message", slog.Any("err", err))
+			slog.Error("error while updating message", slog.Any("err", err))
 		}
 	}
 })
 
-var awardRecommendationModalEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
-	if event.Data.CustomID() == awardRecommendationModalCustomID {
-		selectedOption := event.StringSelectMenuInteractionData().Values[0]
+var awardRecommendationRecipientEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if strings.HasPrefix(event.Data.CustomID(), awardRecommendationRecipientCustomID+":") {
+		a, ok := findAward(strings.TrimPrefix(event.Data.CustomID(), awardRecommendationRecipientCustomID+":"))
+		if !ok {
+			return
+		}
 
+		recipientID := event.UserSelectMenuInteractionData().Values[0]
 		err := event.Modal(
 			discord.NewModalCreateBuilder().
-				SetTitle("Award Recommendation").
-				SetCustomID(awardRecommendationModalSubmitCustomID + ":" + selectedOption).
-				AddActionRow(discord.NewShortTextInput("name", "Recipient Name")).
-				AddActionRow(discord.NewShortTextInput("operation_number", "Operation #")).
+				SetTitle(a.Name).
+				SetCustomID(fmt.Sprintf("%v:%v:%v", awardRecommendationModalSubmitCustomID, a.Key, recipientID)).
+				AddActionRow(discord.NewShortTextInput("operation_number", "Operation #").WithRequired(false).WithPlaceholder("Leave empty if it wasn't earned on an operation")).
 				AddActionRow(discord.NewParagraphTextInput("citation", "Citation")).
 				Build(),
 		)
 
 		if err != nil {
-			slog.Error("error while creating message", slog.Any("err", err))
+			slog.Error("error while creating modal", slog.Any("err", err))
 		}
 	}
 })
 
 var awardRecommendationModalSubmitEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
-	if strings.Contains(event.ModalSubmitInteraction.Data.CustomID, awardRecommendationModalSubmitCustomID) {
-		selectedOption := strings.Split(event.ModalSubmitInteraction.Data.CustomID, ":")[1]
+	if strings.HasPrefix(event.ModalSubmitInteraction.Data.CustomID, awardRecommendationModalSubmitCustomID+":") {
+		if event.GuildID() == nil {
+			return
+		}
 
-		//TODO: Create channel for collab on the medal
+		awardKey, rawRecipientID, _ := strings.Cut(strings.TrimPrefix(event.ModalSubmitInteraction.Data.CustomID, awardRecommendationModalSubmitCustomID+":"), ":")
+		recipientID, err := snowflake.Parse(rawRecipientID)
+		if err != nil {
+			slog.Error("error while parsing custom ID", slog.Any("err", err))
+			return
+		}
 
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		recommendation, err := submitAwardRecommendation(event.Client(), *event.GuildID(), awardRecommendationRecord{
+			Award:         awardKey,
+			RecipientID:   recipientID,
+			RecommenderID: event.User().ID,
+			Operation:     strings.TrimSpace(event.Data.Text("operation_number")),
+			Citation:      strings.TrimSpace(event.Data.Text("citation")),
+		})
+
+		content := ""
+		if err != nil {
+			content = fmt.Sprintf("Couldn't submit your award recommendation: %v.", err)
+		} else {
+			a, _ := findAward(recommendation.Award)
+			content = fmt.Sprintf("Submitted your recommendation of <@%v> for the %v, the awards board will vote on it by <t:%d:f>.", recipientID, a.Name, recommendation.Deadline.Unix())
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearContainerComponents().
 			ClearEmbeds().
-			SetContentf("Submitted your award recommendation request for \"%v\".", selectedOption).
+			SetContent(content).
 			Build(),
 		)
 
@@ -0,0 +1,207 @@
+package perscom_events
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"slices"
+	"strings"
+	"time"
+)
+
+// award is an entry on our awards page. Precedence orders awards from most to
+// least prestigious starting at 1, Ribbon is the ribbon image under the award
+// assets URL, and RecommenderRank is the lowest rank that may recommend it.
+type award struct {
+	Key             string
+	Name            string
+	Precedence      int
+	Ribbon          string
+	Criteria        string
+	MultipleAllowed bool
+	RecommenderRank string
+}
+
+var (
+	awardAssetsBaseURL = envString("award_assets_base_url", "https://72ndairborne.com/awards")
+	awardsChannelName  = envString("awards_channel", "awards")
+)
+
+// awards is ordered by precedence
+var awards = []award{
+	{"dsc", "Distinguished Service Cross", 1, "dsc.png", "Extraordinary heroism in combat at great personal risk.", true, "CPT"},
+	{"dsm", "Distinguished Service Medal", 2, "dsm.png", "Exceptionally meritorious service to the unit in a duty of great responsibility.", true, "CPT"},
+	{"ss", "Silver Star", 3, "ss.png", "Gallantry in action against an enemy.", true, "1LT"},
+	{"lom", "Legion of Merit", 4, "lom.png", "Exceptionally meritorious conduct in the performance of outstanding leadership over a campaign.", true, "1LT"},
+	{"dfc", "Distinguished Flying Cross", 5, "dfc.png", "Heroism or extraordinary achievement while participating in aerial flight.", true, "WO1"},
+	{"sm", "Soldier's Medal", 6, "sm.png", "Heroism not involving actual conflict, such as saving the lives of others at risk to their own.", true, "SFC"},
+	{"bsm", "Bronze Star Medal", 7, "bsm.png", "Heroic or meritorious achievement in combat operations.", true, "SFC"},
+	{"ph", "Purple Heart", 8, "ph.png", "Wounded or killed in action during an operation.", true, "SGT"},
+	{"msm", "Meritorious Service Medal", 9, "msm.png", "Outstanding meritorious service to the unit over an extended period.", true, "SFC"},
+	{"am", "Air Medal", 10, "am.png", "Meritorious achievement while participating in aerial flight.", true, "WO1"},
+	{"asm", "Air Service Medal", 11, "asm.png", "Sustained service as aircrew across multiple operations.", true, "WO1"},
+	{"arcom", "Army Commendation Medal", 12, "arcom.png", "Heroism, meritorious achievement or meritorious service.", true, "SSG"},
+	{"aam", "Army Achievement Medal", 13, "aam.png", "Meritorious service or achievement of a lesser degree than the Army Commendation Medal.", true, "SPC"},
+	{"agcm", "Army Good Conduct Medal", 14, "agcm.png", "Exemplary behavior, efficiency and fidelity over six months of active service.", true, "SGT"},
+	{"arcam", "Army Reserve Components Achievement Medal", 15, "arcam.png", "Exemplary behavior and fidelity while serving in the Reserves.", true, "SGT"},
+	{"ndsm", "National Defense Service Medal", 16, "ndsm.png", "Completing basic training and joining a combat unit.", false, "SPC"},
+	{"afem", "Armed Forces Expeditionary Medal", 17, "afem.png", "Participating in a campaign deployed away from the unit's home theater.", true, "SGT"},
+	{"gwotem", "Global War on Terrorism Expeditionary Medal", 18, "gwotem.png", "Participating in a counter-insurgency campaign.", false, "SGT"},
+	{"gwotsm", "Global War on Terrorism Service Medal", 19, "gwotsm.png", "Supporting a counter-insurgency campaign without deploying.", false, "SGT"},
+	{"hsm", "Humanitarian Service Medal", 20, "hsm.png", "Meritorious participation in a humanitarian or disaster relief operation.", true, "SGT"},
+	{"movsm", "Military Outstanding Volunteer Service Medal", 21, "movsm.png", "Outstanding volunteer service to the community outside of operations.", false, "SSG"},
+	{"afrm", "Armed Forces Reserve Medal", 22, "afrm.png", "Honorable service in the Reserves for six months.", true, "SGT"},
+	{"ncopdr", "Army NCODEV Ribbon", 23, "ncopdr.png", "Completing an NCO professional development course.", true, "SSG"},
+	{"asr", "Army Service Ribbon", 24, "asr.png", "Completing initial entry training.", false, "SPC"},
+	{"osr", "Overseas Service Ribbon", 25, "osr.png", "Completing a campaign deployed overseas.", true, "SGT"},
+	{"arcotr", "Army Reserve Components Overseas Training Ribbon", 26, "arcotr.png", "Completing a training deployment while serving in the Reserves.", true, "SGT"},
+}
+
+var errAwardAlreadyHeld = errors.New("that award can only be received once")
+
+func findAward(key string) (award, bool) {
+	for _, a := range awards {
+		if a.Key == key {
+			return a, true
+		}
+	}
+
+	return award{}, false
+}
+
+func findAwardByName(name string) (award, bool) {
+	for _, a := range awards {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+
+	return award{}, false
+}
+
+func (a award) RibbonURL() string {
+	return strings.TrimSuffix(awardAssetsBaseURL, "/") + "/" + a.Ribbon
+}
+
+// canRecommend reports whether a member of the given rank may recommend the
+// award. Members without a rank can't recommend anything.
+func (a award) canRecommend(rankAbbreviation string) bool {
+	if _, _, ok := findRank(rankAbbreviation); !ok {
+		return false
+	}
+
+	if _, _, ok := findRank(a.RecommenderRank); !ok {
+		return true
+	}
+	return rankAtLeast(rankAbbreviation, a.RecommenderRank)
+}
+
+// recommendableAwards lists the awards a member of the given rank may recommend.
+func recommendableAwards(rankAbbreviation string) []award {
+	var eligible []award
+	for _, a := range awards {
+		if a.canRecommend(rankAbbreviation) {
+			eligible = append(eligible, a)
+		}
+	}
+
+	return eligible
+}
+
+// recordAward adds the award to the member's roster record, refusing awards
+// that can only be received once when the member already holds them.
+func recordAward(memberID snowflake.ID, a award, citation string, operation string, awardedBy snowflake.ID) error {
+	if _, err := getRosterMember(memberID); err != nil {
+		return err
+	}
+
+	return updateRosterMember(memberID, func(member *rosterMember) error {
+		if !a.MultipleAllowed && member.awardCount(a.Name) > 0 {
+			return errAwardAlreadyHeld
+		}
+
+		member.Awards = append(member.Awards, rosterAward{
+			Name:      a.Name,
+			Awarded:   time.Now().UTC(),
+			Citation:  citation,
+			Operation: operation,
+			AwardedBy: awardedBy,
+		})
+		return nil
+	})
+}
+
+// announceAward posts the award to the awards channel along with the
+// recipient's updated ribbon rack.
+func announceAward(client bot.Client, guildID snowflake.ID, memberID snowflake.ID, a award, citation string) error {
+	member, err := getRosterMember(memberID)
+	if err != nil {
+		return err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, awardsChannelName)
+	if err != nil {
+		return err
+	}
+
+	embed := discord.NewEmbedBuilder().
+		SetColor(0xc9a227).
+		SetTitle(":military_medal: Award :military_medal:").
+		SetDescriptionf("<@%v> has been awarded the **%v**.", memberID, a.Name).
+		AddField("Citation", truncateField(orNone(citation)), false)
+
+	message := discord.NewMessageCreateBuilder()
+	rack, ok, err := renderRibbonRack(member)
+	if err != nil {
+		return err
+	} else if ok {
+		embed.SetImage("attachment://ribbons.png")
+		message.AddFile("ribbons.png", "Ribbon rack", bytes.NewReader(rack))
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, message.SetEmbeds(embed.Build()).Build())
+	return err
+}
+
+func (m rosterMember) awardCount(name string) int {
+	count := 0
+	for _, a := range m.Awards {
+		if strings.EqualFold(a.Name, name) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// awardPrecedence is the award's place in the catalog, awards no longer in
+// the catalog go after everything else.
+func awardPrecedence(name string) int {
+	if a, ok := findAwardByName(name); ok {
+		return a.Precedence
+	}
+
+	return len(awards) + 1
+}
+
+// sortAwardsByPrecedence orders received awards most prestigious first, and
+// by when they were awarded within the same award. Awards no longer in the
+// catalog are kept together by name.
+func sortAwardsByPrecedence(received []rosterAward) []rosterAward {
+	sorted := slices.Clone(received)
+	slices.SortStableFunc(sorted, func(a, b rosterAward) int {
+		if c := cmp.Compare(awardPrecedence(a.Name), awardPrecedence(b.Name)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+
+		return a.Awarded.Compare(b.Awarded)
+	})
+
+	return sorted
+}
@@ -0,0 +1,218 @@
+package perscom_events
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const whitelistCommandName = "whitelist"
+
+// whitelistToken protects the whitelist HTTP endpoint, which stays disabled
+// until it's set. Game servers pass it as `?token=` or as a bearer token.
+var whitelistToken = envString("whitelist_token", "")
+
+type whitelistFormat struct {
+	Key         string
+	Name        string
+	FileName    string
+	ContentType string
+}
+
+var whitelistFormats = []whitelistFormat{
+	{"guids", "Plain GUID list", "whitelist.txt", "text/plain; charset=utf-8"},
+	{"bans", "bans.txt style", "bans.txt", "text/plain; charset=utf-8"},
+	{"json", "JSON", "whitelist.json", "application/json"},
+}
+
+type whitelistEntry struct {
+	DiscordID snowflake.ID `json:"discord_id"`
+	Name      string       `json:"name"`
+	PlayerID  string       `json:"player_id"`
+	GUID      string       `json:"guid"`
+}
+
+var whitelistCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        whitelistCommandName,
+		Description: "Export the ArmA server BattlEye whitelist (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionString{
+				Name:        "format",
+				Description: "File format to export",
+				Required:    true,
+				Choices:     whitelistFormatChoices(),
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{whitelistCommandEventListener},
+}
+
+var whitelistCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != whitelistCommandName {
+		return
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	format, ok := findWhitelistFormat(event.SlashCommandInteractionData().String("format"))
+	if !isStaff(event.Member()) {
+		message.SetContent("Only staff can do that.")
+	} else if !ok {
+		message.SetContent("Unknown whitelist format.")
+	} else if content, count, err := exportWhitelist(format); err != nil {
+		slog.Error("error while exporting whitelist", slog.Any("err", err))
+		message.SetContentf("Couldn't export the whitelist: %v.", err)
+	} else {
+		message.SetContentf("Whitelist of %d members.", count).
+			AddFile(format.FileName, format.Name, bytes.NewReader(content))
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// battlEyeGUID derives the GUID BattlEye identifies a player by from their
+// Steam64 ID: the MD5 of "BE" followed by the ID as a little endian uint64.
+func battlEyeGUID(steam64ID string) (string, error) {
+	id, err := strconv.ParseUint(steam64ID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 10)
+	copy(data, "BE")
+	binary.LittleEndian.PutUint64(data[2:], id)
+
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// getWhitelistEntries lists every member with a valid player ID who is allowed
+// on the server. Discharged and suspended members are left off.
+func getWhitelistEntries() ([]whitelistEntry, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]whitelistEntry, 0, len(members))
+	for _, member := range members {
+		if member.Status == rosterStatusDischarged || member.Status == rosterStatusSuspended {
+			continue
+		}
+		if !steam64IDPattern.MatchString(member.PlayerID) {
+			continue
+		}
+
+		guid, err := battlEyeGUID(member.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, whitelistEntry{
+			DiscordID: member.DiscordID,
+			Name:      member.Name,
+			PlayerID:  member.PlayerID,
+			GUID:      guid,
+		})
+	}
+
+	return entries, nil
+}
+
+func exportWhitelist(format whitelistFormat) ([]byte, int, error) {
+	entries, err := getWhitelistEntries()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var buffer bytes.Buffer
+	switch format.Key {
+	case "guids":
+		for _, entry := range entries {
+			buffer.WriteString(entry.GUID + "\n")
+		}
+	case "bans":
+		// Same layout as BattlEye's bans.txt: GUID, duration and a comment
+		for _, entry := range entries {
+			fmt.Fprintf(&buffer, "%v -1 %v\n", entry.GUID, strings.ReplaceAll(entry.Name, "\n", " "))
+		}
+	case "json":
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(entries); err != nil {
+			return nil, 0, err
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown whitelist format %q", format.Key)
+	}
+
+	return buffer.Bytes(), len(entries), nil
+}
+
+func findWhitelistFormat(key string) (whitelistFormat, bool) {
+	for _, format := range whitelistFormats {
+		if format.Key == key {
+			return format, true
+		}
+	}
+
+	return whitelistFormat{}, false
+}
+
+func whitelistFormatChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(whitelistFormats))
+	for _, format := range whitelistFormats {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: format.Name, Value: format.Key})
+	}
+
+	return choices
+}
+
+func serveWhitelist(w http.ResponseWriter, r *http.Request) {
+	// The whitelist has every member's Steam64 ID, never serve it unprotected
+	if whitelistToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(whitelistToken)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	format, ok := findWhitelistFormat(r.PathValue("format"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, _, err := exportWhitelist(format)
+	if err != nil {
+		slog.Error("error while exporting whitelist", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(content)
+}
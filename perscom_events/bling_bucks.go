@@ -2,6 +2,7 @@ package perscom_events
 
 import (
 	_ "embed"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
@@ -31,15 +32,7 @@ var blingBucksEventListener = bot.NewListenerFunc(func(event *events.ComponentIn
 				SetDescription(blingBucksDescription).
 				Build(),
 			).
-			AddActionRow(discord.NewStringSelectMenu(selectedBBOptionCustomID, "Select an option...",
-				discord.NewStringSelectMenuOption("Raffle Ticket - 2 BB", "Raffle Ticket"),
-				discord.NewStringSelectMenuOption("Helmet - 8 BB", "Helmet"),
-				discord.NewStringSelectMenuOption("Insignia - 10 BB", "Insignia"),
-				discord.NewStringSelectMenuOption("Uniform - 10 BB", "Uniform"),
-				discord.NewStringSelectMenuOption("Backpack - 10 BB", "Backpack"),
-				discord.NewStringSelectMenuOption("Vest - 12 BB", "Vest"),
-				discord.NewStringSelectMenuOption("Face-wear - 16 BB", "Face-wear"),
-			)).
+			AddActionRow(discord.NewStringSelectMenu(selectedBBOptionCustomID, "Select an option...", blingBucksStoreOptions()...)).
 			Build(),
 		)
 
@@ -51,24 +44,50 @@ var blingBucksEventListener = bot.NewListenerFunc(func(event *events.ComponentIn
 
 var blingBucksSelectedOptionEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == selectedBBOptionCustomID {
-		var err error
 		selectedOption := event.StringSelectMenuInteractionData().Values[0]
-
-		if selectedOption == "Raffle Ticket" {
-			//TODO: Make it S-1's problem. No channel necessary
-
-			err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		reply := func(content string) error {
+			return event.UpdateMessage(discord.NewMessageUpdateBuilder().
 				ClearEmbeds().
 				ClearContainerComponents().
-				SetContent("Submitted your Bling Bucks request.").
+				SetContent(content).
 				Build(),
 			)
+		}
+
+		item, ok := findBlingBucksStoreItem(selectedOption)
+		if !ok {
+			if err := reply(fmt.Sprintf("%v isn't in the Bling Bucks store anymore.", selectedOption)); err != nil {
+				slog.Error("error while updating message", slog.Any("err", err))
+			}
+			return
+		}
+
+		balance, err := getBlingBucksBalance(event.User().ID)
+		if err != nil {
+			slog.Error("error while reading bling bucks balance", slog.Any("err", err))
+			if err = reply(fmt.Sprintf("Couldn't read your Bling Bucks balance: %v.", err)); err != nil {
+				slog.Error("error while updating message", slog.Any("err", err))
+			}
+			return
+		}
+
+		if balance < item.Price {
+			err = reply(fmt.Sprintf("%v costs %d BB but your balance is %d BB.", item.Name, item.Price, balance))
+		} else if selectedOption == "Raffle Ticket" {
+			//TODO: Make it S-1's problem. No channel necessary
+			content := "Submitted your Bling Bucks request."
+			if err = purchaseBlingBucksItem(event.User().ID, selectedOption, ""); err != nil {
+				content = fmt.Sprintf("Couldn't submit your Bling Bucks request: %v.", err)
+			}
+
+			err = reply(content)
 		} else {
+			defaults := getModalDefaults(event.User().ID)
 			err = event.Modal(discord.NewModalCreateBuilder().
 				SetTitle("Bling Bucks Request").
 				SetCustomID(blingBucksModalSubmitCustomID + ":" + selectedOption).
-				AddActionRow(discord.NewShortTextInput("name", "Name")).
-				AddActionRow(discord.NewShortTextInput("player_id", "Player ID")).
+				AddActionRow(discord.NewShortTextInput("name", "Name").WithValue(defaults.Name)).
+				AddActionRow(discord.NewShortTextInput("player_id", "Player ID").WithValue(defaults.PlayerID)).
 				AddActionRow(discord.NewParagraphTextInput("description", "Link and/or Description")).
 				Build(),
 			)
@@ -82,7 +101,6 @@ var blingBucksSelectedOptionEventListener = bot.NewListenerFunc(func(event *even
 
 var blingBucksModalSubmitEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
 	if strings.Contains(event.ModalSubmitInteraction.Data.CustomID, blingBucksModalSubmitCustomID) {
-		var err error
 		selectedBBOption := ""
 		if split := strings.Split(event.ModalSubmitInteraction.Data.CustomID, ":"); len(split) == 2 {
 			selectedBBOption = split[1]
@@ -91,10 +109,25 @@ var blingBucksModalSubmitEventListener = bot.NewListenerFunc(func(event *events.
 		}
 
 		//ToDo: Create channel with details of BB request
+		content := "Submitted your Bling Bucks request."
+		name, err := validateNameInput(event.User().ID, event.Data.Text("name"))
+		if err == nil {
+			saveModalDefaults(event.User().ID, modalDefaults{
+				Name:     name,
+				PlayerID: event.Data.Text("player_id"),
+			})
+
+			err = purchaseBlingBucksItem(event.User().ID, selectedBBOption, event.Data.Text("description"))
+		}
+
+		if err != nil {
+			content = fmt.Sprintf("Couldn't submit your Bling Bucks request: %v.", err)
+		}
+
 		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearEmbeds().
 			ClearContainerComponents().
-			SetContent("Submitted your Bling Bucks request.").
+			SetContent(content).
 			Build(),
 		)
 
@@ -103,3 +136,12 @@ var blingBucksModalSubmitEventListener = bot.NewListenerFunc(func(event *events.
 		}
 	}
 })
+
+func blingBucksStoreOptions() []discord.StringSelectMenuOption {
+	options := make([]discord.StringSelectMenuOption, 0, len(blingBucksStoreItems))
+	for _, item := range blingBucksStoreItems {
+		options = append(options, discord.NewStringSelectMenuOption(fmt.Sprintf("%v - %d BB", item.Name, item.Price), item.Name))
+	}
+
+	return options
+}
@@ -0,0 +1,198 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const blingBucksCommandName = "bling-bucks"
+const blingBucksLeaderboardSize = 10
+
+var blingBucksCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        blingBucksCommandName,
+		Description: "Bling Bucks balances, leaderboard and gifting",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "balance",
+				Description: "Show a Bling Bucks balance",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to look up, defaults to you"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "leaderboard",
+				Description: "Show the top Bling Bucks earners",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{
+						Name:        "period",
+						Description: "Period to rank, defaults to this month",
+						Choices: []discord.ApplicationCommandOptionChoiceString{
+							{Name: "This month", Value: "month"},
+							{Name: "All time", Value: "all"},
+						},
+					},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "gift",
+				Description: "Gift some of your Bling Bucks to another member",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member receiving the gift", Required: true},
+					discord.ApplicationCommandOptionInt{Name: "amount", Description: "Amount of BB to gift", Required: true},
+					discord.ApplicationCommandOptionString{Name: "memo", Description: "Why you're gifting"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "award",
+				Description: "Award Bling Bucks to a member (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member receiving the award", Required: true},
+					discord.ApplicationCommandOptionInt{Name: "amount", Description: "Amount of BB to award", Required: true},
+					discord.ApplicationCommandOptionString{Name: "memo", Description: "Event or reason for the award"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "flag-alt",
+				Description: "Flag a member as an alternate account (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to flag", Required: true},
+					discord.ApplicationCommandOptionString{Name: "reason", Description: "Reason for the flag"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "unflag-alt",
+				Description: "Remove an alternate account flag (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to unflag", Required: true},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{blingBucksCommandEventListener},
+}
+
+var blingBucksCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != blingBucksCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	var message discord.MessageCreate
+	switch subCommand := *data.SubCommandName; subCommand {
+	case "balance":
+		member, ok := data.OptUser("member")
+		if !ok {
+			member = event.User()
+		}
+
+		balance, err := getBlingBucksBalance(member.ID)
+		if err != nil {
+			slog.Error("error while reading bling bucks balance", slog.Any("err", err))
+			message = discord.NewMessageCreateBuilder().
+				SetEphemeral(true).
+				SetContentf("Couldn't read the Bling Bucks balance: %v.", err).
+				Build()
+			break
+		}
+
+		message = discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContentf(":coin: %v has %d BB.", member.Mention(), balance).
+			Build()
+	case "leaderboard":
+		message = blingBucksLeaderboardMessage(data.String("period"))
+	case "gift":
+		member := data.User("member")
+		amount := data.Int("amount")
+
+		content := fmt.Sprintf(":coin: Gifted %d BB to %v.", amount, member.Mention())
+		if member.Bot {
+			content = "You can't gift Bling Bucks to a bot."
+		} else if err := giftBlingBucks(event.User().ID, member.ID, amount, data.String("memo")); err != nil {
+			content = fmt.Sprintf("Couldn't gift Bling Bucks: %v.", err)
+		}
+
+		message = discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()
+	case "award", "flag-alt", "unflag-alt":
+		if !isStaff(event.Member()) {
+			message = discord.NewMessageCreateBuilder().
+				SetEphemeral(true).
+				SetContent("Only staff can do that.").
+				Build()
+			break
+		}
+
+		member := data.User("member")
+		var err error
+		var content string
+		switch subCommand {
+		case "award":
+			err = awardBlingBucks(event.User().ID, member.ID, data.Int("amount"), data.String("memo"))
+			content = fmt.Sprintf(":coin: Awarded %d BB to %v.", data.Int("amount"), member.Mention())
+		case "flag-alt":
+			err = setBlingBucksAltFlag(member.ID, true, data.String("reason"))
+			content = fmt.Sprintf("Flagged %v as an alternate account.", member.Mention())
+		case "unflag-alt":
+			err = setBlingBucksAltFlag(member.ID, false, "")
+			content = fmt.Sprintf("Removed the alternate account flag from %v.", member.Mention())
+		}
+
+		if err != nil {
+			content = fmt.Sprintf("Couldn't update Bling Bucks: %v.", err)
+		}
+
+		message = discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()
+	default:
+		return
+	}
+
+	if err := event.CreateMessage(message); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+func blingBucksLeaderboardMessage(period string) discord.MessageCreate {
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	title := fmt.Sprintf(":coin: Top Earners - %v %d :coin:", now.Month(), now.Year())
+	if period == "all" {
+		since = time.Time{}
+		title = ":coin: Top Earners - All Time :coin:"
+	}
+
+	standings, err := getBlingBucksLeaderboard(since, blingBucksLeaderboardSize)
+	if err != nil {
+		slog.Error("error while building bling bucks leaderboard", slog.Any("err", err))
+		return discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Couldn't build the leaderboard right now.").
+			Build()
+	}
+
+	var description strings.Builder
+	for i, standing := range standings {
+		fmt.Fprintf(&description, "%d. <@%v> - %d BB\n", i+1, standing.MemberID, standing.Earned)
+	}
+	if len(standings) == 0 {
+		description.WriteString("No Bling Bucks have been awarded yet.")
+	}
+
+	return discord.NewMessageCreateBuilder().
+		SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0xe8b923).
+			SetTitle(title).
+			SetDescription(description.String()).
+			Build(),
+		).
+		Build()
+}
@@ -0,0 +1,256 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/snowflake/v2"
+	"sort"
+	"time"
+)
+
+type blingBucksTransactionKind string
+
+const (
+	blingBucksTransactionAward    blingBucksTransactionKind = "award"
+	blingBucksTransactionPurchase blingBucksTransactionKind = "purchase"
+	blingBucksTransactionGift     blingBucksTransactionKind = "gift"
+)
+
+// blingBucksTransaction moves Amount BB from From to To. Awards have no From
+// and purchases have no To, both are represented by a zero ID.
+type blingBucksTransaction struct {
+	Time     time.Time                 `json:"time"`
+	Kind     blingBucksTransactionKind `json:"kind"`
+	From     snowflake.ID              `json:"from,omitempty"`
+	To       snowflake.ID              `json:"to,omitempty"`
+	Amount   int                       `json:"amount"`
+	Memo     string                    `json:"memo,omitempty"`
+	IssuedBy snowflake.ID              `json:"issued_by,omitempty"`
+}
+
+type blingBucksLedgerData struct {
+	Transactions []blingBucksTransaction `json:"transactions"`
+	// FlaggedAlts holds members staff have flagged as alternate accounts,
+	// keyed by member with the reason as the value.
+	FlaggedAlts map[snowflake.ID]string `json:"flagged_alts"`
+}
+
+type blingBucksStoreItem struct {
+	Name  string
+	Price int
+}
+
+type blingBucksStanding struct {
+	MemberID snowflake.ID
+	Earned   int
+}
+
+var blingBucksStoreItems = []blingBucksStoreItem{
+	{"Raffle Ticket", 2},
+	{"Helmet", 8},
+	{"Insignia", 10},
+	{"Uniform", 10},
+	{"Backpack", 10},
+	{"Vest", 12},
+	{"Face-wear", 16},
+}
+
+var (
+	blingBucksDailyGiftCap   = envInt("bling_bucks_daily_gift_cap", 10)
+	blingBucksDailyGiftCount = envInt("bling_bucks_daily_gift_count", 3)
+)
+
+var (
+	errBlingBucksInsufficientBalance = errors.New("you don't have enough Bling Bucks for that")
+	errBlingBucksInvalidAmount       = errors.New("the amount must be greater than zero")
+	errBlingBucksUnknownItem         = errors.New("that item isn't in the Bling Bucks store")
+	errBlingBucksGiftToSelf          = errors.New("you can't gift Bling Bucks to yourself")
+	errBlingBucksGiftToAlt           = errors.New("that member has been flagged by staff and can't receive gifts")
+	errBlingBucksGiftFromAlt         = errors.New("your account has been flagged by staff and can't send gifts")
+	errBlingBucksGiftUnderDischarge  = errors.New("you can't gift Bling Bucks while your discharge is pending")
+)
+
+var blingBucksLedger = newJSONStore("bling_bucks_ledger", func() blingBucksLedgerData {
+	return blingBucksLedgerData{FlaggedAlts: map[snowflake.ID]string{}}
+})
+
+func findBlingBucksStoreItem(name string) (blingBucksStoreItem, bool) {
+	for _, item := range blingBucksStoreItems {
+		if item.Name == name {
+			return item, true
+		}
+	}
+
+	return blingBucksStoreItem{}, false
+}
+
+func (l *blingBucksLedgerData) balance(memberID snowflake.ID) int {
+	balance := 0
+	for _, transaction := range l.Transactions {
+		if transaction.To == memberID {
+			balance += transaction.Amount
+		}
+		if transaction.From == memberID {
+			balance -= transaction.Amount
+		}
+	}
+
+	return balance
+}
+
+func getBlingBucksBalance(memberID snowflake.ID) (int, error) {
+	var balance int
+	err := blingBucksLedger.View(func(ledger *blingBucksLedgerData) {
+		balance = ledger.balance(memberID)
+	})
+
+	return balance, err
+}
+
+func awardBlingBucks(issuedBy snowflake.ID, memberID snowflake.ID, amount int, memo string) error {
+	if amount <= 0 {
+		return errBlingBucksInvalidAmount
+	}
+
+	return blingBucksLedger.Update(func(ledger *blingBucksLedgerData) error {
+		ledger.Transactions = append(ledger.Transactions, blingBucksTransaction{
+			Time:     time.Now().UTC(),
+			Kind:     blingBucksTransactionAward,
+			To:       memberID,
+			Amount:   amount,
+			Memo:     memo,
+			IssuedBy: issuedBy,
+		})
+		return nil
+	})
+}
+
+// purchaseBlingBucksItem debits the price of the named store item from the
+// member, failing if their balance doesn't cover it.
+func purchaseBlingBucksItem(memberID snowflake.ID, itemName string, memo string) error {
+	item, ok := findBlingBucksStoreItem(itemName)
+	if !ok {
+		return errBlingBucksUnknownItem
+	}
+
+	return blingBucksLedger.Update(func(ledger *blingBucksLedgerData) error {
+		if ledger.balance(memberID) < item.Price {
+			return errBlingBucksInsufficientBalance
+		}
+
+		ledger.Transactions = append(ledger.Transactions, blingBucksTransaction{
+			Time:   time.Now().UTC(),
+			Kind:   blingBucksTransactionPurchase,
+			From:   memberID,
+			Amount: item.Price,
+			Memo:   item.Name + memoSuffix(memo),
+		})
+		return nil
+	})
+}
+
+func giftBlingBucks(from snowflake.ID, to snowflake.ID, amount int, memo string) error {
+	if amount <= 0 {
+		return errBlingBucksInvalidAmount
+	}
+	if from == to {
+		return errBlingBucksGiftToSelf
+	}
+	if pending, err := isDischargePending(from); err != nil {
+		return err
+	} else if pending {
+		return errBlingBucksGiftUnderDischarge
+	}
+
+	return blingBucksLedger.Update(func(ledger *blingBucksLedgerData) error {
+		if _, ok := ledger.FlaggedAlts[from]; ok {
+			return errBlingBucksGiftFromAlt
+		}
+		if _, ok := ledger.FlaggedAlts[to]; ok {
+			return errBlingBucksGiftToAlt
+		}
+
+		now := time.Now().UTC()
+		giftedToday, giftsToday := 0, 0
+		for _, transaction := range ledger.Transactions {
+			if transaction.Kind == blingBucksTransactionGift && transaction.From == from && now.Sub(transaction.Time) < 24*time.Hour {
+				giftedToday += transaction.Amount
+				giftsToday++
+			}
+		}
+
+		if giftsToday >= blingBucksDailyGiftCount {
+			return fmt.Errorf("you can only send %d gifts per day", blingBucksDailyGiftCount)
+		}
+		if giftedToday+amount > blingBucksDailyGiftCap {
+			return fmt.Errorf("you can only gift %d BB per day, you have %d BB left today", blingBucksDailyGiftCap, max(blingBucksDailyGiftCap-giftedToday, 0))
+		}
+		if ledger.balance(from) < amount {
+			return errBlingBucksInsufficientBalance
+		}
+
+		ledger.Transactions = append(ledger.Transactions, blingBucksTransaction{
+			Time:   now,
+			Kind:   blingBucksTransactionGift,
+			From:   from,
+			To:     to,
+			Amount: amount,
+			Memo:   memo,
+		})
+		return nil
+	})
+}
+
+// getBlingBucksLeaderboard ranks members by BB awarded to them since the
+// given time. Gifts only move existing BB around, so they don't count as
+// earnings.
+func getBlingBucksLeaderboard(since time.Time, limit int) ([]blingBucksStanding, error) {
+	earned := map[snowflake.ID]int{}
+	err := blingBucksLedger.View(func(ledger *blingBucksLedgerData) {
+		for _, transaction := range ledger.Transactions {
+			if transaction.Kind == blingBucksTransactionAward && !transaction.Time.Before(since) {
+				earned[transaction.To] += transaction.Amount
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	standings := make([]blingBucksStanding, 0, len(earned))
+	for memberID, amount := range earned {
+		standings = append(standings, blingBucksStanding{memberID, amount})
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Earned != standings[j].Earned {
+			return standings[i].Earned > standings[j].Earned
+		}
+		return standings[i].MemberID < standings[j].MemberID
+	})
+
+	if len(standings) > limit {
+		standings = standings[:limit]
+	}
+
+	return standings, nil
+}
+
+func setBlingBucksAltFlag(memberID snowflake.ID, flagged bool, reason string) error {
+	return blingBucksLedger.Update(func(ledger *blingBucksLedgerData) error {
+		if flagged {
+			ledger.FlaggedAlts[memberID] = reason
+		} else {
+			delete(ledger.FlaggedAlts, memberID)
+		}
+		return nil
+	})
+}
+
+func memoSuffix(memo string) string {
+	if memo == "" {
+		return ""
+	}
+
+	return ": " + memo
+}
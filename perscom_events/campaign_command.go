@@ -0,0 +1,207 @@
+package perscom_events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const campaignCommandName = "campaign"
+
+var campaignCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        campaignCommandName,
+		Description: "Track campaigns and their statistics (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "start",
+				Description: "Start a campaign, operations from then on are part of it",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "name", Description: "Name of the campaign", Required: true},
+					discord.ApplicationCommandOptionString{Name: "date", Description: "First day of the campaign as YYYY-MM-DD, defaults to today"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "close",
+				Description: "Close the running campaign and post its summary",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "date", Description: "Last day of the campaign as YYYY-MM-DD, defaults to today"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "stats",
+				Description: "Show a campaign's statistics with a CSV export",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "name", Description: "Name of the campaign, defaults to the running one"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "list",
+				Description: "List every campaign",
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{campaignCommandEventListener},
+}
+
+var campaignCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != campaignCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	message := discord.NewMessageUpdateBuilder()
+	switch *data.SubCommandName {
+	case "start":
+		date, err := dateOption(data)
+		if err != nil {
+			message.SetContentf("Couldn't start the campaign: %v.", err)
+			break
+		}
+
+		campaign, err := startCampaign(data.String("name"), date, event.User().ID)
+		if err != nil {
+			message.SetContentf("Couldn't start the campaign: %v.", err)
+		} else {
+			message.SetContentf("Started the %v campaign on %v.", campaign.Name, campaign.Start.Format(time.DateOnly))
+		}
+	case "close":
+		date, err := dateOption(data)
+		if err != nil {
+			message.SetContentf("Couldn't close the campaign: %v.", err)
+			break
+		}
+
+		campaign, err := closeCampaign(date, event.User().ID)
+		if err != nil {
+			message.SetContentf("Couldn't close the campaign: %v.", err)
+		} else if err = postCampaignSummary(event.Client(), *event.GuildID(), campaign); err != nil {
+			slog.Error("error while posting campaign summary", slog.Any("err", err))
+			message.SetContentf("Closed the %v campaign, however I couldn't post its summary: %v.", campaign.Name, err)
+		} else {
+			message.SetContentf("Closed the %v campaign, the summary was posted to #%v.", campaign.Name, campaignChannelName)
+		}
+	case "stats":
+		campaign, err := campaignOption(data)
+		if errors.Is(err, errCampaignNotFound) {
+			message.SetContent("There's no campaign with that name, and none running.")
+			break
+		} else if err != nil {
+			slog.Error("error while reading campaign", slog.Any("err", err))
+			message.SetContentf("Couldn't read the campaign: %v.", err)
+			break
+		}
+
+		if embed, file, err := campaignSummary(campaign); err != nil {
+			slog.Error("error while compiling campaign statistics", slog.Any("err", err))
+			message.SetContentf("Couldn't compile the campaign statistics: %v.", err)
+		} else {
+			message.SetEmbeds(embed).AddFiles(file)
+		}
+	case "list":
+		list, err := listCampaigns()
+		if err != nil {
+			slog.Error("error while listing campaigns", slog.Any("err", err))
+			message.SetContentf("Couldn't list the campaigns: %v.", err)
+			break
+		}
+
+		lines := make([]string, 0, len(list))
+		for _, campaign := range list {
+			lines = append(lines, fmt.Sprintf("**%v** - %v", campaign.Name, campaign.dates()))
+		}
+
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Campaigns").
+			SetDescription(orNone(strings.Join(lines, "\n"))).
+			Build(),
+		)
+	default:
+		return
+	}
+
+	if _, err := event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), message.Build()); err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+// campaignOption reads the campaign named by the command, defaulting to the
+// running one.
+func campaignOption(data discord.SlashCommandInteractionData) (campaignRecord, error) {
+	if name, ok := data.OptString("name"); ok {
+		return findCampaign(name)
+	}
+
+	campaign, found, err := runningCampaign()
+	if err == nil && !found {
+		err = errCampaignNotFound
+	}
+	return campaign, err
+}
+
+// campaignSummary builds the campaign's summary embed along with the
+// statistics of every member as a CSV file.
+func campaignSummary(campaign campaignRecord) (discord.Embed, *discord.File, error) {
+	stats, err := buildCampaignStats(campaign, time.Now().UTC())
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	content, err := campaignCSV(stats)
+	if err != nil {
+		return discord.Embed{}, nil, err
+	}
+
+	name := strings.ToLower(strings.Join(strings.Fields(campaign.Name), "-"))
+	return campaignSummaryEmbed(stats), discord.NewFile(fmt.Sprintf("campaign-%v.csv", name), "Campaign statistics", bytes.NewReader(content)), nil
+}
+
+func postCampaignSummary(client bot.Client, guildID snowflake.ID, campaign campaignRecord) error {
+	channelID, err := findGuildChannel(client, guildID, campaignChannelName)
+	if err != nil {
+		return err
+	}
+
+	embed, file, err := campaignSummary(campaign)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetEmbeds(embed).
+		AddFiles(file).
+		Build(),
+	)
+
+	return err
+}
@@ -0,0 +1,449 @@
+package perscom_events
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var campaignChannelName = envString("campaign_channel", aarChannelName)
+
+var (
+	errCampaignNotFound = errors.New("there's no campaign with that name")
+	errCampaignRunning  = errors.New("another campaign is still running")
+)
+
+// campaignRecord is a run of operations. Start and End are the first and last
+// days of the campaign, End being zero while it's still running.
+type campaignRecord struct {
+	Name      string       `json:"name"`
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end,omitempty"`
+	StartedBy snowflake.ID `json:"started_by,omitempty"`
+	ClosedBy  snowflake.ID `json:"closed_by,omitempty"`
+}
+
+type campaignData struct {
+	Campaigns []campaignRecord `json:"campaigns"`
+}
+
+var campaigns = newJSONStore("campaigns", func() campaignData {
+	return campaignData{}
+})
+
+// campaignMemberStats is how a member did over a campaign, counting each
+// attendance status recorded for them on its operations.
+type campaignMemberStats struct {
+	Member          rosterMember
+	Present         int
+	Partial         int
+	Excused         int
+	AWOL            int
+	TemporaryPasses int
+	Awards          []rosterAward
+}
+
+// campaignUnitStats is the turnout of a unit, Expected counting every
+// attendance record of its members that wasn't excused and Attended those
+// that were present or partial.
+type campaignUnitStats struct {
+	Unit     string
+	Attended int
+	Expected int
+}
+
+type campaignStats struct {
+	Campaign   campaignRecord
+	Operations []operationRecord
+	Members    []campaignMemberStats
+	Units      []campaignUnitStats
+}
+
+func (c campaignRecord) running() bool {
+	return c.End.IsZero()
+}
+
+// covers reports whether the day falls within the campaign, a running one
+// covering every day from its start on.
+func (c campaignRecord) covers(day time.Time) bool {
+	return !day.Before(c.Start) && (c.running() || !day.After(c.End))
+}
+
+func (c campaignRecord) dates() string {
+	if c.running() {
+		return fmt.Sprintf("%v to now", c.Start.Format(time.DateOnly))
+	}
+
+	return fmt.Sprintf("%v to %v", c.Start.Format(time.DateOnly), c.End.Format(time.DateOnly))
+}
+
+func findCampaign(name string) (campaignRecord, error) {
+	var campaign campaignRecord
+	found := false
+	err := campaigns.View(func(data *campaignData) {
+		for _, c := range data.Campaigns {
+			if strings.EqualFold(c.Name, strings.TrimSpace(name)) {
+				campaign, found = c, true
+				return
+			}
+		}
+	})
+
+	if err == nil && !found {
+		err = errCampaignNotFound
+	}
+	return campaign, err
+}
+
+// runningCampaign returns the campaign that hasn't been closed yet, if there
+// is one.
+func runningCampaign() (campaignRecord, bool, error) {
+	var campaign campaignRecord
+	found := false
+	err := campaigns.View(func(data *campaignData) {
+		for _, c := range data.Campaigns {
+			if c.running() {
+				campaign, found = c, true
+				return
+			}
+		}
+	})
+
+	return campaign, found, err
+}
+
+// campaignOn returns the name of the campaign the operation on opID belongs
+// to, or an empty string when it isn't part of one.
+func campaignOn(opID string) (string, error) {
+	day, err := time.Parse(time.DateOnly, opID)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	err = campaigns.View(func(data *campaignData) {
+		for _, c := range data.Campaigns {
+			if c.covers(day) {
+				name = c.Name
+				return
+			}
+		}
+	})
+
+	return name, err
+}
+
+func listCampaigns() ([]campaignRecord, error) {
+	var list []campaignRecord
+	err := campaigns.View(func(data *campaignData) {
+		list = slices.Clone(data.Campaigns)
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start.After(list[j].Start)
+	})
+
+	return list, err
+}
+
+// startCampaign starts a campaign on the day. Only one campaign runs at a
+// time and it has to start after every other campaign ended, so campaigns
+// never overlap.
+func startCampaign(name string, start time.Time, startedBy snowflake.ID) (campaignRecord, error) {
+	campaign := campaignRecord{Name: strings.TrimSpace(name), Start: start, StartedBy: startedBy}
+	if campaign.Name == "" {
+		return campaign, errors.New("the campaign needs a name")
+	}
+
+	err := campaigns.Update(func(data *campaignData) error {
+		for _, c := range data.Campaigns {
+			switch {
+			case strings.EqualFold(c.Name, campaign.Name):
+				return errors.New("there's already a campaign with that name")
+			case c.running():
+				return errCampaignRunning
+			case !start.After(c.End):
+				return fmt.Errorf("the campaign has to start after the %v campaign ended on %v", c.Name, c.End.Format(time.DateOnly))
+			}
+		}
+
+		data.Campaigns = append(data.Campaigns, campaign)
+		return nil
+	})
+
+	return campaign, err
+}
+
+// closeCampaign ends the running campaign on the day. It can't end on or
+// after the start of another campaign.
+func closeCampaign(end time.Time, closedBy snowflake.ID) (campaignRecord, error) {
+	var campaign campaignRecord
+	err := campaigns.Update(func(data *campaignData) error {
+		for i, c := range data.Campaigns {
+			if !c.running() {
+				continue
+			}
+
+			if end.Before(c.Start) {
+				return errors.New("the campaign can't end before it started")
+			}
+
+			for _, other := range data.Campaigns {
+				if other.Name != c.Name && !other.Start.Before(c.Start) && !end.Before(other.Start) {
+					return fmt.Errorf("the campaign has to end before the %v campaign started on %v", other.Name, other.Start.Format(time.DateOnly))
+				}
+			}
+
+			data.Campaigns[i].End = end
+			data.Campaigns[i].ClosedBy = closedBy
+			campaign = data.Campaigns[i]
+			return nil
+		}
+		return errors.New("there's no campaign running")
+	})
+
+	return campaign, err
+}
+
+// attendanceRate is the share of the operations the member was expected at
+// that they showed up to, excused absences not counting against them. ok is
+// false when they weren't expected at any.
+func (s campaignMemberStats) attendanceRate() (float64, bool) {
+	expected := s.Present + s.Partial + s.AWOL
+	if expected == 0 {
+		return 0, false
+	}
+
+	return float64(s.Present+s.Partial) / float64(expected), true
+}
+
+func (s campaignUnitStats) turnout() (float64, bool) {
+	if s.Expected == 0 {
+		return 0, false
+	}
+
+	return float64(s.Attended) / float64(s.Expected), true
+}
+
+// buildCampaignStats counts the attendance, temporary passes and awards of
+// everyone involved in the campaign. Its operations are those registered to
+// it along with any registered without a campaign during it. Members are
+// counted in the unit they're in now as the roster doesn't keep the units
+// they were in before.
+func buildCampaignStats(campaign campaignRecord, now time.Time) (campaignStats, error) {
+	stats := campaignStats{Campaign: campaign}
+
+	ops, err := listOperations()
+	if err != nil {
+		return stats, err
+	}
+
+	opIDs := []string{}
+	for _, op := range slices.Backward(ops) {
+		day, _ := time.Parse(time.DateOnly, op.OpID)
+		if strings.EqualFold(op.Campaign, campaign.Name) || (op.Campaign == "" && campaign.covers(day)) {
+			stats.Operations = append(stats.Operations, op)
+			opIDs = append(opIDs, op.OpID)
+		}
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return stats, err
+	}
+
+	passes, err := countTemporaryPasses(opIDs)
+	if err != nil {
+		return stats, err
+	}
+
+	byID := map[snowflake.ID]*campaignMemberStats{}
+	memberStats := func(member rosterMember) *campaignMemberStats {
+		if byID[member.DiscordID] == nil {
+			byID[member.DiscordID] = &campaignMemberStats{Member: member, TemporaryPasses: passes[member.DiscordID]}
+		}
+		return byID[member.DiscordID]
+	}
+
+	for _, opID := range opIDs {
+		op, found, err := getAttendance(opID)
+		if err != nil {
+			return stats, err
+		} else if !found {
+			continue
+		}
+
+		for _, member := range members {
+			record, ok := op.Records[member.DiscordID]
+			if !ok {
+				continue
+			}
+
+			s := memberStats(member)
+			switch record.Status {
+			case attendancePresent:
+				s.Present++
+			case attendancePartial:
+				s.Partial++
+			case attendanceExcused:
+				s.Excused++
+			case attendanceAWOL:
+				s.AWOL++
+			}
+		}
+	}
+
+	// The whole of the last day counts
+	end := now
+	if !campaign.running() {
+		end = campaign.End.AddDate(0, 0, 1)
+	}
+
+	for _, member := range members {
+		for _, received := range member.Awards {
+			if !received.Awarded.Before(campaign.Start) && received.Awarded.Before(end) {
+				s := memberStats(member)
+				s.Awards = append(s.Awards, received)
+			}
+		}
+
+		if passes[member.DiscordID] > 0 {
+			memberStats(member)
+		}
+	}
+
+	units := map[string]*campaignUnitStats{}
+	for _, s := range byID {
+		s.Awards = sortAwardsByPrecedence(s.Awards)
+		stats.Members = append(stats.Members, *s)
+
+		if units[s.Member.Unit] == nil {
+			units[s.Member.Unit] = &campaignUnitStats{Unit: s.Member.Unit}
+		}
+		units[s.Member.Unit].Attended += s.Present + s.Partial
+		units[s.Member.Unit].Expected += s.Present + s.Partial + s.AWOL
+	}
+
+	sort.Slice(stats.Members, func(i, j int) bool {
+		return strings.ToLower(stats.Members[i].Member.Name) < strings.ToLower(stats.Members[j].Member.Name)
+	})
+
+	// Units in ORBAT order, those no longer in it after
+	for _, unit := range orbatUnits {
+		if s, ok := units[unit.Name]; ok {
+			stats.Units = append(stats.Units, *s)
+			delete(units, unit.Name)
+		}
+	}
+	var rest []string
+	for name := range units {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		stats.Units = append(stats.Units, *units[name])
+	}
+
+	return stats, nil
+}
+
+func formatRate(rate float64, ok bool) string {
+	if !ok {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// campaignCSV has a row per member with everything counted for them.
+func campaignCSV(stats campaignStats) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write([]string{"Name", "Discord ID", "Rank", "Unit", "Present", "Partial", "Excused", "AWOL", "Attendance Rate", "Temporary Passes", "Awards"}); err != nil {
+		return nil, err
+	}
+
+	for _, s := range stats.Members {
+		awardNames := make([]string, 0, len(s.Awards))
+		for _, received := range s.Awards {
+			awardNames = append(awardNames, received.Name)
+		}
+
+		err := w.Write([]string{
+			s.Member.Name,
+			s.Member.DiscordID.String(),
+			s.Member.Rank,
+			s.Member.Unit,
+			strconv.Itoa(s.Present),
+			strconv.Itoa(s.Partial),
+			strconv.Itoa(s.Excused),
+			strconv.Itoa(s.AWOL),
+			formatRate(s.attendanceRate()),
+			strconv.Itoa(s.TemporaryPasses),
+			strings.Join(awardNames, "; "),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// campaignSummaryEmbed sums up the campaign: its operations, the overall
+// attendance rate, each unit's turnout, the members who never missed an op
+// and the temporary passes and awards given during it.
+func campaignSummaryEmbed(stats campaignStats) discord.Embed {
+	ops := make([]string, 0, len(stats.Operations))
+	for _, op := range stats.Operations {
+		ops = append(ops, fmt.Sprintf("%v (%v)", op.Title(), op.OpID))
+	}
+
+	var attended, expected, passes, passMembers int
+	var perfect, awarded []string
+	for _, s := range stats.Members {
+		attended += s.Present + s.Partial
+		expected += s.Present + s.Partial + s.AWOL
+		if rate, ok := s.attendanceRate(); ok && rate == 1 {
+			perfect = append(perfect, fmt.Sprintf("<@%v>", s.Member.DiscordID))
+		}
+
+		if s.TemporaryPasses > 0 {
+			passes += s.TemporaryPasses
+			passMembers++
+		}
+
+		for _, received := range s.Awards {
+			awarded = append(awarded, fmt.Sprintf("**%v** to <@%v>", received.Name, s.Member.DiscordID))
+		}
+	}
+
+	units := make([]string, 0, len(stats.Units))
+	for _, unit := range stats.Units {
+		if unit.Expected == 0 {
+			continue
+		}
+		units = append(units, fmt.Sprintf("%v: %v (%d of %d)", orNone(unit.Unit), formatRate(unit.turnout()), unit.Attended, unit.Expected))
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Campaign Summary - %v", stats.Campaign.Name).
+		SetDescriptionf("%v, %d operations.", stats.Campaign.dates(), len(stats.Operations)).
+		AddField("Operations", truncateField(orNone(strings.Join(ops, "\n"))), false).
+		AddField("Attendance Rate", formatRate(float64(attended)/float64(max(expected, 1)), expected > 0), true).
+		AddField("Temporary Passes", fmt.Sprintf("%d from %d members", passes, passMembers), true).
+		AddField("Unit Turnout", truncateField(orNone(strings.Join(units, "\n"))), false).
+		AddField("Perfect Attendance", truncateField(orNone(strings.Join(perfect, ", "))), false).
+		AddField(fmt.Sprintf("Awards (%d)", len(awarded)), truncateField(orNone(strings.Join(awarded, "\n"))), false).
+		Build()
+}
@@ -0,0 +1,170 @@
+package perscom_events
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const ceremonyDateLayout = "2 January 2006"
+
+// ceremonyEntry is one citation to read at a ceremony.
+type ceremonyEntry struct {
+	Member   rosterMember
+	Award    award
+	Received rosterAward
+}
+
+// ceremonyHistory remembers which days ceremonies were held, a ceremony
+// covers the awards given since the one before it.
+type ceremonyHistory struct {
+	Dates []string `json:"dates"`
+}
+
+var ceremonyStore = newJSONStore("ceremonies", func() ceremonyHistory {
+	return ceremonyHistory{}
+})
+
+// holdCeremony records that the ceremony on date was held, so the next one
+// starts with the awards given after it.
+func holdCeremony(date time.Time, now time.Time) error {
+	if date.After(now) {
+		return errors.New("a ceremony can't be recorded before the day it's held")
+	}
+
+	day := date.Format(time.DateOnly)
+	return ceremonyStore.Update(func(history *ceremonyHistory) error {
+		if slices.Contains(history.Dates, day) {
+			return fmt.Errorf("the %v ceremony is already recorded", date.Format(ceremonyDateLayout))
+		}
+
+		history.Dates = append(history.Dates, day)
+		slices.Sort(history.Dates)
+		return nil
+	})
+}
+
+// ceremonyEntries lists the awards given after the previous ceremony up to
+// the end of the ceremony day, ordered by precedence, then by rank with the
+// highest first, then by name. Compiling them doesn't record the ceremony, see
+// holdCeremony.
+func ceremonyEntries(date time.Time) ([]ceremonyEntry, error) {
+	day := date.Format(time.DateOnly)
+	var since time.Time
+	err := ceremonyStore.View(func(history *ceremonyHistory) {
+		for _, held := range history.Dates {
+			if held < day {
+				if previous, err := time.Parse(time.DateOnly, held); err == nil {
+					since = previous.Add(24 * time.Hour)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	until := date.Add(24 * time.Hour)
+	var entries []ceremonyEntry
+	for _, member := range members {
+		for _, received := range member.Awards {
+			if received.Awarded.Before(since) || !received.Awarded.Before(until) {
+				continue
+			}
+
+			a, ok := findAwardByName(received.Name)
+			if !ok {
+				a = award{Name: received.Name, Precedence: awardPrecedence(received.Name)}
+			}
+			entries = append(entries, ceremonyEntry{Member: member, Award: a, Received: received})
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b ceremonyEntry) int {
+		if c := cmp.Compare(a.Award.Precedence, b.Award.Precedence); c != 0 {
+			return c
+		}
+
+		_, aRank, _ := findRank(a.Member.Rank)
+		_, bRank, _ := findRank(b.Member.Rank)
+		if c := cmp.Compare(bRank, aRank); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Member.Name, b.Member.Name)
+	})
+
+	return entries, nil
+}
+
+func (e ceremonyEntry) details() string {
+	details := fmt.Sprintf("Awarded %v", e.Received.Awarded.Format(ceremonyDateLayout))
+	if e.Received.Operation != "" {
+		details = fmt.Sprintf("Operation %v, awarded %v", e.Received.Operation, e.Received.Awarded.Format(ceremonyDateLayout))
+	}
+
+	return details
+}
+
+func ceremonyIntroduction() string {
+	return fmt.Sprintf("Attention to orders. The following awards are presented to members of the %v for their actions and service.", squadXMLSections[0].Title)
+}
+
+// ceremonyMarkdown is the ceremony script with a heading per award and the
+// citations to read under it.
+func ceremonyMarkdown(date time.Time, entries []ceremonyEntry) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Award Ceremony, %v\n\n%v\n", date.Format(ceremonyDateLayout), ceremonyIntroduction())
+
+	for i, entry := range entries {
+		if i == 0 || entries[i-1].Award.Name != entry.Award.Name {
+			fmt.Fprintf(&b, "\n## %v\n", entry.Award.Name)
+		}
+
+		fmt.Fprintf(&b, "\n### %v\n\n*%v*\n\n%v\n", orNone(entry.Member.Name), entry.details(), orNone(entry.Received.Citation))
+	}
+
+	if len(entries) == 0 {
+		b.WriteString("\nNo awards were given since the last ceremony.\n")
+	}
+
+	return []byte(b.String())
+}
+
+// ceremonyPDF is the same script as ceremonyMarkdown laid out for printing.
+func ceremonyPDF(date time.Time, entries []ceremonyEntry) []byte {
+	doc := newPDFDocument(pdfLetterWidth, pdfLetterHeight)
+	flow := newPDFFlow(doc, 54)
+
+	flow.paragraph(fmt.Sprintf("Award Ceremony, %v", date.Format(ceremonyDateLayout)), pdfHelveticaBold, 20, 0)
+	flow.space(8)
+	flow.paragraph(ceremonyIntroduction(), pdfHelvetica, 11, 0)
+
+	for i, entry := range entries {
+		if i == 0 || entries[i-1].Award.Name != entry.Award.Name {
+			flow.space(14)
+			flow.paragraph(entry.Award.Name, pdfHelveticaBold, 15, 0)
+		}
+
+		flow.space(8)
+		flow.paragraph(orNone(entry.Member.Name), pdfHelveticaBold, 12, 12)
+		flow.paragraph(entry.details(), pdfHelveticaOblique, 10, 12)
+		flow.space(4)
+		flow.paragraph(orNone(entry.Received.Citation), pdfHelvetica, 11, 12)
+	}
+
+	if len(entries) == 0 {
+		flow.space(14)
+		flow.paragraph("No awards were given since the last ceremony.", pdfHelvetica, 11, 0)
+	}
+
+	return doc.bytes()
+}
@@ -0,0 +1,148 @@
+package perscom_events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const ceremonyCommandName = "ceremony"
+
+// Discord doesn't take more than 10 files on a message, the certificate PDF
+// takes one of them
+const maxCertificateImages = 9
+
+var ceremonyCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        ceremonyCommandName,
+		Description: "Prepare an award ceremony (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "script",
+				Description: "Compile the citations given since the last ceremony into a script",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "date", Description: "Day of the ceremony as YYYY-MM-DD, defaults to today"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "hold",
+				Description: "Record that a ceremony was held, the next one starts with the awards given after it",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "date", Description: "Day of the ceremony as YYYY-MM-DD, defaults to today"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "certificates",
+				Description: "Make a certificate for every award given since the last ceremony",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "date", Description: "Day of the ceremony as YYYY-MM-DD, defaults to today"},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{ceremonyCommandEventListener},
+}
+
+var ceremonyCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != ceremonyCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	if !isStaff(event.Member()) {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	message := discord.NewMessageUpdateBuilder()
+	date, err := dateOption(data)
+	if err != nil {
+		message.SetContentf("Couldn't compile the ceremony: %v.", err)
+	} else if *data.SubCommandName == "hold" {
+		if err = holdCeremony(date, time.Now().UTC()); err != nil {
+			message.SetContentf("Couldn't record the ceremony: %v.", err)
+		} else {
+			message.SetContentf("Recorded the %v ceremony, the next one starts with the awards given after it.", date.Format(ceremonyDateLayout))
+		}
+	} else if entries, err := ceremonyEntries(date); err != nil {
+		slog.Error("error while compiling ceremony", slog.Any("err", err))
+		message.SetContentf("Couldn't compile the ceremony: %v.", err)
+	} else if *data.SubCommandName == "script" {
+		day := date.Format(time.DateOnly)
+		message.
+			SetContentf("Ceremony script for %v with %d citations. Once it's held, record it with `/ceremony hold` so the next one starts after it.", date.Format(ceremonyDateLayout), len(entries)).
+			AddFile(fmt.Sprintf("ceremony-%v.md", day), "Ceremony script", bytes.NewReader(ceremonyMarkdown(date, entries))).
+			AddFile(fmt.Sprintf("ceremony-%v.pdf", day), "Ceremony script", bytes.NewReader(ceremonyPDF(date, entries)))
+	} else if len(entries) == 0 {
+		message.SetContentf("No awards were given for the %v ceremony since the last one.", date.Format(ceremonyDateLayout))
+	} else if err = addCertificates(message, date, entries); err != nil {
+		slog.Error("error while rendering certificates", slog.Any("err", err))
+		message.SetContentf("Couldn't make the certificates: %v.", err)
+	}
+
+	if _, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), message.Build()); err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+// dateOption reads the date option of a command, defaulting to today.
+func dateOption(data discord.SlashCommandInteractionData) (time.Time, error) {
+	value, ok := data.OptString("date")
+	if !ok {
+		return time.Now().UTC().Truncate(24 * time.Hour), nil
+	}
+
+	date, err := time.Parse(time.DateOnly, strings.TrimSpace(value))
+	if err != nil {
+		return date, errors.New("the date must be a date like 2006-01-30")
+	}
+
+	return date, nil
+}
+
+// addCertificates attaches the certificates PDF, along with each certificate
+// as an image when they fit on the message.
+func addCertificates(message *discord.MessageUpdateBuilder, date time.Time, entries []ceremonyEntry) error {
+	pngs, pdf, err := renderCertificates(entries)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("Certificates for the %v ceremony.", date.Format(ceremonyDateLayout))
+	if len(pngs) > maxCertificateImages {
+		content += " There are too many to attach each image, they're all in the PDF."
+	} else {
+		for i, png := range pngs {
+			name := strings.Join(strings.Fields(entries[i].Member.Name+" "+entries[i].Award.Key), "-")
+			message.AddFile(fmt.Sprintf("certificate-%d-%v.png", i+1, name), "Award certificate", bytes.NewReader(png))
+		}
+	}
+
+	message.
+		SetContent(content).
+		AddFile(fmt.Sprintf("certificates-%v.pdf", date.Format(time.DateOnly)), "Award certificates", bytes.NewReader(pdf))
+	return nil
+}
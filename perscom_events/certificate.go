@@ -0,0 +1,176 @@
+package perscom_events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const certificateMargin = 90
+const certificateSignatureWidth = 420
+
+var (
+	certificateBackground = color.RGBA{0xfb, 0xf6, 0xe9, 0xff}
+	certificateText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	certificateAccent     = color.RGBA{0x8a, 0x6d, 0x1e, 0xff}
+)
+
+// certificateTemplate lays out an award certificate from the top down. Each
+// line's text is a text/template filled in from a certificateData, lines that
+// come out empty are skipped and long lines are wrapped. Gap is the space
+// above a line in pixels. S1 can replace the default by putting a
+// certificate_template.json in the data directory.
+type certificateTemplate struct {
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Lines      []certificateLine `json:"lines"`
+	Signatures []string          `json:"signatures"`
+}
+
+type certificateLine struct {
+	Text   string `json:"text"`
+	Scale  int    `json:"scale"`
+	Gap    int    `json:"gap"`
+	Accent bool   `json:"accent"`
+}
+
+type certificateData struct {
+	Unit      string
+	Name      string
+	Award     string
+	Citation  string
+	Operation string
+	Date      string
+}
+
+var defaultCertificateTemplate = certificateTemplate{
+	Width:  1650,
+	Height: 1275,
+	Lines: []certificateLine{
+		{Text: "{{.Unit}}", Scale: 4, Accent: true},
+		{Text: "CERTIFICATE OF ACHIEVEMENT", Scale: 6, Gap: 30},
+		{Text: "This is to certify that", Scale: 3, Gap: 60},
+		{Text: "{{.Name}}", Scale: 7, Gap: 30, Accent: true},
+		{Text: "has been awarded the", Scale: 3, Gap: 30},
+		{Text: "{{.Award}}", Scale: 5, Gap: 30, Accent: true},
+		{Text: "{{.Citation}}", Scale: 3, Gap: 40},
+		{Text: "{{if .Operation}}Operation {{.Operation}}, {{end}}{{.Date}}", Scale: 3, Gap: 40},
+	},
+	Signatures: envList("certificate_signatures"),
+}
+
+func loadCertificateTemplate() (certificateTemplate, error) {
+	t := defaultCertificateTemplate
+	if len(t.Signatures) == 0 {
+		t.Signatures = []string{"Commanding Officer", "S1 Officer"}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dataDirectory, "certificate_template.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+
+	return t, json.Unmarshal(content, &t)
+}
+
+func newCertificateData(entry ceremonyEntry) certificateData {
+	return certificateData{
+		Unit:      squadXMLSections[0].Title,
+		Name:      entry.Member.Name,
+		Award:     entry.Award.Name,
+		Citation:  strings.Join(strings.Fields(entry.Received.Citation), " "),
+		Operation: entry.Received.Operation,
+		Date:      entry.Received.Awarded.Format(ceremonyDateLayout),
+	}
+}
+
+// renderCertificate draws the certificate with a double border, the
+// template's lines centered down the page and a signature line for each
+// signatory along the bottom.
+func renderCertificate(t certificateTemplate, data certificateData) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, t.Width, t.Height))
+	fillRect(img, img.Bounds(), certificateBackground)
+	strokeRect(img, img.Bounds().Inset(30), certificateAccent, 8)
+	strokeRect(img, img.Bounds().Inset(50), certificateAccent, 2)
+
+	signatureTop := t.Height - certificateMargin - 2*textHeight(2) - 16
+	y := certificateMargin
+	for _, line := range t.Lines {
+		tmpl, err := template.New("line").Parse(line.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		var text bytes.Buffer
+		if err = tmpl.Execute(&text, data); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text.String()) == "" {
+			continue
+		}
+
+		c := certificateText
+		if line.Accent {
+			c = certificateAccent
+		}
+
+		scale := max(line.Scale, 1)
+		y += line.Gap
+		for _, wrapped := range wrapTextLines(text.String(), t.Width-2*certificateMargin, scale) {
+			if y+textHeight(scale) > signatureTop {
+				break
+			}
+
+			drawTextCentered(img, t.Width/2, y, wrapped, c, scale)
+			y += textHeight(scale) + 2*scale
+		}
+	}
+
+	for i, signature := range t.Signatures {
+		centerX := t.Width * (2*i + 1) / (2 * len(t.Signatures))
+		lineWidth := min(certificateSignatureWidth, t.Width/len(t.Signatures)-40)
+		fillRect(img, image.Rect(centerX-lineWidth/2, signatureTop, centerX+lineWidth/2, signatureTop+2), certificateText)
+		drawTextCentered(img, centerX, signatureTop+12, signature, certificateText, 2)
+	}
+
+	return img, nil
+}
+
+// renderCertificates draws a certificate for every entry, returning the PNGs
+// and a PDF with a landscape page per certificate.
+func renderCertificates(entries []ceremonyEntry) ([][]byte, []byte, error) {
+	t, err := loadCertificateTemplate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc := newPDFDocument(pdfLetterHeight, pdfLetterWidth)
+	pngs := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		img, err := renderCertificate(t, newCertificateData(entry))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		content, err := encodePNG(img)
+		if err != nil {
+			return nil, nil, err
+		}
+		pngs = append(pngs, content)
+
+		if err = doc.addPage().image(img, 0, 0, doc.Width, doc.Height); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return pngs, doc.bytes(), nil
+}
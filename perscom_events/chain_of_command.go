@@ -0,0 +1,164 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// chainLink is one leader billet in a member's chain of command. MemberID is
+// zero while the billet is vacant.
+type chainLink struct {
+	Unit     orbatUnit
+	Billet   orbatBillet
+	MemberID snowflake.ID
+}
+
+func (l chainLink) vacant() bool {
+	return l.MemberID == 0
+}
+
+func (l chainLink) String() string {
+	holder := "vacant"
+	if !l.vacant() {
+		holder = fmt.Sprintf("<@%v>", l.MemberID)
+	}
+
+	return fmt.Sprintf("%v %v: %v", l.Unit.Name, l.Billet.Name, holder)
+}
+
+// chainOfCommand lists the leaders above the member, closest first. Leaders of
+// a unit answer to the leaders of its parent, everyone else to the leader of
+// their own unit. Members outside the ORBAT have no chain of command.
+func chainOfCommand(memberID snowflake.ID) ([]chainLink, error) {
+	member, err := getRosterMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, ok := findOrbatUnitByName(member.Unit)
+	if !ok {
+		return nil, nil
+	}
+
+	assignments, err := getBilletAssignments()
+	if err != nil {
+		return nil, err
+	}
+
+	return unitChainOfCommand(unit, memberID, assignments), nil
+}
+
+// unitChainOfCommand lists the leader billets of the unit and every unit above
+// it. A unit exclude leads is left out entirely, since its leaders answer to
+// the unit above rather than to each other, so nobody ends up in their own
+// chain.
+func unitChainOfCommand(unit orbatUnit, exclude snowflake.ID, assignments map[string][]snowflake.ID) []chainLink {
+	var chain []chainLink
+	for {
+		leads := slices.ContainsFunc(unit.Billets, func(billet orbatBillet) bool {
+			return billet.Leader && slices.Contains(assignments[billetAssignmentKey(unit, billet)], exclude)
+		})
+
+		for _, billet := range unit.Billets {
+			if !billet.Leader || leads {
+				continue
+			}
+
+			link := chainLink{Unit: unit, Billet: billet}
+			if holders := assignments[billetAssignmentKey(unit, billet)]; len(holders) > 0 {
+				link.MemberID = holders[0]
+			}
+			chain = append(chain, link)
+		}
+
+		parent, ok := findOrbatUnit(unit.Parent)
+		if !ok {
+			return chain
+		}
+		unit = parent
+	}
+}
+
+// firstLeader is the closest leader in the chain whose billet is filled.
+func firstLeader(chain []chainLink) (chainLink, bool) {
+	for _, link := range chain {
+		if !link.vacant() {
+			return link, true
+		}
+	}
+
+	return chainLink{}, false
+}
+
+// unitLeader is whoever leads the unit, falling back up the chain while the
+// leader billet is vacant and leaving out exclude so nobody approves their own
+// transfer. It's zero when the whole chain is vacant or the unit isn't part of
+// the ORBAT.
+func unitLeader(unitName string, exclude snowflake.ID) (snowflake.ID, error) {
+	unit, ok := findOrbatUnitByName(unitName)
+	if !ok {
+		return 0, nil
+	}
+
+	assignments, err := getBilletAssignments()
+	if err != nil {
+		return 0, err
+	}
+
+	leader, _ := firstLeader(unitChainOfCommand(unit, exclude, assignments))
+	return leader.MemberID, nil
+}
+
+// notifyChainOfCommand lets the member's closest leader know about something
+// the member filed. Leaders are messaged directly, when a billet is vacant the
+// next leader up is told why they're getting it. Errors are only logged since
+// the member's request already went through.
+func notifyChainOfCommand(client bot.Client, memberID snowflake.ID, what string) {
+	chain, err := chainOfCommand(memberID)
+	if err != nil && !errors.Is(err, errRosterMemberNotFound) {
+		slog.Error("error while looking up chain of command", slog.Any("err", err), slog.Any("member", memberID))
+		return
+	}
+
+	leader, ok := firstLeader(chain)
+	if !ok {
+		return
+	}
+
+	var vacant []string
+	for _, link := range chain {
+		if !link.vacant() {
+			break
+		}
+		vacant = append(vacant, fmt.Sprintf("%v %v", link.Unit.Name, link.Billet.Name))
+	}
+
+	content := fmt.Sprintf("<@%v> %v.", memberID, what)
+	if len(vacant) > 0 {
+		content += fmt.Sprintf("\nYou're getting this because the %v billet is vacant.", strings.Join(vacant, " and "))
+	}
+
+	if err = sendDirectMessage(client, leader.MemberID, content); err != nil {
+		slog.Error("error while notifying chain of command", slog.Any("err", err), slog.Any("member", memberID), slog.Any("leader", leader.MemberID))
+	}
+}
+
+// directSuperiorMention names the member's closest leader for replies to the
+// member, or an empty string when they don't have one.
+func directSuperiorMention(memberID snowflake.ID) string {
+	chain, err := chainOfCommand(memberID)
+	if err != nil {
+		return ""
+	}
+
+	if leader, ok := firstLeader(chain); ok {
+		return fmt.Sprintf("<@%v>", leader.MemberID)
+	}
+
+	return ""
+}
@@ -0,0 +1,256 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const classCommandName = "class"
+
+// Classes run two hours unless the instructor says otherwise
+const defaultClassMinutes = 120
+
+var classCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        classCommandName,
+		Description: "Schedule classes for courses members asked for",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "demand",
+				Description: "Show how many members are waiting for each course",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "times",
+				Description: "Rank the weekly times most of the members waiting for a course can make",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "course", Description: "Course to teach", Required: true, Choices: courseChoices()},
+					discord.ApplicationCommandOptionUser{Name: "instructor", Description: "Instructor whose availability to match, defaults to you"},
+					discord.ApplicationCommandOptionInt{Name: "minutes", Description: fmt.Sprintf("How long the class runs, defaults to %d minutes", defaultClassMinutes)},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "schedule",
+				Description: "Schedule a class and invite everyone waiting for it (instructors only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "course", Description: "Course to teach", Required: true, Choices: courseChoices()},
+					discord.ApplicationCommandOptionString{Name: "start", Description: "Start of the class in UTC as YYYY-MM-DD HH:MM", Required: true},
+					discord.ApplicationCommandOptionInt{Name: "minutes", Description: fmt.Sprintf("How long the class runs, defaults to %d minutes", defaultClassMinutes)},
+					discord.ApplicationCommandOptionInt{Name: "slots", Description: "How many students to take, defaults to the course's capacity"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "cancel",
+				Description: "Cancel a class (instructors only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionInt{Name: "class", Description: "Class number", Required: true},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "list",
+				Description: "List the classes coming up",
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{classCommandEventListener},
+}
+
+var classCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != classCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil || event.GuildID() == nil {
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	message := discord.NewMessageUpdateBuilder()
+	switch *data.SubCommandName {
+	case "demand":
+		demand, err := courseDemand()
+		if err != nil {
+			slog.Error("error while reading course requests", slog.Any("err", err))
+			message.SetContentf("Couldn't read the course requests: %v.", err)
+			break
+		}
+
+		lines := make([]string, 0, len(courses))
+		for _, c := range courses {
+			if waiting := len(demand[c.Key]); waiting > 0 {
+				lines = append(lines, fmt.Sprintf("**%v** - %d waiting", c.Name, waiting))
+			}
+		}
+
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Course Demand").
+			SetDescription(orNone(strings.Join(lines, "\n"))).
+			Build(),
+		)
+	case "times":
+		c, _ := findCourse(data.String("course"))
+		instructor, ok := data.OptUser("instructor")
+		if !ok {
+			instructor = event.User()
+		}
+		minutes, ok := data.OptInt("minutes")
+		if !ok {
+			minutes = defaultClassMinutes
+		} else if minutes <= 0 {
+			message.SetContent("The class has to run for at least a minute.")
+			break
+		}
+
+		demand, err := courseDemand()
+		if err != nil {
+			slog.Error("error while reading course requests", slog.Any("err", err))
+			message.SetContentf("Couldn't read the course requests: %v.", err)
+			break
+		}
+
+		pending := demand[c.Key]
+		if len(pending) == 0 {
+			message.SetContentf("Nobody is waiting for a %v class.", c.Name)
+			break
+		}
+
+		times, err := bestClassTimes(pending, instructor.ID, time.Duration(minutes)*time.Minute, time.Now().UTC())
+		if err != nil {
+			slog.Error("error while ranking class times", slog.Any("err", err))
+			message.SetContentf("Couldn't rank the class times: %v.", err)
+			break
+		}
+
+		availability, err := getMemberAvailability(instructor.ID)
+		if err != nil {
+			slog.Error("error while reading availability", slog.Any("err", err))
+		}
+		instructorAvailability := availability.summary()
+		if !availability.complete() {
+			instructorAvailability = "Not set, times are ranked on the students alone. Set it with `/availability`."
+		}
+
+		message.SetContent("These times come around every week, schedule one with `/class schedule` using the UTC start shown.").SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitlef("Best Class Times - %v", c.Name).
+			SetDescription(orNone(formatSessionTimes(times, len(pending)))).
+			AddField("Instructor", fmt.Sprintf("%v - %v", instructor.Mention(), instructorAvailability), false).
+			Build(),
+		)
+	case "schedule":
+		c, _ := findCourse(data.String("course"))
+		start, err := time.Parse(classTimeLayout, strings.TrimSpace(data.String("start")))
+		if err != nil {
+			message.SetContent("The start must be a UTC date and time like 2006-01-30 19:00.")
+			break
+		}
+
+		allowed, err := canTeachCourse(event.Client(), *event.GuildID(), event.Member(), c)
+		if err != nil {
+			slog.Error("error while checking instructor role", slog.Any("err", err))
+			message.SetContentf("Couldn't check whether you teach %v: %v.", c.Name, err)
+			break
+		} else if !allowed {
+			message.SetContentf("Only %v instructors can schedule %v classes.", c.InstructorRole, c.Name)
+			break
+		}
+
+		minutes, ok := data.OptInt("minutes")
+		if !ok {
+			minutes = defaultClassMinutes
+		}
+		slots, ok := data.OptInt("slots")
+		if !ok {
+			slots = c.Capacity
+		}
+
+		session, warnings, err := scheduleClassSession(event.Client(), classSession{
+			GuildID:      *event.GuildID(),
+			Course:       c.Key,
+			InstructorID: event.User().ID,
+			Start:        start,
+			End:          start.Add(time.Duration(minutes) * time.Minute),
+			Slots:        slots,
+		})
+		if err != nil {
+			slog.Error("error while scheduling class", slog.Any("err", err))
+			message.SetContentf("Couldn't schedule the class: %v.", err)
+			break
+		}
+
+		content := fmt.Sprintf("Scheduled class #%d, invited %d students and waitlisted %d.", session.ID, len(session.Students), len(session.Waitlist))
+		if len(warnings) > 0 {
+			content += "\nHowever I " + strings.Join(warnings, ", ") + "."
+		}
+		message.SetContent(content).SetEmbeds(classSessionEmbed(session))
+	case "cancel":
+		session, err := findClassSession(data.Int("class"))
+		if errors.Is(err, errClassSessionNotFound) {
+			message.SetContentf("There's no class #%d.", data.Int("class"))
+			break
+		} else if err != nil {
+			slog.Error("error while reading class", slog.Any("err", err))
+			message.SetContentf("Couldn't read the class: %v.", err)
+			break
+		}
+
+		c, _ := findCourse(session.Course)
+		if allowed, err := canTeachCourse(event.Client(), *event.GuildID(), event.Member(), c); err != nil {
+			slog.Error("error while checking instructor role", slog.Any("err", err))
+			message.SetContentf("Couldn't check whether you teach %v: %v.", c.Name, err)
+			break
+		} else if !allowed {
+			message.SetContentf("Only %v instructors can cancel %v classes.", c.InstructorRole, c.Name)
+			break
+		}
+
+		session, warnings, err := cancelClassSession(event.Client(), session.ID)
+		if err != nil {
+			message.SetContentf("Couldn't cancel the class: %v.", err)
+			break
+		}
+
+		content := fmt.Sprintf("Cancelled class #%d, its students are back to waiting for the next %v class.", session.ID, c.Name)
+		if len(warnings) > 0 {
+			content += "\nHowever I " + strings.Join(warnings, ", ") + "."
+		}
+		message.SetContent(content)
+	case "list":
+		sessions, err := listClassSessions(time.Now().UTC())
+		if err != nil {
+			slog.Error("error while listing classes", slog.Any("err", err))
+			message.SetContentf("Couldn't list the classes: %v.", err)
+			break
+		}
+
+		lines := make([]string, 0, len(sessions))
+		for _, session := range sessions {
+			c, _ := findCourse(session.Course)
+			lines = append(lines, fmt.Sprintf("**#%d %v** <t:%d:F> - %d/%d students, %d waitlisted", session.ID, c.Name, session.Start.Unix(), len(session.Students), session.Slots, len(session.Waitlist)))
+		}
+
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Upcoming Classes").
+			SetDescription(orNone(strings.Join(lines, "\n"))).
+			Build(),
+		)
+	default:
+		return
+	}
+
+	if _, err := event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), message.Build()); err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
@@ -0,0 +1,464 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const classDeclineCustomID = "class-decline"
+
+// Class times are entered in UTC
+const classTimeLayout = "2006-01-02 15:04"
+
+var classLocation = envString("class_location", "Training server")
+
+// classReminders are how long before a class its students are reminded,
+// furthest first
+var classReminders = []time.Duration{24 * time.Hour, time.Hour}
+
+var errClassSessionNotFound = errors.New("there's no class with that number")
+
+// classSession is a class an instructor scheduled for a course. Students are
+// the members invited to it and Waitlist those who get a slot when a student
+// drops out, both in the order they asked. EventID is the Discord scheduled
+// event for it and RemindersSent how many of classReminders went out.
+type classSession struct {
+	ID            int            `json:"id"`
+	GuildID       snowflake.ID   `json:"guild_id"`
+	Course        string         `json:"course"`
+	InstructorID  snowflake.ID   `json:"instructor_id"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Slots         int            `json:"slots"`
+	Students      []snowflake.ID `json:"students"`
+	Waitlist      []snowflake.ID `json:"waitlist"`
+	EventID       snowflake.ID   `json:"event_id,omitempty"`
+	RemindersSent int            `json:"reminders_sent"`
+	Cancelled     bool           `json:"cancelled,omitempty"`
+}
+
+var classGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client := event.Client()
+	startPeriodicTask("class-reminders", 10*time.Minute, func() {
+		now := time.Now().UTC()
+		sendClassReminders(client, now)
+		releaseClassWaitlists(client, now)
+	})
+})
+
+var classDeclineEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if !strings.HasPrefix(event.Data.CustomID(), classDeclineCustomID+":") {
+		return
+	}
+
+	sessionID, err := strconv.Atoi(strings.TrimPrefix(event.Data.CustomID(), classDeclineCustomID+":"))
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	content := ""
+	session, promoted, err := declineClassSession(sessionID, event.User().ID, time.Now().UTC())
+	if err != nil {
+		content = fmt.Sprintf("Couldn't take you out of the class: %v.", err)
+	} else {
+		c, _ := findCourse(session.Course)
+		content = fmt.Sprintf("You've been taken out of the %v class, your request stays open for the next one.", c.Name)
+	}
+
+	if promoted != 0 {
+		if err := sendClassInvite(event.Client(), session, promoted); err != nil {
+			slog.Error("error while inviting member from the waitlist", slog.Any("err", err), slog.Int("session", session.ID))
+		}
+	}
+
+	err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		ClearContainerComponents().
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating message", slog.Any("err", err))
+	}
+})
+
+func (s classSession) has(memberID snowflake.ID) bool {
+	return slices.Contains(s.Students, memberID) || slices.Contains(s.Waitlist, memberID)
+}
+
+// add invites the member when there's a slot left and waitlists them when
+// there isn't.
+func (s *classSession) add(memberID snowflake.ID) {
+	if len(s.Students) < s.Slots {
+		s.Students = append(s.Students, memberID)
+	} else {
+		s.Waitlist = append(s.Waitlist, memberID)
+	}
+}
+
+// remindersDue is how many of classReminders should have gone out by now.
+func (s classSession) remindersDue(now time.Time) int {
+	due := 0
+	for due < len(classReminders) && !now.Before(s.Start.Add(-classReminders[due])) {
+		due++
+	}
+
+	return due
+}
+
+// canTeachCourse reports whether the member holds the course's instructor
+// role. Staff can always schedule classes.
+func canTeachCourse(client bot.Client, guildID snowflake.ID, member *discord.ResolvedMember, c course) (bool, error) {
+	if member == nil {
+		return false, nil
+	}
+	if isStaff(member) {
+		return true, nil
+	}
+
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return false, err
+	}
+
+	roleID, ok := roleIDs[c.InstructorRole]
+	return ok && slices.Contains(member.RoleIDs, roleID), nil
+}
+
+// scheduleClassSession schedules the class, invites everyone waiting for the
+// course in the order they asked and waitlists those who don't fit. It creates
+// a Discord scheduled event for the class and returns warnings for what
+// couldn't be done along the way.
+func scheduleClassSession(client bot.Client, session classSession) (classSession, []string, error) {
+	c, ok := findCourse(session.Course)
+	if !ok {
+		return session, nil, fmt.Errorf("unknown course %q", session.Course)
+	}
+
+	now := time.Now().UTC()
+	if !session.Start.After(now) {
+		return session, nil, errors.New("the class has to start in the future")
+	}
+	if !session.End.After(session.Start) {
+		return session, nil, errors.New("the class has to run for at least a minute")
+	}
+	if session.Slots <= 0 {
+		return session, nil, errors.New("the class needs at least one slot")
+	}
+
+	// Reminders already due would only repeat the invite
+	session.RemindersSent = session.remindersDue(now)
+	err := school.Update(func(data *schoolData) error {
+		session.ID = data.NextSessionID
+		data.NextSessionID++
+
+		for _, i := range data.pendingRequests(session.Course) {
+			session.add(data.Requests[i].MemberID)
+			data.Requests[i].SessionID = session.ID
+		}
+
+		delete(data.DemandNotified, session.Course)
+		data.Sessions = append(data.Sessions, session)
+		return nil
+	})
+	if err != nil {
+		return session, nil, err
+	}
+
+	var warnings []string
+	event, err := client.Rest().CreateGuildScheduledEvent(session.GuildID, discord.GuildScheduledEventCreate{
+		Name:               c.Name,
+		Description:        fmt.Sprintf("%v\n%d slots, sign up with the Schools & Courses button.", c.Description, session.Slots),
+		PrivacyLevel:       discord.ScheduledEventPrivacyLevelGuildOnly,
+		ScheduledStartTime: session.Start,
+		ScheduledEndTime:   &session.End,
+		EntityType:         discord.ScheduledEventEntityTypeExternal,
+		EntityMetaData:     &discord.EntityMetaData{Location: classLocation},
+	})
+	if err != nil {
+		slog.Error("error while creating scheduled event", slog.Any("err", err), slog.Int("session", session.ID))
+		warnings = append(warnings, fmt.Sprintf("couldn't create the scheduled event: %v", err))
+	} else {
+		if session, err = updateClassSession(session.ID, func(s *classSession) error {
+			s.EventID = event.ID
+			return nil
+		}); err != nil {
+			return session, warnings, err
+		}
+	}
+
+	for _, memberID := range session.Students {
+		if err = sendClassInvite(client, session, memberID); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't invite <@%v>: %v", memberID, err))
+		}
+	}
+
+	for i, memberID := range session.Waitlist {
+		content := fmt.Sprintf("A %v class was scheduled for <t:%d:F> but it's full, you're number %d on the waitlist. You'll get an invite if a slot opens up.", c.Name, session.Start.Unix(), i+1)
+		if err = sendDirectMessage(client, memberID, content); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't tell <@%v> they're waitlisted: %v", memberID, err))
+		}
+	}
+
+	return session, warnings, nil
+}
+
+func findClassSession(id int) (classSession, error) {
+	var session classSession
+	err := school.View(func(data *schoolData) {
+		if i := data.findSession(id); i >= 0 {
+			session = data.Sessions[i]
+		}
+	})
+
+	if err == nil && session.ID == 0 {
+		err = errClassSessionNotFound
+	}
+	return session, err
+}
+
+func updateClassSession(id int, fn func(s *classSession) error) (classSession, error) {
+	var updated classSession
+	err := school.Update(func(data *schoolData) error {
+		i := data.findSession(id)
+		if i < 0 {
+			return errClassSessionNotFound
+		}
+
+		if err := fn(&data.Sessions[i]); err != nil {
+			return err
+		}
+		updated = data.Sessions[i]
+		return nil
+	})
+
+	return updated, err
+}
+
+// declineClassSession takes the member out of the class, giving their slot
+// to the first member on the waitlist, who is returned as promoted. Their
+// request goes back to waiting for the next class.
+func declineClassSession(id int, memberID snowflake.ID, now time.Time) (classSession, snowflake.ID, error) {
+	var promoted snowflake.ID
+	var session classSession
+	err := school.Update(func(data *schoolData) error {
+		i := data.findSession(id)
+		if i < 0 {
+			return errClassSessionNotFound
+		}
+
+		s := &data.Sessions[i]
+		if s.Cancelled || !now.Before(s.Start) {
+			return errors.New("the class has already started or was cancelled")
+		}
+
+		if index := slices.Index(s.Students, memberID); index >= 0 {
+			s.Students = slices.Delete(s.Students, index, index+1)
+			if len(s.Waitlist) > 0 {
+				promoted = s.Waitlist[0]
+				s.Students = append(s.Students, promoted)
+				s.Waitlist = s.Waitlist[1:]
+			}
+		} else if index = slices.Index(s.Waitlist, memberID); index >= 0 {
+			s.Waitlist = slices.Delete(s.Waitlist, index, index+1)
+		} else {
+			return errors.New("you aren't in that class")
+		}
+
+		for j, request := range data.Requests {
+			if request.SessionID == id && request.MemberID == memberID {
+				data.Requests[j].SessionID = 0
+			}
+		}
+
+		session = *s
+		return nil
+	})
+
+	return session, promoted, err
+}
+
+// cancelClassSession cancels the class and its scheduled event, putting the
+// requests of everyone in it back to waiting for the next class. It returns
+// warnings for the members who couldn't be told.
+func cancelClassSession(client bot.Client, id int) (classSession, []string, error) {
+	session, err := updateClassSession(id, func(s *classSession) error {
+		if s.Cancelled {
+			return errors.New("the class was already cancelled")
+		}
+
+		s.Cancelled = true
+		return nil
+	})
+	if err != nil {
+		return session, nil, err
+	}
+
+	err = school.Update(func(data *schoolData) error {
+		for i, request := range data.Requests {
+			if request.SessionID == id {
+				data.Requests[i].SessionID = 0
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return session, nil, err
+	}
+
+	var warnings []string
+	if session.EventID != 0 {
+		if err = client.Rest().DeleteGuildScheduledEvent(session.GuildID, session.EventID); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't delete the scheduled event: %v", err))
+		}
+	}
+
+	c, _ := findCourse(session.Course)
+	content := fmt.Sprintf("The %v class on <t:%d:F> was cancelled, your request stays open for the next one.", c.Name, session.Start.Unix())
+	for _, memberID := range slices.Concat(session.Students, session.Waitlist) {
+		if err = sendDirectMessage(client, memberID, content); err != nil {
+			warnings = append(warnings, fmt.Sprintf("couldn't tell <@%v>: %v", memberID, err))
+		}
+	}
+
+	return session, warnings, nil
+}
+
+func sendClassInvite(client bot.Client, session classSession, memberID snowflake.ID) error {
+	c, _ := findCourse(session.Course)
+	return sendDirectMessageCreate(client, memberID, discord.NewMessageCreateBuilder().
+		SetContentf("You're invited to the %v class you asked for. If you can't make it, let someone on the waitlist have your slot.", c.Name).
+		SetEmbeds(classSessionEmbed(session)).
+		AddActionRow(discord.NewDangerButton("Can't Make It", fmt.Sprintf("%v:%d", classDeclineCustomID, session.ID))).
+		Build(),
+	)
+}
+
+// sendClassReminders reminds the students of every class coming up that a
+// reminder is due for.
+func sendClassReminders(client bot.Client, now time.Time) {
+	var due []classSession
+	err := school.Update(func(data *schoolData) error {
+		for i, session := range data.Sessions {
+			if session.Cancelled || !now.Before(session.Start) {
+				continue
+			}
+
+			if reminders := session.remindersDue(now); reminders > session.RemindersSent {
+				data.Sessions[i].RemindersSent = reminders
+				due = append(due, data.Sessions[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error while checking class reminders", slog.Any("err", err))
+		return
+	}
+
+	for _, session := range due {
+		c, _ := findCourse(session.Course)
+		content := fmt.Sprintf("Reminder: your %v class starts <t:%d:R> at <t:%d:t>, %v.", c.Name, session.Start.Unix(), session.Start.Unix(), classLocation)
+		for _, memberID := range session.Students {
+			if err = sendDirectMessage(client, memberID, content); err != nil {
+				slog.Error("error while sending class reminder", slog.Any("err", err), slog.Int("session", session.ID), slog.Any("member", memberID))
+			}
+		}
+	}
+}
+
+// releaseClassWaitlists puts the requests of everyone still waitlisted for a
+// class that started back to waiting for the next one, so they're invited
+// when it's scheduled. S4 is told again if that's enough to run another class.
+func releaseClassWaitlists(client bot.Client, now time.Time) {
+	var released []courseRequestRecord
+	guildIDs := map[string]snowflake.ID{}
+	err := school.Update(func(data *schoolData) error {
+		for i, request := range data.Requests {
+			if request.SessionID == 0 {
+				continue
+			}
+
+			j := data.findSession(request.SessionID)
+			if j < 0 || data.Sessions[j].Cancelled || now.Before(data.Sessions[j].Start) || !slices.Contains(data.Sessions[j].Waitlist, request.MemberID) {
+				continue
+			}
+
+			data.Requests[i].SessionID = 0
+			released = append(released, request)
+			guildIDs[request.Course] = data.Sessions[j].GuildID
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error while releasing class waitlists", slog.Any("err", err))
+		return
+	}
+
+	for _, request := range released {
+		c, _ := findCourse(request.Course)
+		content := fmt.Sprintf("The %v class started without a slot opening up for you, your request stays open for the next one.", c.Name)
+		if err = sendDirectMessage(client, request.MemberID, content); err != nil {
+			slog.Error("error while telling waitlisted member", slog.Any("err", err), slog.Any("member", request.MemberID))
+		}
+	}
+
+	for courseKey, guildID := range guildIDs {
+		c, _ := findCourse(courseKey)
+		if err = notifyCourseDemand(client, guildID, c); err != nil {
+			slog.Error("error while notifying S4 of course demand", slog.Any("err", err), slog.String("course", c.Key))
+		}
+	}
+}
+
+// listClassSessions returns the classes that haven't started and weren't
+// cancelled, soonest first.
+func listClassSessions(now time.Time) ([]classSession, error) {
+	var sessions []classSession
+	err := school.View(func(data *schoolData) {
+		for _, session := range data.Sessions {
+			if !session.Cancelled && now.Before(session.Start) {
+				sessions = append(sessions, session)
+			}
+		}
+	})
+
+	slices.SortFunc(sessions, func(a, b classSession) int {
+		return a.Start.Compare(b.Start)
+	})
+	return sessions, err
+}
+
+func classSessionEmbed(session classSession) discord.Embed {
+	c, _ := findCourse(session.Course)
+	students := make([]string, 0, len(session.Students))
+	for _, memberID := range session.Students {
+		students = append(students, fmt.Sprintf("<@%v>", memberID))
+	}
+
+	waitlist := make([]string, 0, len(session.Waitlist))
+	for i, memberID := range session.Waitlist {
+		waitlist = append(waitlist, fmt.Sprintf("%d. <@%v>", i+1, memberID))
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Class #%d - %v", session.ID, c.Name).
+		SetDescription(c.Description).
+		AddField("When", fmt.Sprintf("<t:%d:F> to <t:%d:t>", session.Start.Unix(), session.End.Unix()), false).
+		AddField("Instructor", fmt.Sprintf("<@%v>", session.InstructorID), true).
+		AddField("Where", classLocation, true).
+		AddField(fmt.Sprintf("Students (%d/%d)", len(session.Students), session.Slots), truncateField(orNone(strings.Join(students, ", "))), false).
+		AddField("Waitlist", truncateField(orNone(strings.Join(waitlist, "\n"))), false).
+		Build()
+}
@@ -0,0 +1,42 @@
+package perscom_events
+
+import (
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+)
+
+type SlashCommandHandler struct {
+	Command        discord.SlashCommandCreate
+	EventListeners []bot.EventListener
+}
+
+var commandCatalog = []SlashCommandHandler{
+	blingBucksCommand,
+	profileCommand,
+	rosterCommand,
+	promoteCommand,
+	auditNicknamesCommand,
+	reconcileRolesCommand,
+	whitelistCommand,
+	attendanceCommand,
+	accountabilityDigestCommand,
+	orbatCommand,
+	awardCommand,
+	ceremonyCommand,
+	milestonesCommand,
+	operationCommand,
+	campaignCommand,
+	classCommand,
+	availabilityCommand,
+	dischargeCommand,
+}
+
+func GetSlashCommandHandlers() []SlashCommandHandler {
+	return commandCatalog
+}
+
+// isStaff reports whether the member invoking an interaction may use staff
+// only actions.
+func isStaff(member *discord.ResolvedMember) bool {
+	return member != nil && member.Permissions.Has(discord.PermissionManageRoles)
+}
@@ -0,0 +1,43 @@
+package perscom_events
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func envString(name string, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func envInt(name string, fallback int) int {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Error("invalid integer in environment", slog.String("name", name), slog.Any("err", err))
+		return fallback
+	}
+
+	return parsed
+}
+
+// envList reads a comma separated list, skipping empty entries.
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
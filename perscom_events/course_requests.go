@@ -0,0 +1,275 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	s4ChannelName         = envString("s4_channel", "s4")
+	courseDemandThreshold = envInt("course_demand_threshold", 5)
+)
+
+// How many of the best class times are shown to S4
+const bestClassTimesShown = 5
+
+var errCourseAlreadyRequested = errors.New("you've already requested that course")
+
+// courseRequestRecord is a member asking to take a course. SessionID is the
+// class they were invited to or waitlisted for, zero while they're waiting for
+// one to be scheduled.
+type courseRequestRecord struct {
+	ID           int          `json:"id"`
+	Course       string       `json:"course"`
+	MemberID     snowflake.ID `json:"member_id"`
+	Availability string       `json:"availability,omitempty"`
+	Submitted    time.Time    `json:"submitted"`
+	SessionID    int          `json:"session_id,omitempty"`
+}
+
+// schoolData holds the course requests and the classes scheduled for them.
+// DemandNotified has the courses S4 was told have enough requests waiting,
+// until a class is scheduled for them.
+type schoolData struct {
+	NextRequestID  int                   `json:"next_request_id"`
+	Requests       []courseRequestRecord `json:"requests"`
+	DemandNotified map[string]time.Time  `json:"demand_notified"`
+	NextSessionID  int                   `json:"next_session_id"`
+	Sessions       []classSession        `json:"sessions"`
+}
+
+var school = newJSONStore("school", func() schoolData {
+	return schoolData{NextRequestID: 1, DemandNotified: map[string]time.Time{}, NextSessionID: 1}
+})
+
+// pendingRequests returns the indices of the requests for the course still
+// waiting for a class, oldest first.
+func (d *schoolData) pendingRequests(courseKey string) []int {
+	var pending []int
+	for i, request := range d.Requests {
+		if request.Course == courseKey && request.SessionID == 0 {
+			pending = append(pending, i)
+		}
+	}
+
+	slices.SortStableFunc(pending, func(a, b int) int {
+		return d.Requests[a].Submitted.Compare(d.Requests[b].Submitted)
+	})
+	return pending
+}
+
+// upcomingSession returns the index of the next class for the course that
+// hasn't started or been cancelled, or -1 when there isn't one.
+func (d *schoolData) upcomingSession(courseKey string, now time.Time) int {
+	next := -1
+	for i, session := range d.Sessions {
+		if session.Course != courseKey || session.Cancelled || !now.Before(session.Start) {
+			continue
+		}
+
+		if next == -1 || session.Start.Before(d.Sessions[next].Start) {
+			next = i
+		}
+	}
+
+	return next
+}
+
+func (d *schoolData) findSession(id int) int {
+	return slices.IndexFunc(d.Sessions, func(session classSession) bool {
+		return session.ID == id
+	})
+}
+
+// submitCourseRequest stores the request. The member is put straight into
+// the next class for the course when one is scheduled, or on its waitlist when
+// it's full. Otherwise they wait for one to be scheduled and S4 is told once
+// enough members are waiting. It returns the class they were placed in, ok
+// being false when there wasn't one.
+func submitCourseRequest(client bot.Client, guildID snowflake.ID, request courseRequestRecord) (classSession, bool, error) {
+	c, ok := findCourse(request.Course)
+	if !ok {
+		return classSession{}, false, fmt.Errorf("unknown course %q", request.Course)
+	}
+
+	var session classSession
+	placed := false
+	now := time.Now().UTC()
+	err := school.Update(func(data *schoolData) error {
+		for _, existing := range data.Requests {
+			if existing.Course != request.Course || existing.MemberID != request.MemberID {
+				continue
+			}
+
+			if existing.SessionID == 0 {
+				return errCourseAlreadyRequested
+			}
+			if i := data.findSession(existing.SessionID); i >= 0 && !data.Sessions[i].Cancelled && now.Before(data.Sessions[i].Start) && data.Sessions[i].has(request.MemberID) {
+				return errCourseAlreadyRequested
+			}
+		}
+
+		request.ID = data.NextRequestID
+		request.Submitted = now
+		data.NextRequestID++
+
+		if i := data.upcomingSession(request.Course, now); i >= 0 {
+			data.Sessions[i].add(request.MemberID)
+			request.SessionID = data.Sessions[i].ID
+			session, placed = data.Sessions[i], true
+		}
+
+		data.Requests = append(data.Requests, request)
+		return nil
+	})
+	if err != nil {
+		return session, placed, err
+	}
+
+	// The request is in either way, S4 can still find it with /class demand
+	if !placed {
+		if err = notifyCourseDemand(client, guildID, c); err != nil {
+			slog.Error("error while notifying S4 of course demand", slog.Any("err", err), slog.String("course", c.Key))
+		}
+	} else if slices.Contains(session.Students, request.MemberID) {
+		if err = sendClassInvite(client, session, request.MemberID); err != nil {
+			slog.Error("error while sending class invite", slog.Any("err", err), slog.Int("session", session.ID))
+		}
+	}
+
+	return session, placed, nil
+}
+
+// notifyCourseDemand tells S4 when enough members are waiting for a class,
+// once until a class is scheduled for the course.
+func notifyCourseDemand(client bot.Client, guildID snowflake.ID, c course) error {
+	var pending []courseRequestRecord
+	err := school.Update(func(data *schoolData) error {
+		if _, notified := data.DemandNotified[c.Key]; notified {
+			return nil
+		}
+
+		indices := data.pendingRequests(c.Key)
+		if len(indices) < courseDemandThreshold {
+			return nil
+		}
+
+		for _, i := range indices {
+			pending = append(pending, data.Requests[i])
+		}
+
+		if data.DemandNotified == nil {
+			data.DemandNotified = map[string]time.Time{}
+		}
+		data.DemandNotified[c.Key] = time.Now().UTC()
+		return nil
+	})
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	err = postCourseDemand(client, guildID, c, pending)
+	if err != nil {
+		// Try again with the next request
+		_ = school.Update(func(data *schoolData) error {
+			delete(data.DemandNotified, c.Key)
+			return nil
+		})
+	}
+
+	return err
+}
+
+func postCourseDemand(client bot.Client, guildID snowflake.ID, c course, pending []courseRequestRecord) error {
+	channelID, err := findGuildChannel(client, guildID, s4ChannelName)
+	if err != nil {
+		return err
+	}
+
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return err
+	}
+
+	times, err := bestClassTimes(pending, 0, defaultClassMinutes*time.Minute, time.Now().UTC())
+	if err != nil {
+		slog.Error("error while ranking class times", slog.Any("err", err), slog.String("course", c.Key))
+	}
+
+	content := fmt.Sprintf("%d members are waiting for a %v class.", len(pending), c.Name)
+	if roleID, ok := roleIDs[c.InstructorRole]; ok {
+		content = fmt.Sprintf("<@&%v> %v", roleID, content)
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetContent(content).
+		SetEmbeds(courseDemandEmbed(c, pending, times)).
+		Build(),
+	)
+
+	return err
+}
+
+func courseDemandEmbed(c course, pending []courseRequestRecord, times []sessionTime) discord.Embed {
+	lines := make([]string, 0, len(pending))
+	for _, request := range pending {
+		line := fmt.Sprintf("<@%v> <t:%d:d>", request.MemberID, request.Submitted.Unix())
+		if request.Availability != "" {
+			line += " - " + request.Availability
+		}
+		lines = append(lines, line)
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Course Demand - %v", c.Name).
+		SetDescriptionf("Schedule a class with `/class schedule`, everyone waiting is invited in the order they asked up to %d students and the rest are waitlisted. `/class times` matches the best times against an instructor's availability.", c.Capacity).
+		AddField("Waiting", truncateField(orNone(strings.Join(lines, "\n"))), false).
+		AddField("Best Times", truncateField(orNone(formatSessionTimes(times, len(pending)))), false).
+		Build()
+}
+
+// bestClassTimes ranks the weekly times to hold a class at by how many of the
+// members waiting for it are free, against the instructor's availability when
+// there is one.
+func bestClassTimes(pending []courseRequestRecord, instructorID snowflake.ID, length time.Duration, now time.Time) ([]sessionTime, error) {
+	memberIDs := make([]snowflake.ID, 0, len(pending))
+	for _, request := range pending {
+		memberIDs = append(memberIDs, request.MemberID)
+	}
+
+	attendees, err := memberAvailabilities(memberIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var instructor memberAvailability
+	if instructorID != 0 {
+		if instructor, err = getMemberAvailability(instructorID); err != nil {
+			return nil, err
+		}
+	}
+
+	return rankSessionTimes(instructor, attendees, length, now, bestClassTimesShown), nil
+}
+
+// courseDemand lists the requests waiting for a class for every course.
+func courseDemand() (map[string][]courseRequestRecord, error) {
+	demand := map[string][]courseRequestRecord{}
+	err := school.View(func(data *schoolData) {
+		for _, c := range courses {
+			for _, i := range data.pendingRequests(c.Key) {
+				demand[c.Key] = append(demand[c.Key], data.Requests[i])
+			}
+		}
+	})
+
+	return demand, err
+}
@@ -0,0 +1,141 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/discord"
+	"strings"
+	"time"
+)
+
+// course is a school or course taught by S4. Name is also the qualification
+// members get on the roster for passing it. Students need every one of
+// Prerequisites and at least MinimumRank, InstructorRole is the Discord role
+// of those who teach it and Capacity how many students a class takes. A
+// Validity of zero means the qualification never runs out, otherwise it has to
+// be renewed by taking the course again.
+type course struct {
+	Key            string
+	Name           string
+	Description    string
+	Prerequisites  []string
+	MinimumRank    string
+	InstructorRole string
+	Capacity       int
+	Validity       time.Duration
+}
+
+var courses = []course{
+	{"airborne", "Airborne", "Static line and HALO parachute insertion.", nil, "", "Airborne Instructor", 12, 0},
+	{"air-assault", "Air Assault", "Helicopter insertion, extraction and sling loading.", nil, "PFC", "Air Assault Instructor", 10, 0},
+	{"ait", "Advanced Infantry Training", "Fireteam tactics, weapon systems and land navigation.", nil, "", "AIT Instructor", 10, 0},
+	{"ranger", "Ranger School", "Small unit tactics for raids, ambushes and reconnaissance.", []string{"Airborne", "Advanced Infantry Training"}, "PFC", "Ranger Instructor", 8, 0},
+	{"cls", "Combat Life Saver", "Treating and evacuating casualties under fire.", nil, "", "Medical Instructor", 10, 365 * day},
+	{"drill-instructor", "Drill Instructor Course", "Running basic training for new recruits.", []string{"NCO Training & Leadership"}, "SGT", "Drill Instructor", 6, 0},
+	{"nco", "NCO Training & Leadership", "Leading a fireteam or squad and the duties of an NCO.", []string{"Advanced Infantry Training"}, "CPL", "NCO Academy Instructor", 8, 0},
+	{"sdm", "Squad Designated Marksman (SDM)", "Engaging targets at range in support of the squad.", []string{"Advanced Infantry Training"}, "PFC", "Marksmanship Instructor", 6, 0},
+	{"eod", "Explosive Ordnance Disposal (EOD)", "Finding and disposing of mines, IEDs and unexploded ordnance.", []string{"Advanced Infantry Training"}, "SPC", "EOD Instructor", 6, 0},
+}
+
+func findCourse(key string) (course, bool) {
+	for _, c := range courses {
+		if c.Key == key {
+			return c, true
+		}
+	}
+
+	return course{}, false
+}
+
+func findCourseByName(name string) (course, bool) {
+	for _, c := range courses {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+
+	return course{}, false
+}
+
+// requirements describes what it takes to sign up for the course.
+func (c course) requirements() string {
+	var requirements []string
+	if c.MinimumRank != "" {
+		requirements = append(requirements, c.MinimumRank+"+")
+	}
+	requirements = append(requirements, c.Prerequisites...)
+
+	if len(requirements) == 0 {
+		return "No prerequisites"
+	}
+	return "Requires " + strings.Join(requirements, ", ")
+}
+
+// hasCurrentQualification reports whether the member holds the qualification
+// and, when it comes from a course whose qualification runs out, got it
+// recently enough for it to still count.
+func (m rosterMember) hasCurrentQualification(name string, now time.Time) bool {
+	var validity time.Duration
+	if c, ok := findCourseByName(name); ok {
+		validity = c.Validity
+	}
+
+	for _, qualification := range m.Qualifications {
+		if qualification.Name == name && (validity == 0 || now.Before(qualification.Awarded.Add(validity))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// missingCourseRequirements lists the rank and prerequisites the member is
+// missing to take the course, nothing meaning they can sign up.
+func missingCourseRequirements(member rosterMember, c course, now time.Time) []string {
+	var missing []string
+	if c.MinimumRank != "" && !rankAtLeast(member.Rank, c.MinimumRank) {
+		minimum, _, _ := findRank(c.MinimumRank)
+		missing = append(missing, fmt.Sprintf("The rank of %v or above", minimum.Name))
+	}
+
+	for _, prerequisite := range c.Prerequisites {
+		if member.hasCurrentQualification(prerequisite, now) {
+			continue
+		}
+
+		if member.hasQualification(prerequisite) {
+			missing = append(missing, fmt.Sprintf("%v, yours has run out and needs renewing", prerequisite))
+		} else {
+			missing = append(missing, prerequisite)
+		}
+	}
+
+	return missing
+}
+
+func courseChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(courses))
+	for _, c := range courses {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: c.Name, Value: c.Key})
+	}
+
+	return choices
+}
+
+func courseSelectOptions() []discord.StringSelectMenuOption {
+	options := make([]discord.StringSelectMenuOption, 0, len(courses))
+	for _, c := range courses {
+		options = append(options, discord.NewStringSelectMenuOption(c.Name, c.Key).WithDescription(selectOptionDescription(c.requirements())))
+	}
+
+	return options
+}
+
+// courseEmbedFields describes each course along with its prerequisites.
+func courseEmbedFields() []discord.EmbedField {
+	fields := make([]discord.EmbedField, 0, len(courses))
+	for _, c := range courses {
+		fields = append(fields, discord.EmbedField{Name: c.Name, Value: c.Description + "\n" + c.requirements() + "."})
+	}
+
+	return fields
+}
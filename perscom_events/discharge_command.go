@@ -0,0 +1,105 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const dischargeCommandName = "discharge"
+
+const maxEmbedDescriptionLength = 4096
+
+var dischargeCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        dischargeCommandName,
+		Description: "Manage pending discharges (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "pending",
+				Description: "List the discharges waiting to be processed",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "cancel",
+				Description: "Drop a member's pending discharge without discharging them",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member whose discharge to drop", Required: true},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{dischargeCommandEventListener},
+}
+
+var dischargeCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != dischargeCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	if !isStaff(event.Member()) {
+		message.SetContent("Only staff can do that.")
+	} else {
+		switch *data.SubCommandName {
+		case "pending":
+			pending, err := listPendingDischarges()
+			if err != nil {
+				slog.Error("error while reading discharges", slog.Any("err", err))
+				message.SetContentf("Couldn't read the pending discharges: %v.", err)
+				break
+			}
+
+			memberIDs := slices.SortedFunc(maps.Keys(pending), func(a, b snowflake.ID) int {
+				return pending[a].Submitted.Compare(pending[b].Submitted)
+			})
+
+			lines := make([]string, 0, len(memberIDs))
+			for _, memberID := range memberIDs {
+				discharge := pending[memberID]
+				line := fmt.Sprintf("<@%v> requested <t:%d:R>", memberID, discharge.Submitted.Unix())
+				if discharge.StartedBy != 0 {
+					line = fmt.Sprintf("<@%v> started by <@%v> <t:%d:R>", memberID, discharge.StartedBy, discharge.Submitted.Unix())
+				}
+				if discharge.Statement != "" {
+					line += "\n> " + strings.ReplaceAll(discharge.Statement, "\n", " ")
+				}
+				lines = append(lines, line)
+			}
+
+			message.SetEmbeds(discord.NewEmbedBuilder().
+				SetColor(0xFF0000).
+				SetTitle("Pending Discharges").
+				SetDescription(truncateText(orNone(strings.Join(lines, "\n")), maxEmbedDescriptionLength)).
+				Build(),
+			)
+		case "cancel":
+			user := data.User("member")
+			ok, err := resolveDischarge(user.ID)
+			if err != nil {
+				slog.Error("error while cancelling discharge", slog.Any("err", err))
+				message.SetContentf("Couldn't cancel the discharge: %v.", err)
+			} else if !ok {
+				message.SetContentf("%v doesn't have a pending discharge.", user.Mention())
+			} else {
+				message.SetContentf("Cancelled the pending discharge of %v.", user.Mention())
+			}
+		default:
+			return
+		}
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
@@ -2,10 +2,15 @@ package perscom_events
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
 	"log/slog"
+	"maps"
+	"time"
 )
 
 const dischargeRequestCustomID = "discharge-request"
@@ -16,6 +21,21 @@ const dischargeRequestStatementSubmitModalCustomID = "discharge-request-statemen
 //go:embed discharge_request_description.txt
 var dischargeRequestDescription string
 
+// dischargeRecord is a pending discharge. StartedBy is set when staff started
+// the discharge instead of the member asking for it.
+type dischargeRecord struct {
+	Submitted time.Time    `json:"submitted"`
+	Statement string       `json:"statement,omitempty"`
+	StartedBy snowflake.ID `json:"started_by,omitempty"`
+}
+
+// dischargeStore holds the discharge requests that command staff haven't
+// processed yet, keyed by member. They're resolved when the member is marked
+// discharged on the roster or staff cancel them with /discharge cancel.
+var dischargeStore = newJSONStore("discharges", func() map[snowflake.ID]dischargeRecord {
+	return map[snowflake.ID]dischargeRecord{}
+})
+
 var dischargeRequest = ButtonEventHandler{
 	discord.NewDangerButton("Discharge", dischargeRequestCustomID),
 	[]bot.EventListener{dischargeRequestEventHandler,
@@ -51,11 +71,19 @@ var dischargeRequestEventHandler = bot.NewListenerFunc(func(event *events.Compon
 
 var dischargeRequestWithoutStatementEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == dischargeRequestWithoutStatementCustomID {
-		// TODO: Submit discharge request without statement
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		content := "Submitted your discharge request."
+		err := recordDischargeRequest(event.User().ID, "")
+		if err != nil {
+			slog.Error("error while recording discharge request", slog.Any("err", err))
+			content = fmt.Sprintf("Couldn't submit your discharge request: %v.", err)
+		} else {
+			go notifyChainOfCommand(event.Client(), event.User().ID, "submitted a discharge request")
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearContainerComponents().
 			ClearEmbeds().
-			SetContent("Submitted your discharge request.").
+			SetContent(content).
 			Build(),
 		)
 
@@ -83,11 +111,19 @@ var dischargeRequestStatementModalEventListener = bot.NewListenerFunc(func(event
 
 var dischargeRequestStatementModalSubmissionEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
 	if event.ModalSubmitInteraction.Data.CustomID == dischargeRequestStatementSubmitModalCustomID {
-		// TODO: Submit discharge request with statement
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		content := "Submitted your discharge request."
+		err := recordDischargeRequest(event.User().ID, event.Data.Text("statement"))
+		if err != nil {
+			slog.Error("error while recording discharge request", slog.Any("err", err))
+			content = fmt.Sprintf("Couldn't submit your discharge request: %v.", err)
+		} else {
+			go notifyChainOfCommand(event.Client(), event.User().ID, "submitted a discharge request")
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearContainerComponents().
 			ClearEmbeds().
-			SetContent("Submitted your discharge request.").
+			SetContent(content).
 			Build(),
 		)
 
@@ -96,3 +132,56 @@ var dischargeRequestStatementModalSubmissionEventListener = bot.NewListenerFunc(
 		}
 	}
 })
+
+func recordDischargeRequest(memberID snowflake.ID, statement string) error {
+	return dischargeStore.Update(func(discharges *map[snowflake.ID]dischargeRecord) error {
+		(*discharges)[memberID] = dischargeRecord{Submitted: time.Now().UTC(), Statement: statement}
+		return nil
+	})
+}
+
+// startDischarge records a discharge staff started on a member's behalf. A
+// discharge the member already asked for is left as is.
+func startDischarge(memberID snowflake.ID, startedBy snowflake.ID, reason string) error {
+	return dischargeStore.Update(func(discharges *map[snowflake.ID]dischargeRecord) error {
+		if _, pending := (*discharges)[memberID]; pending {
+			return errors.New("a discharge is already pending for that member")
+		}
+
+		(*discharges)[memberID] = dischargeRecord{Submitted: time.Now().UTC(), Statement: reason, StartedBy: startedBy}
+		return nil
+	})
+}
+
+func isDischargePending(memberID snowflake.ID) (bool, error) {
+	pending := false
+	err := dischargeStore.View(func(discharges *map[snowflake.ID]dischargeRecord) {
+		_, pending = (*discharges)[memberID]
+	})
+
+	return pending, err
+}
+
+// listPendingDischarges returns the pending discharges keyed by member.
+func listPendingDischarges() (map[snowflake.ID]dischargeRecord, error) {
+	var pending map[snowflake.ID]dischargeRecord
+	err := dischargeStore.View(func(discharges *map[snowflake.ID]dischargeRecord) {
+		pending = maps.Clone(*discharges)
+	})
+
+	return pending, err
+}
+
+// resolveDischarge removes the member's pending discharge, ok being false when
+// they didn't have one.
+func resolveDischarge(memberID snowflake.ID) (bool, error) {
+	ok := false
+	err := dischargeStore.Update(func(discharges *map[snowflake.ID]dischargeRecord) error {
+		if _, ok = (*discharges)[memberID]; ok {
+			delete(*discharges, memberID)
+		}
+		return nil
+	})
+
+	return ok, err
+}
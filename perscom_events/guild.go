@@ -0,0 +1,78 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// findGuildChannel looks a channel up by name, the same way the perscom
+// channel is found when the bot starts.
+func findGuildChannel(client bot.Client, guildID snowflake.ID, name string) (snowflake.ID, error) {
+	channels, err := client.Rest().GetGuildChannels(guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, channel := range channels {
+		if channel.Name() == name {
+			return channel.ID(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("channel %q not found", name)
+}
+
+// getGuildMembers pages through every member of the guild.
+func getGuildMembers(client bot.Client, guildID snowflake.ID) ([]discord.Member, error) {
+	var members []discord.Member
+	var after snowflake.ID
+	for {
+		page, err := client.Rest().GetMembers(guildID, 1000, after)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, page...)
+		if len(page) < 1000 {
+			return members, nil
+		}
+		after = page[len(page)-1].User.ID
+	}
+}
+
+// getGuildRoleIDs maps role names to IDs for every role in the guild.
+func getGuildRoleIDs(client bot.Client, guildID snowflake.ID) (map[string]snowflake.ID, error) {
+	roles, err := client.Rest().GetRoles(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs := make(map[string]snowflake.ID, len(roles))
+	for _, role := range roles {
+		roleIDs[role.Name] = role.ID
+	}
+
+	return roleIDs, nil
+}
+
+// sendDirectMessage opens a DM with the user and sends them the content.
+func sendDirectMessage(client bot.Client, userID snowflake.ID, content string) error {
+	return sendDirectMessageCreate(client, userID, discord.NewMessageCreateBuilder().
+		SetContent(content).
+		Build(),
+	)
+}
+
+// sendDirectMessageCreate is sendDirectMessage for messages with more than
+// content, like buttons.
+func sendDirectMessageCreate(client bot.Client, userID snowflake.ID, message discord.MessageCreate) error {
+	channel, err := client.Rest().CreateDMChannel(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Rest().CreateMessage(channel.ID(), message)
+	return err
+}
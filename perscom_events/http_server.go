@@ -0,0 +1,60 @@
+package perscom_events
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+var (
+	httpAddress             = envString("http_address", ":8080")
+	squadXMLAssetsDirectory = envString("squad_xml_assets_dir", filepath.Join(dataDirectory, "squadxml"))
+)
+
+// NewHTTPServer builds the server hosting the files the bot generates for the
+// website and game servers.
+func NewHTTPServer() *http.Server {
+	if err := regenerateSquadXML(); err != nil {
+		slog.Error("error while generating squad XML", slog.Any("err", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /squadxml/{section}/squad.xml", serveSquadXML)
+	mux.HandleFunc("GET /squadxml/{section}/{file}", serveSquadXMLAsset)
+	mux.HandleFunc("GET /battleye/whitelist/{format}", serveWhitelist)
+	mux.HandleFunc("GET /orbat.json", serveOrbat)
+
+	return &http.Server{
+		Addr:              httpAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func serveSquadXML(w http.ResponseWriter, r *http.Request) {
+	file, ok := getSquadXMLFile(r.PathValue("section"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// ServeContent answers If-None-Match and If-Modified-Since for us
+	w.Header().Set("ETag", file.ETag)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "squad.xml", file.LastModified, bytes.NewReader(file.Content))
+}
+
+// serveSquadXMLAsset serves the picture, DTD and stylesheet each squad.xml
+// refers to from the assets directory, laid out as `<section>/<file>`.
+func serveSquadXMLAsset(w http.ResponseWriter, r *http.Request) {
+	section, ok := findSquadXMLSection(r.PathValue("section"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, filepath.Join(squadXMLAssetsDirectory, section.Key, filepath.Base(r.PathValue("file"))))
+}
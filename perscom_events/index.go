@@ -22,6 +22,32 @@ var catalog = []ButtonEventHandler{
 	sfasApplication,
 }
 
+// listenerCatalog holds listeners that aren't tied to a button or command,
+// like background jobs started when a guild becomes ready.
+var listenerCatalog = []bot.EventListener{
+	nicknameAuditGuildReadyListener,
+	fixNicknameEventListener,
+	squadXMLReviewEventListener,
+	attendanceGuildReadyListener,
+	voiceAttendanceGuildReadyListener,
+	voiceAttendanceEventListener,
+	accountabilityGuildReadyListener,
+	accountabilityActionEventListener,
+	awardBoardGuildReadyListener,
+	milestonesGuildReadyListener,
+	ribbonImagesGuildReadyListener,
+	operationsGuildReadyListener,
+	aarSubmitEventListener,
+	aarModalSubmitEventListener,
+	classGuildReadyListener,
+	classDeclineEventListener,
+	availabilitySelectEventListener,
+}
+
 func GetButtonEventHandlers() []ButtonEventHandler {
 	return catalog
 }
+
+func GetEventListeners() []bot.EventListener {
+	return listenerCatalog
+}
@@ -2,10 +2,12 @@ package perscom_events
 
 import (
 	_ "embed"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"log/slog"
+	"time"
 )
 
 const leaveOfAbsenceCustomID = "leave-of-absence"
@@ -47,7 +49,7 @@ var leaveOfAbsenceModalEventListener = bot.NewListenerFunc(func(event *events.Co
 				SetTitle("Leave of Absence").
 				SetCustomID(leaveOfAbsenceModalSubmissionCustomID).
 				AddActionRow(discord.NewShortTextInput("reason", "Reason")).
-				AddActionRow(discord.NewShortTextInput("date", "Approx Return Date")).
+				AddActionRow(discord.NewShortTextInput("date", "Approx Return Date (YYYY-MM-DD)")).
 				Build(),
 		)
 
@@ -59,15 +61,25 @@ var leaveOfAbsenceModalEventListener = bot.NewListenerFunc(func(event *events.Co
 
 var leaveOfAbsenceModalSubmissionEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
 	if event.ModalSubmitInteraction.Data.CustomID == leaveOfAbsenceModalSubmissionCustomID {
-		//TODO: Submit LoA Request
+		message := discord.NewMessageUpdateBuilder().
+			ClearEmbeds().
+			ClearContainerComponents().
+			SetContent("Leave of absence request submitted.")
 
-		err := event.UpdateMessage(
-			discord.NewMessageUpdateBuilder().
-				ClearEmbeds().
-				ClearContainerComponents().
-				SetContent("Leave of absence request submitted.").
-				Build(),
-		)
+		returnDate, err := parseReturnDate(event.Data.Text("date"))
+		if err != nil {
+			message.SetContentf("Couldn't submit your leave of absence request: %v.", err)
+		} else if err = recordLeaveOfAbsence(event.User().ID, returnDate, event.Data.Text("reason")); err != nil {
+			slog.Error("error while recording leave of absence", slog.Any("err", err))
+			message.SetContentf("Couldn't submit your leave of absence request: %v.", err)
+		} else {
+			if superior := directSuperiorMention(event.User().ID); superior != "" {
+				message.SetContentf("Leave of absence request submitted. Check in with your direct superior, %v, when you return.", superior)
+			}
+			go notifyChainOfCommand(event.Client(), event.User().ID, fmt.Sprintf("submitted a leave of absence until %v", returnDate.Format(time.DateOnly)))
+		}
+
+		err = event.UpdateMessage(message.Build())
 
 		if err != nil {
 			slog.Error("error while creating message", slog.Any("err", err))
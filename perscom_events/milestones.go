@@ -0,0 +1,378 @@
+package perscom_events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const milestonesCommandName = "milestones"
+
+type milestoneKind string
+
+const (
+	milestoneServiceYears  milestoneKind = "service_years"
+	milestoneOpsAttended   milestoneKind = "ops_attended"
+	milestoneQualification milestoneKind = "qualification"
+)
+
+// milestoneRule suggests an award once a member reaches a milestone. Count is
+// the years of service or operations attended it takes, WithinDays only counts
+// operations from that many days back, and Citation is a text/template filled
+// in from a milestoneData. Each rule is only suggested once per member.
+//
+// S1 can replace the default rules by putting a milestone_rules.json with a
+// list of rules in the data directory.
+type milestoneRule struct {
+	Key           string        `json:"key"`
+	Kind          milestoneKind `json:"kind"`
+	Count         int           `json:"count,omitempty"`
+	WithinDays    int           `json:"within_days,omitempty"`
+	Qualification string        `json:"qualification,omitempty"`
+	Award         string        `json:"award"`
+	Citation      string        `json:"citation"`
+}
+
+type milestoneData struct {
+	Unit          string
+	Name          string
+	Count         int
+	WithinDays    int
+	Qualification string
+}
+
+var defaultMilestoneRules = []milestoneRule{
+	{Key: "service-1-year", Kind: milestoneServiceYears, Count: 1, Award: "agcm", Citation: "For one year of exemplary behavior, efficiency and fidelity in the {{.Unit}}."},
+	{Key: "service-2-years", Kind: milestoneServiceYears, Count: 2, Award: "agcm", Citation: "For two years of exemplary behavior, efficiency and fidelity in the {{.Unit}}."},
+	{Key: "service-5-years", Kind: milestoneServiceYears, Count: 5, Award: "msm", Citation: "For five years of outstanding meritorious service to the {{.Unit}}."},
+	{Key: "ops-10-in-90-days", Kind: milestoneOpsAttended, Count: 10, WithinDays: 90, Award: "aam", Citation: "For attending {{.Count}} operations in {{.WithinDays}} days with the {{.Unit}}."},
+	{Key: "ops-50", Kind: milestoneOpsAttended, Count: 50, Award: "arcom", Citation: "For attending {{.Count}} operations with the {{.Unit}}."},
+	{Key: "nco-course", Kind: milestoneQualification, Qualification: "NCO Training & Leadership", Award: "ncopdr", Citation: "For completing {{.Qualification}}."},
+}
+
+// milestonesReached remembers which rules were already suggested for each
+// member. Members without an entry haven't been checked yet.
+var milestonesReached = newJSONStore("milestones", func() map[snowflake.ID][]string {
+	return map[snowflake.ID][]string{}
+})
+
+var milestonesCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        milestonesCommandName,
+		Description: "Award suggestions for service milestones (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "rules",
+				Description: "List the milestone rules",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "check",
+				Description: "Check for milestones now instead of waiting for the daily check",
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{milestonesCommandEventListener},
+}
+
+var milestonesGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client, guildID := event.Client(), event.GuildID
+	startPeriodicTask(fmt.Sprintf("milestones:%v", guildID), 24*time.Hour, func() {
+		if _, err := suggestMilestoneAwards(client, guildID, time.Now().UTC()); err != nil {
+			slog.Error("error while checking milestones", slog.Any("err", err), slog.Any("guild", guildID))
+		}
+	})
+})
+
+var milestonesCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != milestonesCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	message := discord.NewMessageUpdateBuilder()
+	switch *data.SubCommandName {
+	case "rules":
+		rules, err := loadMilestoneRules()
+		if err != nil {
+			slog.Error("error while loading milestone rules", slog.Any("err", err))
+			message.SetContentf("Couldn't load the milestone rules: %v.", err)
+			break
+		}
+
+		lines := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			lines = append(lines, fmt.Sprintf("**%v** %v", rule.Key, rule))
+		}
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Milestone Rules").
+			SetDescription(orNone(strings.Join(lines, "\n"))).
+			Build(),
+		)
+	case "check":
+		suggested, err := suggestMilestoneAwards(event.Client(), *event.GuildID(), time.Now().UTC())
+		if err != nil {
+			slog.Error("error while checking milestones", slog.Any("err", err))
+			message.SetContentf("Couldn't check milestones: %v.", err)
+		} else {
+			message.SetContentf("Sent %d award suggestions to the awards board.", suggested)
+		}
+	default:
+		return
+	}
+
+	if _, err := event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), message.Build()); err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+func loadMilestoneRules() ([]milestoneRule, error) {
+	content, err := os.ReadFile(filepath.Join(dataDirectory, "milestone_rules.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultMilestoneRules, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var rules []milestoneRule
+	if err = json.Unmarshal(content, &rules); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if err = rule.validate(); err != nil {
+			return nil, fmt.Errorf("milestone rule %q: %w", rule.Key, err)
+		}
+	}
+
+	return rules, nil
+}
+
+func (r milestoneRule) validate() error {
+	if r.Key == "" {
+		return errors.New("missing key")
+	}
+
+	if _, ok := findAward(r.Award); !ok {
+		return fmt.Errorf("unknown award %q", r.Award)
+	}
+
+	switch r.Kind {
+	case milestoneServiceYears, milestoneOpsAttended:
+		if r.Count <= 0 {
+			return errors.New("count must be above zero")
+		}
+	case milestoneQualification:
+		if r.Qualification == "" {
+			return errors.New("missing qualification")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+
+	_, err := template.New(r.Key).Parse(r.Citation)
+	return err
+}
+
+func (r milestoneRule) String() string {
+	a, _ := findAward(r.Award)
+	switch r.Kind {
+	case milestoneServiceYears:
+		if r.Count == 1 {
+			return fmt.Sprintf("1 year of service: %v", a.Name)
+		}
+		return fmt.Sprintf("%d years of service: %v", r.Count, a.Name)
+	case milestoneOpsAttended:
+		if r.WithinDays > 0 {
+			return fmt.Sprintf("%d ops in %d days: %v", r.Count, r.WithinDays, a.Name)
+		}
+		return fmt.Sprintf("%d ops: %v", r.Count, a.Name)
+	default:
+		return fmt.Sprintf("completed %v: %v", r.Qualification, a.Name)
+	}
+}
+
+// reached reports whether the member has hit the milestone. attended holds
+// the start of every operation the member attended.
+func (r milestoneRule) reached(member rosterMember, attended []time.Time, now time.Time) bool {
+	switch r.Kind {
+	case milestoneServiceYears:
+		return !member.JoinDate.IsZero() && !now.Before(member.JoinDate.AddDate(r.Count, 0, 0))
+	case milestoneOpsAttended:
+		count := 0
+		for _, start := range attended {
+			if r.WithinDays <= 0 || !start.Before(now.AddDate(0, 0, -r.WithinDays)) {
+				count++
+			}
+		}
+		return count >= r.Count
+	case milestoneQualification:
+		return member.hasQualification(r.Qualification)
+	default:
+		return false
+	}
+}
+
+func (r milestoneRule) citation(member rosterMember) (string, error) {
+	tmpl, err := template.New(r.Key).Parse(r.Citation)
+	if err != nil {
+		return "", err
+	}
+
+	var citation bytes.Buffer
+	err = tmpl.Execute(&citation, milestoneData{
+		Unit:          squadXMLSections[0].Title,
+		Name:          member.Name,
+		Count:         r.Count,
+		WithinDays:    r.WithinDays,
+		Qualification: r.Qualification,
+	})
+
+	return citation.String(), err
+}
+
+// attendedOps maps each member to the start of every operation they were
+// present or partially present for.
+func attendedOps() (map[snowflake.ID][]time.Time, error) {
+	attended := map[snowflake.ID][]time.Time{}
+	err := attendanceStore.View(func(ops *map[string]opAttendance) {
+		for _, op := range *ops {
+			for memberID, record := range op.Records {
+				if record.Status == attendancePresent || record.Status == attendancePartial {
+					attended[memberID] = append(attended[memberID], op.Start)
+				}
+			}
+		}
+	})
+
+	return attended, err
+}
+
+// suggestMilestoneAwards sends the awards board a recommendation for every
+// milestone a serving member reached since the last check, returning how many
+// were sent. Awards that can only be received once aren't suggested again.
+// The first check of a member only records the milestones they've already
+// reached, so turning this on doesn't flood the board with stale
+// recommendations for every veteran.
+func suggestMilestoneAwards(client bot.Client, guildID snowflake.ID, now time.Time) (int, error) {
+	rules, err := loadMilestoneRules()
+	if err != nil {
+		return 0, err
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return 0, err
+	}
+
+	attended, err := attendedOps()
+	if err != nil {
+		return 0, err
+	}
+
+	var reached map[snowflake.ID][]string
+	err = milestonesReached.View(func(m *map[snowflake.ID][]string) {
+		reached = make(map[snowflake.ID][]string, len(*m))
+		for memberID, keys := range *m {
+			reached[memberID] = slices.Clone(keys)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	suggested := 0
+	for _, member := range members {
+		if member.Status == rosterStatusDischarged || member.Status == rosterStatusSuspended {
+			continue
+		}
+
+		if _, checked := reached[member.DiscordID]; !checked {
+			baseline := []string{}
+			for _, rule := range rules {
+				if rule.reached(member, attended[member.DiscordID], now) {
+					baseline = append(baseline, rule.Key)
+				}
+			}
+
+			err = milestonesReached.Update(func(m *map[snowflake.ID][]string) error {
+				(*m)[member.DiscordID] = baseline
+				return nil
+			})
+			if err != nil {
+				return suggested, err
+			}
+			continue
+		}
+
+		for _, rule := range rules {
+			if slices.Contains(reached[member.DiscordID], rule.Key) || !rule.reached(member, attended[member.DiscordID], now) {
+				continue
+			}
+
+			a, _ := findAward(rule.Award)
+			if a.MultipleAllowed || member.awardCount(a.Name) == 0 {
+				citation, err := rule.citation(member)
+				if err != nil {
+					return suggested, err
+				}
+
+				_, err = submitAwardRecommendation(client, guildID, awardRecommendationRecord{
+					Award:       rule.Award,
+					RecipientID: member.DiscordID,
+					Citation:    citation,
+				})
+				if err != nil {
+					slog.Error("error while suggesting milestone award", slog.Any("err", err), slog.String("rule", rule.Key), slog.Any("member", member.DiscordID))
+					continue
+				}
+				suggested++
+			}
+
+			err = milestonesReached.Update(func(m *map[snowflake.ID][]string) error {
+				(*m)[member.DiscordID] = append((*m)[member.DiscordID], rule.Key)
+				return nil
+			})
+			if err != nil {
+				return suggested, err
+			}
+		}
+	}
+
+	return suggested, nil
+}
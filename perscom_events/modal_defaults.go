@@ -0,0 +1,58 @@
+package perscom_events
+
+import (
+	"errors"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+)
+
+// modalDefaults are the values a member last typed into the name and player
+// ID inputs shared by several request modals.
+type modalDefaults struct {
+	Name     string `json:"name"`
+	PlayerID string `json:"player_id"`
+}
+
+var modalDefaultsStore = newJSONStore("modal_defaults", func() map[snowflake.ID]modalDefaults {
+	return map[snowflake.ID]modalDefaults{}
+})
+
+// getModalDefaults prefers the member's last submission, since it's the
+// newest, and falls back to their roster record for anything they haven't
+// typed in yet.
+func getModalDefaults(memberID snowflake.ID) modalDefaults {
+	var defaults modalDefaults
+	err := modalDefaultsStore.View(func(stored *map[snowflake.ID]modalDefaults) {
+		defaults = (*stored)[memberID]
+	})
+	if err != nil {
+		slog.Error("error while reading modal defaults", slog.Any("err", err))
+	}
+
+	member, err := getRosterMember(memberID)
+	if err != nil && !errors.Is(err, errRosterMemberNotFound) {
+		slog.Error("error while reading roster", slog.Any("err", err))
+	}
+
+	if defaults.Name == "" {
+		defaults.Name = member.Name
+	}
+	if defaults.PlayerID == "" {
+		defaults.PlayerID = member.PlayerID
+	}
+
+	return defaults
+}
+
+// saveModalDefaults remembers the submitted values for the next request. They
+// never reach the roster, a player ID only gets there once S1 approves a Squad
+// XML request for it.
+func saveModalDefaults(memberID snowflake.ID, defaults modalDefaults) {
+	err := modalDefaultsStore.Update(func(stored *map[snowflake.ID]modalDefaults) error {
+		(*stored)[memberID] = defaults
+		return nil
+	})
+	if err != nil {
+		slog.Error("error while saving modal defaults", slog.Any("err", err))
+	}
+}
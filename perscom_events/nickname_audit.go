@@ -0,0 +1,246 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const auditNicknamesCommandName = "audit-nicknames"
+const fixNicknameCustomID = "fix-nickname"
+const nicknameAuditReportPageSize = 10
+const maxButtonLabelLength = 80
+
+var (
+	s1ChannelName         = envString("s1_channel", "s1")
+	nicknameAuditInterval = time.Duration(envInt("nickname_audit_interval_hours", 24)) * time.Hour
+)
+
+// nicknameMismatch is a roster member whose guild nickname or rank roles
+// don't match their roster record. Fixable is set when the roster name
+// follows the convention and can be copied onto the nickname.
+type nicknameMismatch struct {
+	MemberID snowflake.ID
+	Nickname string
+	Expected string
+	Problems []string
+	Fixable  bool
+}
+
+var auditNicknamesCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        auditNicknamesCommandName,
+		Description: "Compare guild nicknames and rank roles to the roster (staff only)",
+	},
+	EventListeners: []bot.EventListener{auditNicknamesCommandEventListener},
+}
+
+var nicknameAuditGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client, guildID := event.Client(), event.GuildID
+	startPeriodicTask(fmt.Sprintf("nickname-audit:%v", guildID), nicknameAuditInterval, func() {
+		if _, err := postNicknameAudit(client, guildID); err != nil {
+			slog.Error("error while auditing nicknames", slog.Any("err", err), slog.Any("guild", guildID))
+		}
+	})
+})
+
+var auditNicknamesCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != auditNicknamesCommandName {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	content := ""
+	mismatches, err := postNicknameAudit(event.Client(), *event.GuildID())
+	if err != nil {
+		content = fmt.Sprintf("Couldn't audit nicknames: %v.", err)
+	} else {
+		content = fmt.Sprintf("Found %d mismatches, the report was posted to #%v.", mismatches, s1ChannelName)
+	}
+
+	_, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), discord.NewMessageUpdateBuilder().
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+var fixNicknameEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if !strings.HasPrefix(event.Data.CustomID(), fixNicknameCustomID+":") {
+		return
+	}
+
+	content := ""
+	if memberID, err := snowflake.Parse(strings.TrimPrefix(event.Data.CustomID(), fixNicknameCustomID+":")); err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	} else if !isStaff(event.Member()) || event.GuildID() == nil {
+		content = "Only staff can do that."
+	} else if member, err := getRosterMember(memberID); err != nil {
+		content = fmt.Sprintf("Couldn't fix the nickname: %v.", err)
+	} else if _, err = parsePlatformName(member.Name); err != nil {
+		content = fmt.Sprintf("Couldn't fix the nickname, the roster name %v.", err)
+	} else if _, err = event.Client().Rest().UpdateMember(*event.GuildID(), memberID, discord.MemberUpdate{Nick: &member.Name}); err != nil {
+		content = fmt.Sprintf("Couldn't fix the nickname: %v.", err)
+	} else {
+		content = fmt.Sprintf("Changed <@%v>'s nickname to `%v`.", memberID, member.Name)
+	}
+
+	err := event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetEphemeral(true).
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// auditNicknames compares every roster member still in the guild to their
+// guild nickname and rank roles.
+func auditNicknames(client bot.Client, guildID snowflake.ID) ([]nicknameMismatch, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	guildMembers, err := getGuildMembers(client, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[snowflake.ID]discord.Member, len(guildMembers))
+	for _, guildMember := range guildMembers {
+		byID[guildMember.User.ID] = guildMember
+	}
+
+	var mismatches []nicknameMismatch
+	for _, member := range members {
+		guildMember, ok := byID[member.DiscordID]
+		if !ok || member.Status == rosterStatusDischarged {
+			continue
+		}
+
+		mismatch := nicknameMismatch{
+			MemberID: member.DiscordID,
+			Nickname: guildMember.EffectiveName(),
+			Expected: member.Name,
+		}
+
+		if _, err := parsePlatformName(member.Name); err != nil {
+			mismatch.Problems = append(mismatch.Problems, "roster name "+err.Error())
+		} else if mismatch.Nickname != member.Name {
+			mismatch.Problems = append(mismatch.Problems, fmt.Sprintf("nickname is `%v`, roster has `%v`", mismatch.Nickname, member.Name))
+			mismatch.Fixable = true
+		}
+
+		mismatch.Problems = append(mismatch.Problems, rankRoleProblems(member, guildMember, roleIDs)...)
+		if len(mismatch.Problems) > 0 {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	return mismatches, nil
+}
+
+func rankRoleProblems(member rosterMember, guildMember discord.Member, roleIDs map[string]snowflake.ID) []string {
+	held := make(map[snowflake.ID]bool, len(guildMember.RoleIDs))
+	for _, roleID := range guildMember.RoleIDs {
+		held[roleID] = true
+	}
+
+	var problems []string
+	expected, _, ok := findRank(member.Rank)
+	if ok && !held[roleIDs[expected.RoleName]] {
+		problems = append(problems, fmt.Sprintf("missing the %v role", expected.RoleName))
+	}
+
+	for _, r := range ranks {
+		if roleID, exists := roleIDs[r.RoleName]; exists && held[roleID] && r.RoleName != expected.RoleName {
+			problems = append(problems, fmt.Sprintf("holds the %v role", r.RoleName))
+		}
+	}
+
+	return problems
+}
+
+// postNicknameAudit runs the audit and posts the report to S1, returning how
+// many mismatches were found.
+func postNicknameAudit(client bot.Client, guildID snowflake.ID) (int, error) {
+	mismatches, err := auditNicknames(client, guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(mismatches) == 0 {
+		return 0, nil
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < len(mismatches); i += nicknameAuditReportPageSize {
+		page := mismatches[i:min(i+nicknameAuditReportPageSize, len(mismatches))]
+
+		var description strings.Builder
+		buttons := make([]discord.InteractiveComponent, 0)
+		for _, mismatch := range page {
+			fmt.Fprintf(&description, "**<@%v>**\n- %v\n", mismatch.MemberID, strings.Join(mismatch.Problems, "\n- "))
+
+			if mismatch.Fixable {
+				buttons = append(buttons, discord.NewSecondaryButton(buttonLabel("Fix "+mismatch.Expected), fmt.Sprintf("%v:%v", fixNicknameCustomID, mismatch.MemberID)))
+			}
+		}
+
+		message := discord.NewMessageCreateBuilder().
+			SetEmbeds(discord.NewEmbedBuilder().
+				SetColor(0xe8b923).
+				SetTitle(fmt.Sprintf("Nickname Audit (%d-%d of %d)", i+1, i+len(page), len(mismatches))).
+				SetDescription(description.String()).
+				Build(),
+			)
+
+		for j := 0; j < len(buttons); j += 5 {
+			message.AddActionRow(buttons[j:min(j+5, len(buttons))]...)
+		}
+
+		if _, err = client.Rest().CreateMessage(channelID, message.Build()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(mismatches), nil
+}
@@ -0,0 +1,73 @@
+package perscom_events
+
+import (
+	"log/slog"
+	"time"
+)
+
+// The weekly operation, by default Saturdays at 23:00 UTC for three hours.
+var (
+	opWeekday  = time.Weekday(envInt("op_weekday", int(time.Saturday)))
+	opStart    = parseOpStart(envString("op_start_utc", "23:00"))
+	opDuration = time.Duration(envInt("op_duration_minutes", 180)) * time.Minute
+)
+
+// opWindow is the time span of a single scheduled operation. ID is the UTC
+// date the operation starts on and is used to key everything recorded for it.
+type opWindow struct {
+	ID    string
+	Start time.Time
+	End   time.Time
+}
+
+func parseOpStart(value string) time.Duration {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		slog.Error("invalid op start time, expected HH:MM", slog.String("value", value), slog.Any("err", err))
+		return 23 * time.Hour
+	}
+
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+}
+
+func newOpWindow(start time.Time) opWindow {
+	return opWindow{
+		ID:    start.Format(time.DateOnly),
+		Start: start,
+		End:   start.Add(opDuration),
+	}
+}
+
+// opWindowOn returns the operation scheduled that week, on or before day.
+func opWindowOn(day time.Time) opWindow {
+	day = day.UTC()
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(midnight.Weekday()) - int(opWeekday) + 7) % 7
+
+	return newOpWindow(midnight.AddDate(0, 0, -offset).Add(opStart))
+}
+
+// nextOpWindow returns the operation that is running at now or, if none is,
+// the next one to start.
+func nextOpWindow(now time.Time) opWindow {
+	window := opWindowOn(now)
+	for !now.Before(window.End) {
+		window = newOpWindow(window.Start.AddDate(0, 0, 7))
+	}
+
+	return window
+}
+
+// previousOpWindow returns the most recent operation that has already ended.
+func previousOpWindow(now time.Time) opWindow {
+	window := opWindowOn(now)
+	for now.Before(window.End) {
+		window = newOpWindow(window.Start.AddDate(0, 0, -7))
+	}
+
+	return window
+}
+
+func (w opWindow) contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
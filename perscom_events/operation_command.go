@@ -0,0 +1,142 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const operationCommandName = "operation"
+
+// How many operations /operation list shows, latest first
+const operationListLength = 25
+
+var operationCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        operationCommandName,
+		Description: "Look up and register operations",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "register",
+				Description: "Register an operation under the next number (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "name", Description: "Name of the operation", Required: true},
+					discord.ApplicationCommandOptionString{Name: "date", Description: "Date of the operation as YYYY-MM-DD, defaults to the next op"},
+					discord.ApplicationCommandOptionString{Name: "campaign", Description: "Campaign the operation is part of, defaults to the one running on its date"},
+					discord.ApplicationCommandOptionUser{Name: "mission-maker", Description: "Member who made the mission"},
+					discord.ApplicationCommandOptionInt{Name: "number", Description: "Number to register it under instead of the next one"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "list",
+				Description: "List the latest operations",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "show",
+				Description: "Show an operation with its attendance and AARs",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionInt{Name: "number", Description: "Operation number", Required: true},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{operationCommandEventListener},
+}
+
+var operationCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != operationCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	switch *data.SubCommandName {
+	case "register":
+		if !isStaff(event.Member()) {
+			message.SetContent("Only staff can do that.")
+			break
+		}
+
+		opID, ok := data.OptString("date")
+		if !ok {
+			opID = nextOpWindow(time.Now().UTC()).ID
+		}
+
+		op := operationRecord{
+			Number:       data.Int("number"),
+			Name:         strings.TrimSpace(data.String("name")),
+			OpID:         strings.TrimSpace(opID),
+			Campaign:     strings.TrimSpace(data.String("campaign")),
+			RegisteredBy: event.User().ID,
+		}
+		if user, ok := data.OptUser("mission-maker"); ok {
+			op.MissionMaker = user.ID
+		}
+
+		op, err := registerOperation(op)
+		if errors.Is(err, errOperationTaken) {
+			message.SetContentf("Operation #%d is already registered.", op.Number)
+		} else if err != nil {
+			slog.Error("error while registering operation", slog.Any("err", err))
+			message.SetContentf("Couldn't register the operation: %v.", err)
+		} else {
+			message.SetContentf("Registered %v on %v.", op.Title(), op.OpID)
+		}
+	case "list":
+		ops, err := listOperations()
+		if err != nil {
+			slog.Error("error while listing operations", slog.Any("err", err))
+			message.SetContentf("Couldn't list the operations: %v.", err)
+			break
+		}
+
+		lines := make([]string, 0, min(len(ops), operationListLength))
+		for _, op := range ops[:min(len(ops), operationListLength)] {
+			line := fmt.Sprintf("**%v** - %v", op.Title(), op.OpID)
+			if op.Campaign != "" {
+				line += fmt.Sprintf(" (%v)", op.Campaign)
+			}
+			lines = append(lines, line)
+		}
+
+		message.SetEmbeds(discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle("Operations").
+			SetDescription(orNone(strings.Join(lines, "\n"))).
+			Build(),
+		)
+	case "show":
+		op, err := findOperation(data.Int("number"))
+		if errors.Is(err, errOperationNotFound) {
+			message.SetContentf("There's no operation #%d.", data.Int("number"))
+			break
+		} else if err != nil {
+			slog.Error("error while reading operation", slog.Any("err", err))
+			message.SetContentf("Couldn't read the operation: %v.", err)
+			break
+		}
+
+		embed, err := operationEmbed(op)
+		if err != nil {
+			slog.Error("error while reading attendance", slog.Any("err", err))
+			message.SetContentf("Couldn't read the operation's attendance: %v.", err)
+		} else {
+			message.SetEmbeds(embed)
+		}
+	default:
+		return
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
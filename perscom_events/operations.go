@@ -0,0 +1,581 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const aarSubmitCustomID = "aar-submit"
+const aarModalSubmitCustomID = "aar-modal-submit"
+
+// AARs are only asked for when the op ended within this long, so an op missed
+// while the bot was down doesn't get a prompt days later
+const aarPromptWindow = 24 * time.Hour
+
+var aarChannelName = envString("aar_channel", "after-action-reports")
+
+var (
+	errOperationNotFound = errors.New("there's no operation with that number")
+	errOperationTaken    = errors.New("that operation number is already taken")
+)
+
+// operationRecord is a numbered operation. OpID is the date of the op window
+// it ran in, which links it to the attendance recorded for it. Leaders are
+// the unit leaders asked for an after action report, and the reports are
+// compiled in the thread started on the prompt message.
+type operationRecord struct {
+	Number       int                 `json:"number"`
+	Name         string              `json:"name"`
+	OpID         string              `json:"op_id"`
+	Campaign     string              `json:"campaign,omitempty"`
+	MissionMaker snowflake.ID        `json:"mission_maker,omitempty"`
+	Registered   time.Time           `json:"registered"`
+	RegisteredBy snowflake.ID        `json:"registered_by,omitempty"`
+	Leaders      []snowflake.ID      `json:"leaders,omitempty"`
+	ChannelID    snowflake.ID        `json:"channel_id,omitempty"`
+	MessageID    snowflake.ID        `json:"message_id,omitempty"`
+	ThreadID     snowflake.ID        `json:"thread_id,omitempty"`
+	AARs         []afterActionReport `json:"aars,omitempty"`
+}
+
+// afterActionReport is a leader's account of how an operation went for the
+// unit they lead.
+type afterActionReport struct {
+	AuthorID   snowflake.ID `json:"author_id"`
+	Unit       string       `json:"unit,omitempty"`
+	Summary    string       `json:"summary"`
+	WentWell   string       `json:"went_well,omitempty"`
+	Improve    string       `json:"improve,omitempty"`
+	Casualties string       `json:"casualties,omitempty"`
+	Submitted  time.Time    `json:"submitted"`
+}
+
+type operationData struct {
+	NextNumber int               `json:"next_number"`
+	Operations []operationRecord `json:"operations"`
+}
+
+var operations = newJSONStore("operations", func() operationData {
+	return operationData{NextNumber: 1}
+})
+
+var operationsGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	client, guildID := event.Client(), event.GuildID
+	startPeriodicTask(fmt.Sprintf("aar-prompt:%v", guildID), time.Hour, func() {
+		if err := promptAfterActionReports(client, guildID, time.Now().UTC()); err != nil {
+			slog.Error("error while asking for after action reports", slog.Any("err", err), slog.Any("guild", guildID))
+		}
+	})
+})
+
+var aarSubmitEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if !strings.HasPrefix(event.Data.CustomID(), aarSubmitCustomID+":") {
+		return
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(event.Data.CustomID(), aarSubmitCustomID+":"))
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	reply := func(content string) {
+		if err := event.CreateMessage(discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()); err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+	}
+
+	op, err := findOperation(number)
+	if err != nil {
+		reply(fmt.Sprintf("Couldn't find the operation: %v.", err))
+		return
+	}
+
+	if !slices.Contains(op.Leaders, event.User().ID) && !isStaff(event.Member()) {
+		reply("Only the leaders asked for an AAR can submit one.")
+		return
+	}
+
+	err = event.Modal(
+		discord.NewModalCreateBuilder().
+			SetTitle("After Action Report").
+			SetCustomID(fmt.Sprintf("%v:%d", aarModalSubmitCustomID, op.Number)).
+			AddActionRow(discord.NewParagraphTextInput("summary", "Summary").WithMaxLength(1024)).
+			AddActionRow(discord.NewParagraphTextInput("went_well", "What went well").WithMaxLength(1024).WithRequired(false)).
+			AddActionRow(discord.NewParagraphTextInput("improve", "What needs to improve").WithMaxLength(1024).WithRequired(false)).
+			AddActionRow(discord.NewShortTextInput("casualties", "Casualties and losses").WithRequired(false)).
+			Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating modal", slog.Any("err", err))
+	}
+})
+
+var aarModalSubmitEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
+	if !strings.HasPrefix(event.Data.CustomID, aarModalSubmitCustomID+":") {
+		return
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(event.Data.CustomID, aarModalSubmitCustomID+":"))
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	unit, err := leaderUnit(event.User().ID)
+	if err != nil {
+		slog.Error("error while reading billet assignments", slog.Any("err", err))
+	}
+
+	content := ""
+	op, err := submitAfterActionReport(event.Client(), number, afterActionReport{
+		AuthorID:   event.User().ID,
+		Unit:       unit,
+		Summary:    strings.TrimSpace(event.Data.Text("summary")),
+		WentWell:   strings.TrimSpace(event.Data.Text("went_well")),
+		Improve:    strings.TrimSpace(event.Data.Text("improve")),
+		Casualties: strings.TrimSpace(event.Data.Text("casualties")),
+		Submitted:  time.Now().UTC(),
+	})
+	if err != nil {
+		slog.Error("error while submitting after action report", slog.Any("err", err))
+		content = fmt.Sprintf("Couldn't submit your AAR: %v.", err)
+	} else {
+		content = fmt.Sprintf("Submitted your AAR for %v to <#%v>.", op.Title(), op.ThreadID)
+	}
+
+	err = event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetEphemeral(true).
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// Title names the operation the way it's referred to everywhere else.
+func (o operationRecord) Title() string {
+	if o.Name == "" {
+		return fmt.Sprintf("Operation #%d", o.Number)
+	}
+
+	return fmt.Sprintf("Operation #%d %v", o.Number, o.Name)
+}
+
+// parseOperationNumber reads an operation number written as 12, #12, Op 12
+// or Operation #12.
+func parseOperationNumber(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, prefix := range []string{"operation", "op"} {
+		if strings.HasPrefix(value, prefix) {
+			value = strings.TrimSpace(value[len(prefix):])
+			break
+		}
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(value, "#"))
+	if err != nil || number <= 0 {
+		return 0, errors.New("the operation must be a number like 12")
+	}
+
+	return number, nil
+}
+
+// checkOperationReference makes sure an operation number given by a member
+// is in the registry, returning it written as a plain number. An empty
+// reference is allowed as not every award comes from an operation.
+func checkOperationReference(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+
+	number, err := parseOperationNumber(value)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = findOperation(number); errors.Is(err, errOperationNotFound) {
+		return "", fmt.Errorf("there's no operation #%d in the registry", number)
+	} else if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(number), nil
+}
+
+func findOperation(number int) (operationRecord, error) {
+	var op operationRecord
+	found := false
+	err := operations.View(func(data *operationData) {
+		for _, o := range data.Operations {
+			if o.Number == number {
+				op, found = o, true
+				return
+			}
+		}
+	})
+
+	if err == nil && !found {
+		err = errOperationNotFound
+	}
+	return op, err
+}
+
+// findOperationOn returns the operation registered for the op window, if
+// there is one.
+func findOperationOn(opID string) (operationRecord, bool, error) {
+	var op operationRecord
+	found := false
+	err := operations.View(func(data *operationData) {
+		for _, o := range data.Operations {
+			if o.OpID == opID {
+				op, found = o, true
+				return
+			}
+		}
+	})
+
+	return op, found, err
+}
+
+// listOperations returns every registered operation, latest first.
+func listOperations() ([]operationRecord, error) {
+	var ops []operationRecord
+	err := operations.View(func(data *operationData) {
+		ops = slices.Clone(data.Operations)
+	})
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].OpID != ops[j].OpID {
+			return ops[i].OpID > ops[j].OpID
+		}
+		return ops[i].Number > ops[j].Number
+	})
+
+	return ops, err
+}
+
+// registerOperation adds the operation to the registry. It's given the next
+// number unless it already has one, which lets operations run before the
+// registry existed keep their numbers.
+func registerOperation(op operationRecord) (operationRecord, error) {
+	if _, err := time.Parse(time.DateOnly, op.OpID); err != nil {
+		return op, errors.New("the date must be a date like 2006-01-30")
+	}
+
+	if op.Number < 0 {
+		return op, errors.New("the operation number can't be negative")
+	}
+
+	// Operations fall in the campaign running on their date unless told otherwise
+	var err error
+	if op.Campaign == "" {
+		if op.Campaign, err = campaignOn(op.OpID); err != nil {
+			return op, err
+		}
+	} else if campaign, err := findCampaign(op.Campaign); err != nil {
+		return op, err
+	} else {
+		op.Campaign = campaign.Name
+	}
+
+	op.Registered = time.Now().UTC()
+	err = operations.Update(func(data *operationData) error {
+		if op.Number == 0 {
+			op.Number = data.NextNumber
+		}
+
+		for _, o := range data.Operations {
+			if o.Number == op.Number {
+				return errOperationTaken
+			}
+		}
+
+		data.NextNumber = max(data.NextNumber, op.Number+1)
+		data.Operations = append(data.Operations, op)
+		return nil
+	})
+
+	return op, err
+}
+
+func updateOperation(number int, fn func(op *operationRecord) error) (operationRecord, error) {
+	var updated operationRecord
+	err := operations.Update(func(data *operationData) error {
+		for i := range data.Operations {
+			if data.Operations[i].Number == number {
+				if err := fn(&data.Operations[i]); err != nil {
+					return err
+				}
+				updated = data.Operations[i]
+				return nil
+			}
+		}
+		return errOperationNotFound
+	})
+
+	return updated, err
+}
+
+// operationLeaders lists the members holding a leader billet anywhere in the
+// ORBAT.
+func operationLeaders() ([]snowflake.ID, error) {
+	assignments, err := getBilletAssignments()
+	if err != nil {
+		return nil, err
+	}
+
+	var leaders []snowflake.ID
+	for _, unit := range orbatUnits {
+		for _, billet := range unit.Billets {
+			if !billet.Leader {
+				continue
+			}
+
+			for _, holder := range assignments[billetAssignmentKey(unit, billet)] {
+				if !slices.Contains(leaders, holder) {
+					leaders = append(leaders, holder)
+				}
+			}
+		}
+	}
+
+	return leaders, nil
+}
+
+// leaderUnit returns the name of the unit the member leads, or an empty string
+// when they don't lead one.
+func leaderUnit(memberID snowflake.ID) (string, error) {
+	assignments, err := getBilletAssignments()
+	if err != nil {
+		return "", err
+	}
+
+	for _, unit := range orbatUnits {
+		for _, billet := range unit.Billets {
+			if billet.Leader && slices.Contains(assignments[billetAssignmentKey(unit, billet)], memberID) {
+				return unit.Name, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// promptAfterActionReports asks the unit leaders for their AARs once the last
+// op is over, registering it under the next number if staff didn't beforehand.
+// The prompt goes to the AAR channel with a thread the reports are posted in.
+func promptAfterActionReports(client bot.Client, guildID snowflake.ID, now time.Time) error {
+	window := previousOpWindow(now)
+	if now.After(window.End.Add(aarPromptWindow)) {
+		return nil
+	}
+
+	op, found, err := findOperationOn(window.ID)
+	if err != nil || (found && op.ThreadID != 0) {
+		return err
+	}
+
+	// The prompt went out before but its thread couldn't be made
+	if found && op.MessageID != 0 {
+		return createAfterActionReportThread(client, op)
+	}
+
+	if !found {
+		if op, err = registerOperation(operationRecord{OpID: window.ID}); err != nil {
+			return err
+		}
+	}
+
+	leaders, err := operationLeaders()
+	if err != nil {
+		return err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, aarChannelName)
+	if err != nil {
+		return err
+	}
+
+	mentions := make([]string, 0, len(leaders))
+	for _, leader := range leaders {
+		mentions = append(mentions, fmt.Sprintf("<@%v>", leader))
+	}
+
+	op.Leaders = leaders
+	message, err := client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetContentf("%v is over. Leaders, submit your after action reports: %v", op.Title(), orNone(strings.Join(mentions, " "))).
+		SetEmbeds(aarPromptEmbed(op)).
+		AddActionRow(discord.NewPrimaryButton("Submit AAR", fmt.Sprintf("%v:%d", aarSubmitCustomID, op.Number))).
+		Build(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Saved straight away so the leaders aren't pinged again if the thread fails
+	op, err = updateOperation(op.Number, func(o *operationRecord) error {
+		o.Leaders = leaders
+		o.ChannelID = channelID
+		o.MessageID = message.ID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return createAfterActionReportThread(client, op)
+}
+
+// createAfterActionReportThread opens the thread the operation's AARs are
+// posted in on its prompt.
+func createAfterActionReportThread(client bot.Client, op operationRecord) error {
+	thread, err := client.Rest().CreateThreadFromMessage(op.ChannelID, op.MessageID, discord.ThreadCreateFromMessage{
+		Name:                buttonLabel(fmt.Sprintf("AARs - %v", op.Title())),
+		AutoArchiveDuration: discord.AutoArchiveDuration1w,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = updateOperation(op.Number, func(o *operationRecord) error {
+		o.ThreadID = thread.ID()
+		return nil
+	})
+
+	return err
+}
+
+// submitAfterActionReport records the AAR and posts it in the operation's
+// thread. A leader submitting again replaces their earlier report.
+func submitAfterActionReport(client bot.Client, number int, report afterActionReport) (operationRecord, error) {
+	if report.Summary == "" {
+		return operationRecord{}, errors.New("the summary can't be empty")
+	}
+
+	op, err := updateOperation(number, func(o *operationRecord) error {
+		if o.ThreadID == 0 {
+			return errors.New("the operation has no AAR thread yet")
+		}
+
+		o.AARs = slices.DeleteFunc(o.AARs, func(aar afterActionReport) bool {
+			return aar.AuthorID == report.AuthorID
+		})
+		o.AARs = append(o.AARs, report)
+		return nil
+	})
+	if err != nil {
+		return op, err
+	}
+
+	_, err = client.Rest().CreateMessage(op.ThreadID, discord.NewMessageCreateBuilder().
+		SetEmbeds(aarEmbed(report)).
+		Build(),
+	)
+	if err != nil {
+		return op, err
+	}
+
+	// Keeps track of who's still to report on the prompt
+	_, err = client.Rest().UpdateMessage(op.ChannelID, op.MessageID, discord.NewMessageUpdateBuilder().
+		SetEmbeds(aarPromptEmbed(op)).
+		Build(),
+	)
+
+	return op, err
+}
+
+func aarPromptEmbed(op operationRecord) discord.Embed {
+	submitted := make([]string, 0, len(op.AARs))
+	for _, aar := range op.AARs {
+		submitted = append(submitted, fmt.Sprintf("<@%v>", aar.AuthorID))
+	}
+
+	var outstanding []string
+	for _, leader := range op.Leaders {
+		if !slices.ContainsFunc(op.AARs, func(aar afterActionReport) bool { return aar.AuthorID == leader }) {
+			outstanding = append(outstanding, fmt.Sprintf("<@%v>", leader))
+		}
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("After Action Reports - %v", op.Title()).
+		SetDescription("Press the button below to submit your AAR, they're compiled in the thread on this message.").
+		AddField("Submitted", truncateField(orNone(strings.Join(submitted, ", "))), false).
+		AddField("Outstanding", truncateField(orNone(strings.Join(outstanding, ", "))), false).
+		Build()
+}
+
+func aarEmbed(report afterActionReport) discord.Embed {
+	title := "After Action Report"
+	if report.Unit != "" {
+		title += " - " + report.Unit
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitle(title).
+		SetDescriptionf("Submitted by <@%v> <t:%d:f>", report.AuthorID, report.Submitted.Unix()).
+		AddField("Summary", truncateField(report.Summary), false).
+		AddField("What Went Well", truncateField(orNone(report.WentWell)), false).
+		AddField("What Needs To Improve", truncateField(orNone(report.Improve)), false).
+		AddField("Casualties and Losses", truncateField(orNone(report.Casualties)), false).
+		Build()
+}
+
+// operationEmbed shows the operation as registered, with a count of each
+// attendance status recorded for it.
+func operationEmbed(op operationRecord) (discord.Embed, error) {
+	attendance, found, err := getAttendance(op.OpID)
+	if err != nil {
+		return discord.Embed{}, err
+	}
+
+	counts := "None"
+	if found && len(attendance.Records) > 0 {
+		byStatus := map[attendanceStatus]int{}
+		for _, record := range attendance.Records {
+			byStatus[record.Status]++
+		}
+
+		parts := make([]string, 0, len(attendanceStatuses))
+		for _, status := range attendanceStatuses {
+			parts = append(parts, fmt.Sprintf("%v %d", status, byStatus[status]))
+		}
+		counts = strings.Join(parts, ", ")
+	}
+
+	missionMaker := "None"
+	if op.MissionMaker != 0 {
+		missionMaker = fmt.Sprintf("<@%v>", op.MissionMaker)
+	}
+
+	aars := fmt.Sprintf("%d of %d leaders", len(op.AARs), len(op.Leaders))
+	if op.ThreadID != 0 {
+		aars += fmt.Sprintf(" in <#%v>", op.ThreadID)
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitle(op.Title()).
+		AddField("Date", op.OpID, true).
+		AddField("Campaign", orNone(op.Campaign), true).
+		AddField("Mission Maker", missionMaker, true).
+		AddField("Attendance", counts, false).
+		AddField("AARs", aars, false).
+		Build(), nil
+}
@@ -0,0 +1,282 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"slices"
+	"strings"
+)
+
+// orbatUnit is a unit or section members can be assigned to. Name is both the
+// unit on the roster and the Discord role its members hold. A Capacity of zero
+// means the unit takes everyone, and members need every one of Qualifications
+// to join.
+type orbatUnit struct {
+	Key            string
+	Name           string
+	Parent         string
+	Capacity       int
+	Qualifications []string
+	Billets        []orbatBillet
+}
+
+// orbatBillet is a position within a unit. Slots is how many members can hold
+// it at once, the Leader billet is the one whose holder leads the unit, and
+// holders need at least MinimumRank and every one of Qualifications.
+type orbatBillet struct {
+	Key            string
+	Name           string
+	Abbreviation   string
+	Slots          int
+	Leader         bool
+	MinimumRank    string
+	Qualifications []string
+}
+
+var squadBillets = []orbatBillet{
+	{"squad-leader", "Squad Leader", "SL", 1, true, "SGT", []string{"NCO Training & Leadership"}},
+	{"team-leader", "Team Leader", "TL", 2, false, "CPL", nil},
+	{"medic", "Squad Medic", "MED", 1, false, "", []string{"Combat Life Saver"}},
+}
+
+// orbatUnits is our order of battle, parents before their children
+var orbatUnits = []orbatUnit{
+	{"1st-platoon", "1st Platoon", "", 4, []string{"Airborne"}, []orbatBillet{
+		{"platoon-leader", "Platoon Leader", "PL", 1, true, "2LT", nil},
+		{"platoon-sergeant", "Platoon Sergeant", "PSG", 1, false, "SFC", []string{"NCO Training & Leadership"}},
+		{"medic", "Platoon Medic", "MED", 1, false, "", []string{"Combat Life Saver"}},
+	}},
+	{"1st-squad", "1st Squad", "1st-platoon", 13, []string{"Airborne"}, squadBillets},
+	{"2nd-squad", "2nd Squad", "1st-platoon", 13, []string{"Airborne"}, squadBillets},
+	{"3rd-squad", "3rd Squad", "1st-platoon", 13, []string{"Airborne"}, squadBillets},
+	{"ace", "ACE", "", 8, []string{"Airborne"}, []orbatBillet{
+		{"flight-lead", "Flight Lead", "FL", 1, true, "WO1", []string{"Flight School"}},
+		{"pilot", "Pilot", "PLT", 4, false, "", []string{"Flight School"}},
+	}},
+	{"oda", "ODA", "", 12, []string{"Airborne", "SFAS"}, []orbatBillet{
+		{"detachment-commander", "Detachment Commander", "DC", 1, true, "2LT", nil},
+		{"team-sergeant", "Team Sergeant", "TS", 1, false, "SFC", []string{"NCO Training & Leadership"}},
+		{"medic", "Medical Sergeant", "MED", 2, false, "SGT", []string{"Combat Life Saver"}},
+	}},
+	{"reserves", "Reserves", "", 0, nil, nil},
+}
+
+var errBilletFull = errors.New("every slot of that billet is filled")
+
+// orbatAssignments maps `<unit>/<billet>` to the members holding the billet,
+// in the order they were assigned.
+var orbatAssignments = newJSONStore("orbat", func() map[string][]snowflake.ID {
+	return map[string][]snowflake.ID{}
+})
+
+func findOrbatUnit(key string) (orbatUnit, bool) {
+	for _, unit := range orbatUnits {
+		if unit.Key == key {
+			return unit, true
+		}
+	}
+
+	return orbatUnit{}, false
+}
+
+// findOrbatUnitByName looks up the unit a roster member is in.
+func findOrbatUnitByName(name string) (orbatUnit, bool) {
+	for _, unit := range orbatUnits {
+		if strings.EqualFold(unit.Name, name) {
+			return unit, true
+		}
+	}
+
+	return orbatUnit{}, false
+}
+
+func (u orbatUnit) findBillet(key string) (orbatBillet, bool) {
+	for _, billet := range u.Billets {
+		if billet.Key == key {
+			return billet, true
+		}
+	}
+
+	return orbatBillet{}, false
+}
+
+func (u orbatUnit) children() []orbatUnit {
+	var children []orbatUnit
+	for _, unit := range orbatUnits {
+		if unit.Parent == u.Key {
+			children = append(children, unit)
+		}
+	}
+
+	return children
+}
+
+func (b orbatBillet) slots() int {
+	return max(b.Slots, 1)
+}
+
+func billetAssignmentKey(unit orbatUnit, billet orbatBillet) string {
+	return unit.Key + "/" + billet.Key
+}
+
+// billetChoices lists every distinct billet across the ORBAT.
+func billetChoices() []discord.ApplicationCommandOptionChoiceString {
+	var choices []discord.ApplicationCommandOptionChoiceString
+	seen := map[string]bool{}
+	for _, unit := range orbatUnits {
+		for _, billet := range unit.Billets {
+			if !seen[billet.Key] {
+				seen[billet.Key] = true
+				choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: billet.Name, Value: billet.Key})
+			}
+		}
+	}
+
+	return choices
+}
+
+func orbatUnitChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(orbatUnits))
+	for _, unit := range orbatUnits {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: unit.Name, Value: unit.Key})
+	}
+
+	return choices
+}
+
+// unitStrength counts the members of a unit who aren't discharged or
+// suspended.
+func unitStrength(unitName string, members []rosterMember) int {
+	strength := 0
+	for _, member := range members {
+		if strings.EqualFold(member.Unit, unitName) && member.Status != rosterStatusDischarged && member.Status != rosterStatusSuspended {
+			strength++
+		}
+	}
+
+	return strength
+}
+
+// checkUnitEligibility reports why the member can't join the unit, if anything.
+func checkUnitEligibility(member rosterMember, unit orbatUnit, members []rosterMember) error {
+	if strings.EqualFold(member.Unit, unit.Name) {
+		return fmt.Errorf("you're already in %v", unit.Name)
+	}
+
+	if unit.Capacity > 0 && unitStrength(unit.Name, members) >= unit.Capacity {
+		return fmt.Errorf("%v is at its capacity of %d", unit.Name, unit.Capacity)
+	}
+
+	if missing := missingQualifications(member, unit.Qualifications); len(missing) > 0 {
+		return fmt.Errorf("%v requires %v", unit.Name, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// checkBilletRequirements reports why the member can't hold the billet, if
+// anything.
+func checkBilletRequirements(member rosterMember, billet orbatBillet) error {
+	if billet.MinimumRank != "" && !rankAtLeast(member.Rank, billet.MinimumRank) {
+		minimum, _, _ := findRank(billet.MinimumRank)
+		return fmt.Errorf("%v requires at least %v", billet.Name, minimum.Abbreviation)
+	}
+
+	if missing := missingQualifications(member, billet.Qualifications); len(missing) > 0 {
+		return fmt.Errorf("%v requires %v", billet.Name, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func missingQualifications(member rosterMember, qualifications []string) []string {
+	var missing []string
+	for _, qualification := range qualifications {
+		if !member.hasQualification(qualification) {
+			missing = append(missing, qualification)
+		}
+	}
+
+	return missing
+}
+
+// assignBillet puts the member in the billet, taking them out of any billet
+// they held before. Members have to be in the unit already, and meet the
+// billet's requirements unless they're waived.
+func assignBillet(unitKey string, billetKey string, memberID snowflake.ID, waiveRequirements bool) (orbatUnit, orbatBillet, error) {
+	unit, ok := findOrbatUnit(unitKey)
+	if !ok {
+		return unit, orbatBillet{}, fmt.Errorf("unknown unit %q", unitKey)
+	}
+
+	billet, ok := unit.findBillet(billetKey)
+	if !ok {
+		return unit, billet, fmt.Errorf("%v has no %v billet", unit.Name, billetKey)
+	}
+
+	member, err := getRosterMember(memberID)
+	if err != nil {
+		return unit, billet, err
+	}
+
+	if !strings.EqualFold(member.Unit, unit.Name) {
+		return unit, billet, fmt.Errorf("they aren't in %v, transfer them first", unit.Name)
+	}
+	if !waiveRequirements {
+		if err = checkBilletRequirements(member, billet); err != nil {
+			return unit, billet, err
+		}
+	}
+
+	key := billetAssignmentKey(unit, billet)
+	err = orbatAssignments.Update(func(assignments *map[string][]snowflake.ID) error {
+		holders := (*assignments)[key]
+		if slices.Contains(holders, memberID) {
+			return fmt.Errorf("they already hold %v", billet.Name)
+		}
+		if len(holders) >= billet.slots() {
+			return errBilletFull
+		}
+
+		removeBilletHolder(*assignments, memberID)
+		(*assignments)[key] = append((*assignments)[key], memberID)
+		return nil
+	})
+
+	return unit, billet, err
+}
+
+// vacateBillets takes the member out of every billet they hold.
+func vacateBillets(memberID snowflake.ID) error {
+	return orbatAssignments.Update(func(assignments *map[string][]snowflake.ID) error {
+		removeBilletHolder(*assignments, memberID)
+		return nil
+	})
+}
+
+func removeBilletHolder(assignments map[string][]snowflake.ID, memberID snowflake.ID) {
+	for key, holders := range assignments {
+		holders = slices.DeleteFunc(holders, func(holder snowflake.ID) bool {
+			return holder == memberID
+		})
+
+		if len(holders) == 0 {
+			delete(assignments, key)
+		} else {
+			assignments[key] = holders
+		}
+	}
+}
+
+func getBilletAssignments() (map[string][]snowflake.ID, error) {
+	assignments := map[string][]snowflake.ID{}
+	err := orbatAssignments.View(func(all *map[string][]snowflake.ID) {
+		for key, holders := range *all {
+			assignments[key] = slices.Clone(holders)
+		}
+	})
+
+	return assignments, err
+}
@@ -0,0 +1,198 @@
+package perscom_events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+)
+
+const orbatCommandName = "orbat"
+
+var orbatCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        orbatCommandName,
+		Description: "Show and manage the order of battle",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "show",
+				Description: "Show every unit and who holds each billet",
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "assign",
+				Description: "Assign a member to a billet in their unit (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionString{Name: "unit", Description: "Unit the billet belongs to", Required: true, Choices: orbatUnitChoices()},
+					discord.ApplicationCommandOptionString{Name: "billet", Description: "Billet to fill", Required: true, Choices: billetChoices()},
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to assign", Required: true},
+					discord.ApplicationCommandOptionBool{Name: "waive-requirements", Description: "Assign even if they don't meet the rank or qualification requirements"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "unassign",
+				Description: "Take a member out of their billet (staff only)",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to unassign", Required: true},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "chain",
+				Description: "Show a member's chain of command",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to look up, defaults to you"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "export",
+				Description: "Export the order of battle as JSON for the website",
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{orbatCommandEventListener},
+}
+
+var orbatCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != orbatCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	message := discord.NewMessageCreateBuilder().SetEphemeral(true)
+	switch *data.SubCommandName {
+	case "show":
+		snapshot, err := buildOrbatSnapshot()
+		if err != nil {
+			slog.Error("error while building orbat", slog.Any("err", err))
+			message.SetContentf("Couldn't build the order of battle: %v.", err)
+			break
+		}
+
+		diagram, err := renderOrbatPNG(snapshot)
+		if err != nil {
+			slog.Error("error while rendering orbat", slog.Any("err", err))
+			message.SetContentf("Couldn't draw the order of battle: %v.", err)
+			break
+		}
+
+		message.
+			SetEphemeral(false).
+			SetEmbeds(orbatEmbeds(snapshot)...).
+			AddFile("orbat.png", "Order of battle", bytes.NewReader(diagram))
+	case "assign":
+		if !isStaff(event.Member()) {
+			message.SetContent("Only staff can do that.")
+			break
+		}
+
+		user := data.User("member")
+		unit, billet, err := assignBillet(data.String("unit"), data.String("billet"), user.ID, data.Bool("waive-requirements"))
+		if err != nil {
+			message.SetContentf("Couldn't assign %v: %v.", user.Mention(), err)
+		} else {
+			message.SetContentf("Assigned %v as %v of %v.", user.Mention(), billet.Name, unit.Name)
+		}
+	case "unassign":
+		if !isStaff(event.Member()) {
+			message.SetContent("Only staff can do that.")
+			break
+		}
+
+		user := data.User("member")
+		if err := vacateBillets(user.ID); err != nil {
+			message.SetContentf("Couldn't unassign %v: %v.", user.Mention(), err)
+		} else {
+			message.SetContentf("Took %v out of their billets.", user.Mention())
+		}
+	case "chain":
+		user, ok := data.OptUser("member")
+		if !ok {
+			user = event.User()
+		}
+
+		chain, err := chainOfCommand(user.ID)
+		if errors.Is(err, errRosterMemberNotFound) {
+			message.SetContentf("%v isn't on the roster yet.", user.Mention())
+		} else if err != nil {
+			slog.Error("error while looking up chain of command", slog.Any("err", err))
+			message.SetContentf("Couldn't look up the chain of command: %v.", err)
+		} else if len(chain) == 0 {
+			message.SetContentf("%v doesn't have a chain of command.", user.Mention())
+		} else {
+			links := make([]string, 0, len(chain))
+			for i, link := range chain {
+				links = append(links, fmt.Sprintf("%d. %v", i+1, link))
+			}
+			message.SetEmbeds(discord.NewEmbedBuilder().
+				SetColor(0x5765f2).
+				SetTitlef("Chain of Command - %v", user.Username).
+				SetDescription(strings.Join(links, "\n")).
+				Build(),
+			)
+		}
+	case "export":
+		content, err := exportOrbatJSON()
+		if err != nil {
+			slog.Error("error while exporting orbat", slog.Any("err", err))
+			message.SetContentf("Couldn't export the order of battle: %v.", err)
+		} else {
+			message.AddFile("orbat.json", "Order of battle", bytes.NewReader(content))
+		}
+	default:
+		return
+	}
+
+	if err := event.CreateMessage(message.Build()); err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+// orbatEmbeds makes an embed for each top level unit, with a field for each
+// unit under it.
+func orbatEmbeds(snapshot orbatSnapshot) []discord.Embed {
+	embeds := make([]discord.Embed, 0, len(snapshot.Units))
+	for _, unit := range snapshot.Units {
+		builder := discord.NewEmbedBuilder().
+			SetColor(0x5765f2).
+			SetTitle(orbatUnitHeading(unit)).
+			SetDescription(orbatBilletLines(unit))
+
+		for _, child := range unit.Units {
+			builder.AddField(orbatUnitHeading(child), truncateField(orbatBilletLines(child)), true)
+		}
+
+		embeds = append(embeds, builder.Build())
+	}
+
+	return embeds
+}
+
+func orbatUnitHeading(unit orbatSnapshotUnit) string {
+	if unit.Capacity > 0 {
+		return fmt.Sprintf("%v (%d/%d)", unit.Name, unit.Strength, unit.Capacity)
+	}
+
+	return fmt.Sprintf("%v (%d)", unit.Name, unit.Strength)
+}
+
+func orbatBilletLines(unit orbatSnapshotUnit) string {
+	var lines []string
+	for _, billet := range unit.Billets {
+		for slot := 0; slot < billet.Slots; slot++ {
+			holder := "*Vacant*"
+			if slot < len(billet.Holders) {
+				holder = fmt.Sprintf("<@%v>", billet.Holders[slot].DiscordID)
+			}
+			lines = append(lines, fmt.Sprintf("**%v** %v", billet.Abbreviation, holder))
+		}
+	}
+
+	return orNone(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,146 @@
+package perscom_events
+
+import (
+	"encoding/json"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// orbatSnapshot is the ORBAT filled in with who is in each unit and billet.
+// It's what the embeds and diagram are drawn from and what the website reads,
+// so Discord IDs are left out of the JSON.
+type orbatSnapshot struct {
+	Generated time.Time           `json:"generated"`
+	Units     []orbatSnapshotUnit `json:"units"`
+}
+
+type orbatSnapshotUnit struct {
+	Key            string                `json:"key"`
+	Name           string                `json:"name"`
+	Capacity       int                   `json:"capacity,omitempty"`
+	Strength       int                   `json:"strength"`
+	Qualifications []string              `json:"qualifications,omitempty"`
+	Billets        []orbatSnapshotBillet `json:"billets,omitempty"`
+	Members        []orbatSnapshotMember `json:"members"`
+	Units          []orbatSnapshotUnit   `json:"units,omitempty"`
+}
+
+type orbatSnapshotBillet struct {
+	Key            string                `json:"key"`
+	Name           string                `json:"name"`
+	Abbreviation   string                `json:"abbreviation"`
+	Slots          int                   `json:"slots"`
+	Leader         bool                  `json:"leader,omitempty"`
+	MinimumRank    string                `json:"minimum_rank,omitempty"`
+	Qualifications []string              `json:"qualifications,omitempty"`
+	Holders        []orbatSnapshotMember `json:"holders"`
+}
+
+type orbatSnapshotMember struct {
+	DiscordID snowflake.ID `json:"-"`
+	Name      string       `json:"name"`
+	Rank      string       `json:"rank"`
+}
+
+func buildOrbatSnapshot() (orbatSnapshot, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return orbatSnapshot{}, err
+	}
+
+	assignments, err := getBilletAssignments()
+	if err != nil {
+		return orbatSnapshot{}, err
+	}
+
+	byID := make(map[snowflake.ID]rosterMember, len(members))
+	for _, member := range members {
+		byID[member.DiscordID] = member
+	}
+
+	snapshotMember := func(member rosterMember) orbatSnapshotMember {
+		return orbatSnapshotMember{DiscordID: member.DiscordID, Name: member.Name, Rank: member.Rank}
+	}
+
+	var build func(unit orbatUnit) orbatSnapshotUnit
+	build = func(unit orbatUnit) orbatSnapshotUnit {
+		snapshot := orbatSnapshotUnit{
+			Key:            unit.Key,
+			Name:           unit.Name,
+			Capacity:       unit.Capacity,
+			Strength:       unitStrength(unit.Name, members),
+			Qualifications: unit.Qualifications,
+			Members:        []orbatSnapshotMember{},
+		}
+
+		for _, member := range members {
+			if strings.EqualFold(member.Unit, unit.Name) && member.Status != rosterStatusDischarged && member.Status != rosterStatusSuspended {
+				snapshot.Members = append(snapshot.Members, snapshotMember(member))
+			}
+		}
+
+		for _, billet := range unit.Billets {
+			snapshotBillet := orbatSnapshotBillet{
+				Key:            billet.Key,
+				Name:           billet.Name,
+				Abbreviation:   billet.Abbreviation,
+				Slots:          billet.slots(),
+				Leader:         billet.Leader,
+				MinimumRank:    billet.MinimumRank,
+				Qualifications: billet.Qualifications,
+				Holders:        []orbatSnapshotMember{},
+			}
+
+			for _, holder := range assignments[billetAssignmentKey(unit, billet)] {
+				if member, ok := byID[holder]; ok {
+					snapshotBillet.Holders = append(snapshotBillet.Holders, snapshotMember(member))
+				}
+			}
+
+			snapshot.Billets = append(snapshot.Billets, snapshotBillet)
+		}
+
+		for _, child := range unit.children() {
+			snapshot.Units = append(snapshot.Units, build(child))
+		}
+
+		return snapshot
+	}
+
+	snapshot := orbatSnapshot{Generated: time.Now().UTC()}
+	for _, unit := range orbatUnits {
+		if unit.Parent == "" {
+			snapshot.Units = append(snapshot.Units, build(unit))
+		}
+	}
+
+	return snapshot, nil
+}
+
+func exportOrbatJSON() ([]byte, error) {
+	snapshot, err := buildOrbatSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+func serveOrbat(w http.ResponseWriter, r *http.Request) {
+	content, err := exportOrbatJSON()
+	if err != nil {
+		slog.Error("error while exporting orbat", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if _, err = w.Write(content); err != nil {
+		slog.Error("error while writing orbat", slog.Any("err", err))
+	}
+}
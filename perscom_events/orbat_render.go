@@ -0,0 +1,175 @@
+package perscom_events
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+const (
+	orbatTextScale     = 2
+	orbatLineHeight    = 20
+	orbatBoxPadding    = 12
+	orbatHorizontalGap = 24
+	orbatVerticalGap   = 48
+	orbatMargin        = 24
+	orbatLineWidth     = 2
+)
+
+var (
+	orbatBackground = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+	orbatBoxFill    = color.RGBA{0x1e, 0x1f, 0x22, 0xff}
+	orbatAccent     = color.RGBA{0x57, 0x65, 0xf2, 0xff}
+	orbatText       = color.RGBA{0xf2, 0xf3, 0xf5, 0xff}
+	orbatVacant     = color.RGBA{0x94, 0x9b, 0xa4, 0xff}
+)
+
+type orbatLine struct {
+	Text   string
+	Vacant bool
+}
+
+// orbatNode is a box in the tree diagram. Subtree is the width the node and
+// everything below it take up.
+type orbatNode struct {
+	Title    string
+	Lines    []orbatLine
+	Children []*orbatNode
+	Width    int
+	Height   int
+	Subtree  int
+	X        int
+	Y        int
+}
+
+// renderOrbatPNG draws the ORBAT as a top down tree, one box per unit listing
+// its billets and who holds them.
+func renderOrbatPNG(snapshot orbatSnapshot) ([]byte, error) {
+	root := &orbatNode{Title: squadXMLSections[0].Title}
+	for _, unit := range snapshot.Units {
+		root.Children = append(root.Children, newOrbatNode(unit))
+	}
+
+	var levels []int
+	measureOrbatNode(root, 0, &levels)
+
+	levelY := make([]int, len(levels))
+	y := orbatMargin
+	for depth, height := range levels {
+		levelY[depth] = y
+		y += height + orbatVerticalGap
+	}
+
+	placeOrbatNode(root, orbatMargin, 0, levelY)
+
+	img := image.NewRGBA(image.Rect(0, 0, root.Subtree+2*orbatMargin, y-orbatVerticalGap+orbatMargin))
+	fillRect(img, img.Bounds(), orbatBackground)
+	drawOrbatNode(img, root)
+
+	return encodePNG(img)
+}
+
+func newOrbatNode(unit orbatSnapshotUnit) *orbatNode {
+	node := &orbatNode{Title: unit.Name}
+	if unit.Capacity > 0 {
+		node.Title = fmt.Sprintf("%v (%d/%d)", unit.Name, unit.Strength, unit.Capacity)
+	} else {
+		node.Title = fmt.Sprintf("%v (%d)", unit.Name, unit.Strength)
+	}
+
+	for _, billet := range unit.Billets {
+		for slot := 0; slot < billet.Slots; slot++ {
+			if slot < len(billet.Holders) {
+				node.Lines = append(node.Lines, orbatLine{Text: fmt.Sprintf("%-3v %v", billet.Abbreviation, billet.Holders[slot].Name)})
+			} else {
+				node.Lines = append(node.Lines, orbatLine{Text: fmt.Sprintf("%-3v Vacant", billet.Abbreviation), Vacant: true})
+			}
+		}
+	}
+
+	for _, child := range unit.Units {
+		node.Children = append(node.Children, newOrbatNode(child))
+	}
+
+	return node
+}
+
+// measureOrbatNode sizes every box and records the tallest box at each depth.
+func measureOrbatNode(node *orbatNode, depth int, levels *[]int) {
+	node.Width = textWidth(node.Title, orbatTextScale)
+	for _, line := range node.Lines {
+		node.Width = max(node.Width, textWidth(line.Text, orbatTextScale))
+	}
+	node.Width += 2 * orbatBoxPadding
+	node.Height = 2*orbatBoxPadding + orbatLineHeight*(1+len(node.Lines))
+	if len(node.Lines) > 0 {
+		node.Height += orbatBoxPadding / 2
+	}
+
+	if len(*levels) <= depth {
+		*levels = append(*levels, 0)
+	}
+	(*levels)[depth] = max((*levels)[depth], node.Height)
+
+	children := 0
+	for i, child := range node.Children {
+		measureOrbatNode(child, depth+1, levels)
+		if i > 0 {
+			children += orbatHorizontalGap
+		}
+		children += child.Subtree
+	}
+
+	node.Subtree = max(node.Width, children)
+}
+
+func placeOrbatNode(node *orbatNode, left int, depth int, levelY []int) {
+	node.X = left + (node.Subtree-node.Width)/2
+	node.Y = levelY[depth]
+
+	children := -orbatHorizontalGap
+	for _, child := range node.Children {
+		children += child.Subtree + orbatHorizontalGap
+	}
+
+	left += (node.Subtree - children) / 2
+	for _, child := range node.Children {
+		placeOrbatNode(child, left, depth+1, levelY)
+		left += child.Subtree + orbatHorizontalGap
+	}
+}
+
+func drawOrbatNode(img *image.RGBA, node *orbatNode) {
+	box := image.Rect(node.X, node.Y, node.X+node.Width, node.Y+node.Height)
+	fillRect(img, box, orbatBoxFill)
+	strokeRect(img, box, orbatAccent, orbatLineWidth)
+
+	textY := node.Y + orbatBoxPadding + (orbatLineHeight-textHeight(orbatTextScale))/2
+	drawTextCentered(img, node.X+node.Width/2, textY, node.Title, orbatText, orbatTextScale)
+	textY += orbatLineHeight + orbatBoxPadding/2
+	for _, line := range node.Lines {
+		c := orbatText
+		if line.Vacant {
+			c = orbatVacant
+		}
+		drawText(img, node.X+orbatBoxPadding, textY, line.Text, c, orbatTextScale)
+		textY += orbatLineHeight
+	}
+
+	if len(node.Children) == 0 {
+		return
+	}
+
+	// Elbow connectors from the bottom of the box to the top of each child
+	centerX := node.X + node.Width/2
+	busY := node.Y + node.Height + (orbatVerticalGap / 2)
+	first, last := node.Children[0], node.Children[len(node.Children)-1]
+	fillRect(img, image.Rect(centerX-orbatLineWidth/2, node.Y+node.Height, centerX+orbatLineWidth/2, busY), orbatAccent)
+	fillRect(img, image.Rect(min(centerX, first.X+first.Width/2)-orbatLineWidth/2, busY-orbatLineWidth/2, max(centerX, last.X+last.Width/2)+orbatLineWidth/2, busY+orbatLineWidth/2), orbatAccent)
+
+	for _, child := range node.Children {
+		childX := child.X + child.Width/2
+		fillRect(img, image.Rect(childX-orbatLineWidth/2, busY, childX+orbatLineWidth/2, child.Y), orbatAccent)
+		drawOrbatNode(img, child)
+	}
+}
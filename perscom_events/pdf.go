@@ -0,0 +1,255 @@
+package perscom_events
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Like the images, PDFs are written with nothing but the standard library.
+// Text uses the standard Helvetica fonts every PDF reader has, so nothing has
+// to be embedded, and pages are measured in points.
+const (
+	pdfLetterWidth  = 612
+	pdfLetterHeight = 792
+)
+
+type pdfFont int
+
+const (
+	pdfHelvetica pdfFont = iota
+	pdfHelveticaBold
+	pdfHelveticaOblique
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// thousandths of the font size, from the Helvetica font metrics. Bold and
+// oblique are measured with the same widths, bold runs a little wider.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+type pdfImage struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	images  []pdfImage
+}
+
+type pdfDocument struct {
+	Width  float64
+	Height float64
+	pages  []*pdfPage
+}
+
+func newPDFDocument(width float64, height float64) *pdfDocument {
+	return &pdfDocument{Width: width, Height: height}
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// pdfText swaps characters the standard fonts can't show for '?' and escapes
+// the ones PDF strings treat specially.
+func pdfText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '‘' || r == '’':
+			b.WriteByte('\'')
+		case r == '“' || r == '”':
+			b.WriteByte('"')
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// pdfTextWidth is how many points wide text is at size.
+func pdfTextWidth(text string, font pdfFont, size float64) float64 {
+	width := 0
+	for _, r := range text {
+		if r < ' ' || r > '~' {
+			r = '?'
+		}
+		width += helveticaWidths[r-' ']
+	}
+
+	if font == pdfHelveticaBold {
+		width = width * 11 / 10
+	}
+
+	return float64(width) * size / 1000
+}
+
+// wrapPDFText breaks text into lines no wider than width, keeping the line
+// breaks already in it.
+func wrapPDFText(text string, font pdfFont, size float64, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+
+			if line != "" && pdfTextWidth(candidate, font, size) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// text draws text with its baseline starting at x, y from the bottom left.
+func (p *pdfPage) text(x float64, y float64, font pdfFont, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %.2f %.2f Td (%v) Tj ET\n", font+1, size, x, y, pdfText(text))
+}
+
+// image draws img stretched over the rectangle with its bottom left at x, y.
+func (p *pdfPage) image(img image.Image, x float64, y float64, width float64, height float64) error {
+	bounds := img.Bounds()
+	var raw bytes.Buffer
+	w := zlib.NewWriter(&raw)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		row = row[:0]
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			r, g, b, _ := img.At(px, py).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, y, len(p.images))
+	p.images = append(p.images, pdfImage{Width: bounds.Dx(), Height: bounds.Dy(), Data: raw.Bytes()})
+	return nil
+}
+
+// bytes writes out the document. Objects 1 and 2 are the catalog and page
+// tree, the fonts follow, then each page with its content and images.
+func (d *pdfDocument) bytes() []byte {
+	var objects [][]byte
+	add := func(object string) int {
+		objects = append(objects, []byte(object))
+		return len(objects)
+	}
+	stream := func(dictionary string, data []byte) int {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "<< %v >>\nstream\n", strings.TrimSpace(fmt.Sprintf("%v /Length %d", dictionary, len(data))))
+		b.Write(data)
+		b.WriteString("\nendstream")
+		objects = append(objects, b.Bytes())
+		return len(objects)
+	}
+
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	pagesIndex := add("")
+
+	var fonts strings.Builder
+	for i, name := range pdfFontNames {
+		id := add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%v /Encoding /WinAnsiEncoding >>", name))
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", i+1, id)
+	}
+
+	kids := make([]string, 0, len(d.pages))
+	for _, page := range d.pages {
+		var xObjects strings.Builder
+		for i, img := range page.images {
+			id := stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", img.Width, img.Height), img.Data)
+			fmt.Fprintf(&xObjects, "/Im%d %d 0 R ", i, id)
+		}
+
+		content := stream("", page.content.Bytes())
+		id := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /Font << %v>> /XObject << %v>> >> >>", d.Width, d.Height, content, fonts.String(), xObjects.String()))
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	objects[pagesIndex-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(object)
+		b.WriteString("\nendobj\n")
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return b.Bytes()
+}
+
+// pdfFlow lays text out top to bottom, starting a new page when the current
+// one is full.
+type pdfFlow struct {
+	doc    *pdfDocument
+	page   *pdfPage
+	margin float64
+	y      float64
+}
+
+func newPDFFlow(doc *pdfDocument, margin float64) *pdfFlow {
+	return &pdfFlow{doc: doc, margin: margin}
+}
+
+func (f *pdfFlow) ensure(height float64) {
+	if f.page == nil || f.y-height < f.margin {
+		f.page = f.doc.addPage()
+		f.y = f.doc.Height - f.margin
+	}
+}
+
+// paragraph wraps text to the page width, indented from the left margin.
+func (f *pdfFlow) paragraph(text string, font pdfFont, size float64, indent float64) {
+	leading := size * 1.3
+	for _, line := range wrapPDFText(text, font, size, f.doc.Width-2*f.margin-indent) {
+		f.ensure(leading)
+		f.y -= leading
+		f.page.text(f.margin+indent, f.y, font, size, line)
+	}
+}
+
+func (f *pdfFlow) space(height float64) {
+	f.ensure(height)
+	f.y -= height
+}
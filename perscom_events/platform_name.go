@@ -0,0 +1,65 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/snowflake/v2"
+	"regexp"
+	"strings"
+)
+
+// platformName is a name following our naming convention, `RANK F. Lastname`,
+// I.E. `SSG G. Hydra`.
+type platformName struct {
+	Rank     string
+	Initial  string
+	LastName string
+}
+
+var platformNamePattern = regexp.MustCompile(`^(\S+) ([A-Za-z])\.? ([A-Za-z][A-Za-z'\-]*)$`)
+
+// parsePlatformName accepts names that are close to the convention, such as a
+// missing period or a lower case initial, and normalizes them.
+func parsePlatformName(name string) (platformName, error) {
+	matches := platformNamePattern.FindStringSubmatch(strings.Join(strings.Fields(name), " "))
+	if matches == nil {
+		return platformName{}, fmt.Errorf("%q doesn't follow the `RANK F. Lastname` convention, I.E. `SSG G. Hydra`", name)
+	}
+
+	abbreviation := strings.ToUpper(matches[1])
+	if _, _, ok := findRank(abbreviation); !ok {
+		return platformName{}, fmt.Errorf("%q isn't a rank abbreviation", matches[1])
+	}
+
+	lastName := matches[3]
+	return platformName{
+		Rank:     abbreviation,
+		Initial:  strings.ToUpper(matches[2]),
+		LastName: strings.ToUpper(lastName[:1]) + lastName[1:],
+	}, nil
+}
+
+func (n platformName) String() string {
+	return fmt.Sprintf("%v %v. %v", n.Rank, n.Initial, n.LastName)
+}
+
+// validateNameInput checks a "Name" modal input against the convention and,
+// when the member is on the roster, against the rank they hold. The name is
+// returned normalized to the convention.
+func validateNameInput(memberID snowflake.ID, name string) (string, error) {
+	parsed, err := parsePlatformName(name)
+	if err != nil {
+		return "", err
+	}
+
+	member, err := getRosterMember(memberID)
+	if err != nil && !errors.Is(err, errRosterMemberNotFound) {
+		return "", err
+	}
+
+	if member.Rank != "" && parsed.Rank != member.Rank {
+		return "", fmt.Errorf("your name has the rank %v but the roster has you as %v", parsed.Rank, member.Rank)
+	}
+
+	return parsed.String(), nil
+}
@@ -0,0 +1,113 @@
+package perscom_events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"strings"
+)
+
+const profileCommandName = "profile"
+
+var profileCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        profileCommandName,
+		Description: "Show a member's personnel profile",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to look up, defaults to you"},
+		},
+	},
+	EventListeners: []bot.EventListener{profileCommandEventListener},
+}
+
+var profileCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != profileCommandName {
+		return
+	}
+
+	user, ok := event.SlashCommandInteractionData().OptUser("member")
+	if !ok {
+		user = event.User()
+	}
+
+	reply := func(content string) {
+		if err := event.CreateMessage(discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()); err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+	}
+
+	member, err := getRosterMember(user.ID)
+	if errors.Is(err, errRosterMemberNotFound) {
+		reply(fmt.Sprintf("%v isn't on the roster yet.", user.Mention()))
+		return
+	} else if err != nil {
+		slog.Error("error while reading roster", slog.Any("err", err))
+		reply(fmt.Sprintf("Couldn't read the roster: %v.", err))
+		return
+	}
+
+	// Drawing the ribbon rack can take a moment
+	if err = event.DeferCreateMessage(false); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	embed := profileEmbed(member, user)
+	message := discord.NewMessageUpdateBuilder()
+	if rack, ok, err := renderRibbonRack(member); err != nil {
+		slog.Error("error while rendering ribbon rack", slog.Any("err", err))
+	} else if ok {
+		embed.Image = &discord.EmbedResource{URL: "attachment://ribbons.png"}
+		message.AddFile("ribbons.png", "Ribbon rack", bytes.NewReader(rack))
+	}
+
+	if _, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), message.SetEmbeds(embed).Build()); err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+func profileEmbed(member rosterMember, user discord.User) discord.Embed {
+	qualifications := make([]string, 0, len(member.Qualifications))
+	for _, qualification := range member.Qualifications {
+		qualifications = append(qualifications, qualification.Name)
+	}
+
+	awards := make([]string, 0, len(member.Awards))
+	for _, award := range sortAwardsByPrecedence(member.Awards) {
+		line := fmt.Sprintf("**%v** (<t:%d:d>", award.Name, award.Awarded.Unix())
+		if award.Operation != "" {
+			line += ", Op " + award.Operation
+		}
+		line += ")"
+		if award.Citation != "" {
+			line += "\n> " + strings.ReplaceAll(award.Citation, "\n", " ")
+		}
+		awards = append(awards, line)
+	}
+
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitle(orNone(member.Name)).
+		SetThumbnail(user.EffectiveAvatarURL()).
+		AddField("Rank", orNone(member.Rank), true).
+		AddField("Unit", orNone(member.Unit), true).
+		AddField("Status", member.Status.String(), true).
+		AddField("Discord", user.Mention(), true).
+		AddField("Player ID", orNone(member.PlayerID), true).
+		AddField("Joined", fmt.Sprintf("<t:%d:D>", member.JoinDate.Unix()), true).
+		AddField("Qualifications", orNone(strings.Join(qualifications, "\n")), false).
+		AddField("Awards", truncateField(orNone(strings.Join(awards, "\n"))), false).
+		Build()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "None"
+	}
+
+	return value
+}
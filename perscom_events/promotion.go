@@ -0,0 +1,214 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const promoteCommandName = "promote"
+
+var promotionsChannelName = envString("promotions_channel", "promotions")
+
+var (
+	errPromotionUnknownRank = errors.New("unknown rank")
+	errPromotionSameRank    = errors.New("member already holds that rank")
+)
+
+var promoteCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        promoteCommandName,
+		Description: "Change a member's rank (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to promote", Required: true},
+			discord.ApplicationCommandOptionString{Name: "rank", Description: "New rank", Required: true, Choices: rankChoices()},
+			discord.ApplicationCommandOptionBool{Name: "waive-time-in-grade", Description: "Promote even if the minimum time in grade hasn't been served"},
+		},
+	},
+	EventListeners: []bot.EventListener{promoteCommandEventListener},
+}
+
+var promoteCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != promoteCommandName {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	// Promotions touch several REST endpoints which can take longer than the
+	// interaction response window
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	user := data.User("member")
+
+	content := fmt.Sprintf("Promoted %v.", user.Mention())
+	warnings, err := promoteMember(event.Client(), *event.GuildID(), event.User().ID, user.ID, data.String("rank"), data.Bool("waive-time-in-grade"))
+	if err != nil {
+		content = fmt.Sprintf("Couldn't promote %v: %v.", user.Mention(), err)
+	} else if len(warnings) > 0 {
+		content += "\nHowever:\n- " + strings.Join(warnings, "\n- ")
+	}
+
+	_, err = event.Client().Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), discord.NewMessageUpdateBuilder().
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating interaction response", slog.Any("err", err))
+	}
+})
+
+// promoteMember moves the member to the new rank on the roster and then
+// brings Discord in line with it. Only roster failures are returned as an
+// error, anything that goes wrong afterward is returned as a warning since the
+// promotion itself already happened.
+func promoteMember(client bot.Client, guildID snowflake.ID, promotedBy snowflake.ID, memberID snowflake.ID, abbreviation string, waiveTimeInGrade bool) ([]string, error) {
+	newRank, newRankIndex, ok := findRank(abbreviation)
+	if !ok {
+		return nil, errPromotionUnknownRank
+	}
+
+	if _, err := getRosterMember(memberID); err != nil {
+		return nil, err
+	}
+
+	var oldRank rank
+	var member rosterMember
+	var nameErr error
+	err := updateRosterMember(memberID, func(m *rosterMember) error {
+		if m.Rank == newRank.Abbreviation {
+			return errPromotionSameRank
+		}
+
+		current, currentIndex, ok := findRank(m.Rank)
+		if ok && newRankIndex > currentIndex && !waiveTimeInGrade {
+			since := m.RankDate
+			if since.IsZero() {
+				since = m.JoinDate
+			}
+
+			if served := time.Since(since); served < current.MinimumTimeInGrade {
+				return fmt.Errorf("%v requires %d days time in grade, %d served", current.Abbreviation, int(current.MinimumTimeInGrade/day), int(served/day))
+			}
+		}
+
+		oldRank = current
+		m.Rank = newRank.Abbreviation
+		m.RankDate = time.Now().UTC()
+		if name, err := parsePlatformName(m.Name); err == nil {
+			name.Rank = newRank.Abbreviation
+			m.Name = name.String()
+		} else {
+			nameErr = err
+		}
+		member = *m
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	warn := func(message string, err error) {
+		slog.Error(message, slog.Any("err", err), slog.Any("member", memberID))
+		warnings = append(warnings, fmt.Sprintf("%v: %v", message, err))
+	}
+
+	if err = syncRankRoles(client, guildID, memberID, newRank); err != nil {
+		warn("error while updating rank roles", err)
+	}
+
+	if member.Name != "" && nameErr != nil {
+		// Setting it would put the old rank straight back
+		warnings = append(warnings, fmt.Sprintf("left the nickname alone since %q couldn't be parsed: %v", member.Name, nameErr))
+	} else if member.Name != "" {
+		if _, err = client.Rest().UpdateMember(guildID, memberID, discord.MemberUpdate{Nick: &member.Name}); err != nil {
+			warn("error while updating nickname", err)
+		}
+	}
+
+	if channelID, err := findGuildChannel(client, guildID, promotionsChannelName); err != nil {
+		warn("error while finding promotions channel", err)
+	} else {
+		_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+			SetEmbeds(discord.NewEmbedBuilder().
+				SetColor(0x237f44).
+				SetTitle(":military_helmet: Promotion :military_helmet:").
+				SetDescriptionf("<@%v> has been promoted to **%v** (%v).", memberID, newRank.Name, newRank.PayGrade).
+				AddField("Previous Rank", orNone(oldRank.Name), true).
+				AddField("Promoted By", fmt.Sprintf("<@%v>", promotedBy), true).
+				Build(),
+			).
+			Build(),
+		)
+
+		if err != nil {
+			warn("error while posting promotion", err)
+		}
+	}
+
+	if err = submitSquadXMLRequest(client, guildID, memberID, member.Name, member.PlayerID, memberSquadXMLSection(member).Key, "Promotion to "+newRank.Abbreviation); err != nil {
+		warn("error while submitting squad XML update", err)
+	}
+
+	return warnings, nil
+}
+
+// syncRankRoles gives the member the role for their rank and takes away the
+// roles of every other rank.
+func syncRankRoles(client bot.Client, guildID snowflake.ID, memberID snowflake.ID, newRank rank) error {
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return err
+	}
+
+	guildMember, err := client.Rest().GetMember(guildID, memberID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranks {
+		roleID, ok := roleIDs[r.RoleName]
+		if !ok || r.RoleName == newRank.RoleName {
+			continue
+		}
+
+		for _, heldRoleID := range guildMember.RoleIDs {
+			if heldRoleID == roleID {
+				if err = client.Rest().RemoveMemberRole(guildID, memberID, roleID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	roleID, ok := roleIDs[newRank.RoleName]
+	if !ok {
+		return fmt.Errorf("role %q not found", newRank.RoleName)
+	}
+
+	return client.Rest().AddMemberRole(guildID, memberID, roleID)
+}
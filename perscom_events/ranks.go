@@ -0,0 +1,88 @@
+package perscom_events
+
+import (
+	"github.com/disgoorg/disgo/discord"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// rank is a single grade in the rank structure. RoleName is the Discord role
+// held by members of this rank and MinimumTimeInGrade is how long a member
+// must hold it before they're eligible for promotion.
+type rank struct {
+	PayGrade           string
+	Abbreviation       string
+	Name               string
+	RoleName           string
+	MinimumTimeInGrade time.Duration
+}
+
+// ranks is ordered from lowest to highest
+var ranks = []rank{
+	{"E-1", "PVT", "Private", "Private", 14 * day},
+	{"E-2", "PV2", "Private Second Class", "Private Second Class", 30 * day},
+	{"E-3", "PFC", "Private First Class", "Private First Class", 60 * day},
+	{"E-4", "SPC", "Specialist", "Specialist", 90 * day},
+	{"E-4", "CPL", "Corporal", "Corporal", 90 * day},
+	{"E-5", "SGT", "Sergeant", "Sergeant", 120 * day},
+	{"E-6", "SSG", "Staff Sergeant", "Staff Sergeant", 180 * day},
+	{"E-7", "SFC", "Sergeant First Class", "Sergeant First Class", 180 * day},
+	{"E-8", "MSG", "Master Sergeant", "Master Sergeant", 180 * day},
+	{"E-8", "1SG", "First Sergeant", "First Sergeant", 180 * day},
+	{"E-9", "SGM", "Sergeant Major", "Sergeant Major", 365 * day},
+	{"E-9", "CSM", "Command Sergeant Major", "Command Sergeant Major", 365 * day},
+	{"W-1", "WO1", "Warrant Officer 1", "Warrant Officer 1", 90 * day},
+	{"W-2", "CW2", "Chief Warrant Officer 2", "Chief Warrant Officer 2", 180 * day},
+	{"W-3", "CW3", "Chief Warrant Officer 3", "Chief Warrant Officer 3", 180 * day},
+	{"W-4", "CW4", "Chief Warrant Officer 4", "Chief Warrant Officer 4", 365 * day},
+	{"W-5", "CW5", "Chief Warrant Officer 5", "Chief Warrant Officer 5", 365 * day},
+	{"O-1", "2LT", "Second Lieutenant", "Second Lieutenant", 90 * day},
+	{"O-2", "1LT", "First Lieutenant", "First Lieutenant", 180 * day},
+	{"O-3", "CPT", "Captain", "Captain", 180 * day},
+	{"O-4", "MAJ", "Major", "Major", 365 * day},
+	{"O-5", "LTC", "Lieutenant Colonel", "Lieutenant Colonel", 365 * day},
+	{"O-6", "COL", "Colonel", "Colonel", 365 * day},
+}
+
+// payGradeSteps orders the pay grades across the enlisted, warrant and officer
+// tracks for rank requirements. Grades on the same step count the same, so the
+// most senior NCOs meet a requirement of WO1 and every officer outranks the
+// warrant officers.
+var payGradeSteps = map[string]int{
+	"E-1": 1, "E-2": 2, "E-3": 3, "E-4": 4, "E-5": 5, "E-6": 6, "E-7": 7, "E-8": 8, "E-9": 9,
+	"W-1": 9, "W-2": 10, "W-3": 11, "W-4": 12, "W-5": 13,
+	"O-1": 14, "O-2": 15, "O-3": 16, "O-4": 17, "O-5": 18, "O-6": 19,
+}
+
+// rankAtLeast reports whether the rank meets a minimum rank by pay grade, so a
+// SPC meets a CPL minimum. Unknown ranks never meet one.
+func rankAtLeast(abbreviation string, minimum string) bool {
+	r, _, ok := findRank(abbreviation)
+	if !ok {
+		return false
+	}
+
+	required, _, ok := findRank(minimum)
+	return ok && payGradeSteps[r.PayGrade] >= payGradeSteps[required.PayGrade]
+}
+
+// findRank looks a rank up by abbreviation and returns its position in ranks
+func findRank(abbreviation string) (rank, int, bool) {
+	for i, r := range ranks {
+		if r.Abbreviation == abbreviation {
+			return r, i, true
+		}
+	}
+
+	return rank{}, -1, false
+}
+
+func rankChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(ranks))
+	for _, r := range ranks {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: r.PayGrade + " " + r.Name, Value: r.Abbreviation})
+	}
+
+	return choices
+}
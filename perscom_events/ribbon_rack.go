@@ -0,0 +1,297 @@
+package perscom_events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/events"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ribbons are drawn at four pixels to the sixteenth of an inch, the real
+// thing being 1 3/8 by 3/8 inches.
+const (
+	ribbonWidth   = 88
+	ribbonHeight  = 24
+	ribbonGap     = 2
+	ribbonMargin  = 4
+	deviceSize    = 10
+	deviceSpacing = 2
+)
+
+var (
+	ribbonImagesDirectory = envString("ribbon_images_dir", filepath.Join(dataDirectory, "ribbons"))
+	ribbonRackRowLength   = min(max(envInt("ribbon_rack_row_length", 3), 3), 4)
+)
+
+var ribbonHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// ribbonImagesGuildReadyListener keeps the ribbon images directory stocked in
+// the background, racks are drawn from whatever it holds.
+var ribbonImagesGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	startPeriodicTask("ribbon-images", 24*time.Hour, downloadRibbonImages)
+})
+
+var (
+	ribbonPlaceholder = color.RGBA{0x6b, 0x70, 0x78, 0xff}
+	ribbonLabel       = color.RGBA{0xf2, 0xf3, 0xf5, 0xff}
+	bronzeDevice      = color.RGBA{0xa9, 0x71, 0x42, 0xff}
+	silverDevice      = color.RGBA{0xc0, 0xc0, 0xc8, 0xff}
+	deviceOutline     = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+)
+
+// awardDevice is how repeat awards of a ribbon are shown. Most awards get an
+// oak leaf cluster per additional award, silver standing in for five bronze.
+type awardDevice int
+
+const (
+	oakLeafClusters awardDevice = iota
+	numerals
+	knots
+)
+
+var awardDevices = map[string]awardDevice{
+	"am":     numerals,
+	"agcm":   knots,
+	"ncopdr": numerals,
+	"osr":    numerals,
+	"arcotr": numerals,
+}
+
+// awardCount is an award a member holds and how many times they received it.
+type awardCount struct {
+	Award award
+	Count int
+}
+
+// ribbonRackAwards groups the member's awards most prestigious first, with
+// how many times each was received.
+func ribbonRackAwards(member rosterMember) []awardCount {
+	var counts []awardCount
+	for _, received := range sortAwardsByPrecedence(member.Awards) {
+		if n := len(counts); n > 0 && strings.EqualFold(counts[n-1].Award.Name, received.Name) {
+			counts[n-1].Count++
+			continue
+		}
+
+		a, ok := findAwardByName(received.Name)
+		if !ok {
+			a = award{Name: received.Name, Precedence: awardPrecedence(received.Name)}
+		}
+		counts = append(counts, awardCount{Award: a, Count: 1})
+	}
+
+	return counts
+}
+
+// renderRibbonRack draws the member's ribbons as worn, most prestigious top
+// left. Rows are full from the bottom up so the top row is the one left short,
+// and it's centered over the rest. ok is false when the member has no awards.
+func renderRibbonRack(member rosterMember) ([]byte, bool, error) {
+	counts := ribbonRackAwards(member)
+	if len(counts) == 0 {
+		return nil, false, nil
+	}
+
+	rows := (len(counts) + ribbonRackRowLength - 1) / ribbonRackRowLength
+	width := 2*ribbonMargin + ribbonRackRowLength*ribbonWidth + (ribbonRackRowLength-1)*ribbonGap
+	height := 2*ribbonMargin + rows*ribbonHeight + (rows-1)*ribbonGap
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	topRow := len(counts) - (rows-1)*ribbonRackRowLength
+	for i, count := range counts {
+		row, column, inRow := 0, i, topRow
+		if i >= topRow {
+			row = 1 + (i-topRow)/ribbonRackRowLength
+			column = (i - topRow) % ribbonRackRowLength
+			inRow = ribbonRackRowLength
+		}
+
+		left := (width - inRow*ribbonWidth - (inRow-1)*ribbonGap) / 2
+		x := left + column*(ribbonWidth+ribbonGap)
+		y := ribbonMargin + row*(ribbonHeight+ribbonGap)
+		bounds := image.Rect(x, y, x+ribbonWidth, y+ribbonHeight)
+
+		if err := drawRibbon(img, bounds, count.Award); err != nil {
+			return nil, false, err
+		}
+		drawAwardDevices(img, bounds, count)
+	}
+
+	content, err := encodePNG(img)
+	return content, true, err
+}
+
+// drawRibbon draws the award's ribbon image, or a plain placeholder labelled with the award's key or initials when there
+// isn't one.
+func drawRibbon(img draw.Image, bounds image.Rectangle, a award) error {
+	ribbon, err := loadRibbonImage(a)
+	if err != nil {
+		return err
+	}
+
+	if ribbon == nil {
+		fillRect(img, bounds, ribbonPlaceholder)
+		label := strings.ToUpper(a.Key)
+		if label == "" {
+			for _, word := range strings.Fields(a.Name) {
+				label += strings.ToUpper(string([]rune(word)[:1]))
+			}
+		}
+		drawTextCentered(img, bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+(bounds.Dy()-textHeight(1))/2, label, ribbonLabel, 1)
+		return nil
+	}
+
+	// Nearest neighbour is plenty for ribbons, they're blocks of color
+	src := ribbon.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			img.Set(bounds.Min.X+x, bounds.Min.Y+y, ribbon.At(src.Min.X+x*src.Dx()/bounds.Dx(), src.Min.Y+y*src.Dy()/bounds.Dy()))
+		}
+	}
+
+	return nil
+}
+
+// loadRibbonImage reads the award's ribbon from the ribbon images directory.
+// The image is nil when the award has no ribbon or it hasn't been downloaded.
+func loadRibbonImage(a award) (image.Image, error) {
+	if a.Ribbon == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(filepath.Join(ribbonImagesDirectory, a.Ribbon))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ribbon, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error while decoding ribbon %v: %w", a.Ribbon, err)
+	}
+
+	return ribbon, nil
+}
+
+// downloadRibbonImages saves the ribbon of every award missing from the
+// ribbon images directory from the award assets site.
+func downloadRibbonImages() {
+	for _, a := range awards {
+		if a.Ribbon == "" {
+			continue
+		}
+
+		path := filepath.Join(ribbonImagesDirectory, a.Ribbon)
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err := downloadRibbonImage(a, path); err != nil {
+			slog.Error("error while downloading ribbon", slog.String("ribbon", a.Ribbon), slog.Any("err", err))
+		}
+	}
+}
+
+// downloadRibbonImage saves the award's ribbon from RibbonURL to path. Only
+// valid PNGs are kept so a bad download doesn't break every later render.
+func downloadRibbonImage(a award, path string) error {
+	content, err := fetchRibbonImage(a.RibbonURL())
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Written alongside and renamed so a render never reads half a file
+	temp := path + ".tmp"
+	if err = os.WriteFile(temp, content, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, path)
+}
+
+func fetchRibbonImage(url string) ([]byte, error) {
+	response, err := ribbonHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned %v", url, response.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = png.DecodeConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("%v isn't a PNG: %w", url, err)
+	}
+
+	return content, nil
+}
+
+// drawAwardDevices centers the devices for repeat awards on the ribbon.
+func drawAwardDevices(img draw.Image, bounds image.Rectangle, count awardCount) {
+	if count.Count < 2 {
+		return
+	}
+
+	centerY := bounds.Min.Y + bounds.Dy()/2
+	if awardDevices[count.Award.Key] == numerals {
+		label := strconv.Itoa(count.Count)
+		x := bounds.Min.X + (bounds.Dx()-textWidth(label, 2))/2
+		y := centerY - textHeight(2)/2
+		fillRect(img, image.Rect(x-1, y-1, x+textWidth(label, 2)+1, y+textHeight(2)-1), deviceOutline)
+		drawText(img, x, y, label, bronzeDevice, 2)
+		return
+	}
+
+	// Each device stands for one more award, a silver one for five more
+	additional := count.Count - 1
+	devices := make([]color.Color, 0, additional/5+additional%5)
+	for range additional / 5 {
+		devices = append(devices, silverDevice)
+	}
+	for range additional % 5 {
+		devices = append(devices, bronzeDevice)
+	}
+
+	total := len(devices)*deviceSize + (len(devices)-1)*deviceSpacing
+	x := bounds.Min.X + (bounds.Dx()-total)/2
+	for _, c := range devices {
+		center := image.Pt(x+deviceSize/2, centerY)
+		if awardDevices[count.Award.Key] == knots {
+			fillCircle(img, center, deviceSize/2, deviceOutline)
+			fillCircle(img, center, deviceSize/2-1, c)
+			fillCircle(img, center, deviceSize/2-3, deviceOutline)
+		} else {
+			// An oak leaf cluster is close enough to a leaf on a stem at this size
+			fillRect(img, image.Rect(center.X-1, center.Y, center.X+1, center.Y+deviceSize/2), deviceOutline)
+			fillCircle(img, center, deviceSize/2, deviceOutline)
+			fillCircle(img, center, deviceSize/2-1, c)
+		}
+		x += deviceSize + deviceSpacing
+	}
+}
@@ -0,0 +1,317 @@
+package perscom_events
+
+import (
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+const reconcileRolesCommandName = "reconcile-roles"
+const roleDriftReportPageSize = 15
+
+// Discord allows roughly ten member role changes every ten seconds per guild,
+// space the requests out so enforcing a large drift never trips the limit.
+var roleReconciliationDelay = time.Duration(envInt("role_reconciliation_delay_ms", 1100)) * time.Millisecond
+
+// statusRoleNames are the roles given to members depending on their status.
+// Active members don't get a status role.
+var statusRoleNames = map[rosterStatus]string{
+	rosterStatusLOA:        "Leave of Absence",
+	rosterStatusReserves:   "Reserves",
+	rosterStatusSuspended:  "Suspended",
+	rosterStatusDischarged: "Discharged",
+}
+
+// roleDrift lists the roles a member should have but doesn't and the managed
+// roles they hold but shouldn't.
+type roleDrift struct {
+	MemberID snowflake.ID
+	Missing  []string
+	Extra    []string
+}
+
+var reconcileRolesCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        reconcileRolesCommandName,
+		Description: "Compare guild roles to the roster and optionally correct them (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionString{
+				Name:        "mode",
+				Description: "Only report the differences, or correct them too",
+				Required:    true,
+				Choices: []discord.ApplicationCommandOptionChoiceString{
+					{Name: "Dry run", Value: "dry-run"},
+					{Name: "Enforce", Value: "enforce"},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{reconcileRolesCommandEventListener},
+}
+
+var reconcileRolesCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != reconcileRolesCommandName {
+		return
+	}
+
+	if !isStaff(event.Member()) || event.GuildID() == nil {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if err := event.DeferCreateMessage(true); err != nil {
+		slog.Error("error while deferring message", slog.Any("err", err))
+		return
+	}
+
+	client, guildID := event.Client(), *event.GuildID()
+	enforce := event.SlashCommandInteractionData().String("mode") == "enforce"
+	respond := func(content string) {
+		_, err := client.Rest().UpdateInteractionResponse(event.ApplicationID(), event.Token(), discord.NewMessageUpdateBuilder().
+			SetContent(content).
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while updating interaction response", slog.Any("err", err))
+		}
+	}
+
+	// Enforcing is paced to stay under the rate limit and can take minutes, so
+	// don't hold up the gateway while it runs
+	go func() {
+		drifts, err := computeRoleDrift(client, guildID)
+		if err != nil {
+			respond(fmt.Sprintf("Couldn't compare roles: %v.", err))
+			return
+		}
+
+		if err = postRoleDriftReport(client, guildID, drifts, enforce); err != nil {
+			respond(fmt.Sprintf("Couldn't post the role drift report: %v.", err))
+			return
+		}
+
+		if !enforce {
+			respond(fmt.Sprintf("Found role drift on %d members, the report was posted to #%v.", len(drifts), s1ChannelName))
+			return
+		}
+
+		changes, failures := enforceRoleDrift(client, guildID, drifts)
+		respond(fmt.Sprintf("Corrected role drift on %d members with %d role changes, %d changes failed.", len(drifts), changes, failures))
+	}()
+})
+
+// expectedRoleNames is every role the member's roster record says they hold.
+func expectedRoleNames(member rosterMember) []string {
+	var names []string
+	if member.Status == rosterStatusDischarged {
+		return []string{statusRoleNames[rosterStatusDischarged]}
+	}
+
+	if r, _, ok := findRank(member.Rank); ok {
+		names = append(names, r.RoleName)
+	}
+	if member.Unit != "" {
+		names = append(names, member.Unit)
+	}
+	for _, qualification := range member.Qualifications {
+		names = append(names, qualification.Name)
+	}
+	if statusRoleName, ok := statusRoleNames[member.Status]; ok {
+		names = append(names, statusRoleName)
+	}
+
+	return names
+}
+
+// managedRoleNames is every role the reconciler is allowed to add or remove.
+// Anything else, like moderator or event roles, is left alone.
+func managedRoleNames(members []rosterMember) map[string]bool {
+	managed := map[string]bool{}
+	for _, r := range ranks {
+		managed[r.RoleName] = true
+	}
+	for _, statusRoleName := range statusRoleNames {
+		managed[statusRoleName] = true
+	}
+	for _, member := range members {
+		if member.Unit != "" {
+			managed[member.Unit] = true
+		}
+		for _, qualification := range member.Qualifications {
+			managed[qualification.Name] = true
+		}
+	}
+
+	return managed
+}
+
+func computeRoleDrift(client bot.Client, guildID snowflake.ID) ([]roleDrift, error) {
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	guildMembers, err := getGuildMembers(client, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleNames := make(map[snowflake.ID]string, len(roleIDs))
+	for name, roleID := range roleIDs {
+		roleNames[roleID] = name
+	}
+
+	byID := make(map[snowflake.ID]discord.Member, len(guildMembers))
+	for _, guildMember := range guildMembers {
+		byID[guildMember.User.ID] = guildMember
+	}
+
+	managed := managedRoleNames(members)
+	var drifts []roleDrift
+	for _, member := range members {
+		guildMember, ok := byID[member.DiscordID]
+		if !ok {
+			continue
+		}
+
+		held := map[string]bool{}
+		for _, roleID := range guildMember.RoleIDs {
+			held[roleNames[roleID]] = true
+		}
+
+		expected := map[string]bool{}
+		drift := roleDrift{MemberID: member.DiscordID}
+		for _, name := range expectedRoleNames(member) {
+			expected[name] = true
+			// Roles that don't exist in the guild can't be fixed by the reconciler
+			if _, exists := roleIDs[name]; exists && !held[name] {
+				drift.Missing = append(drift.Missing, name)
+			}
+		}
+
+		for name := range held {
+			if managed[name] && !expected[name] {
+				drift.Extra = append(drift.Extra, name)
+			}
+		}
+
+		if len(drift.Missing) > 0 || len(drift.Extra) > 0 {
+			sort.Strings(drift.Missing)
+			sort.Strings(drift.Extra)
+			drifts = append(drifts, drift)
+		}
+	}
+
+	return drifts, nil
+}
+
+// enforceRoleDrift applies every drift one role change at a time, returning
+// how many changes were made and how many failed.
+func enforceRoleDrift(client bot.Client, guildID snowflake.ID, drifts []roleDrift) (int, int) {
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		slog.Error("error while getting roles", slog.Any("err", err))
+		return 0, len(drifts)
+	}
+
+	throttle := time.NewTicker(roleReconciliationDelay)
+	defer throttle.Stop()
+
+	changes, failures := 0, 0
+	apply := func(memberID snowflake.ID, name string, add bool) {
+		<-throttle.C
+
+		var err error
+		if add {
+			err = client.Rest().AddMemberRole(guildID, memberID, roleIDs[name])
+		} else {
+			err = client.Rest().RemoveMemberRole(guildID, memberID, roleIDs[name])
+		}
+
+		if err != nil {
+			slog.Error("error while reconciling role", slog.Any("err", err), slog.Any("member", memberID), slog.String("role", name))
+			failures++
+			return
+		}
+		changes++
+	}
+
+	for _, drift := range drifts {
+		for _, name := range drift.Missing {
+			apply(drift.MemberID, name, true)
+		}
+		for _, name := range drift.Extra {
+			apply(drift.MemberID, name, false)
+		}
+	}
+
+	return changes, failures
+}
+
+func postRoleDriftReport(client bot.Client, guildID snowflake.ID, drifts []roleDrift, enforce bool) error {
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return err
+	}
+
+	title := "Role Drift (dry run)"
+	if enforce {
+		title = "Role Drift (correcting)"
+	}
+
+	for i := 0; i < len(drifts); i += roleDriftReportPageSize {
+		page := drifts[i:min(i+roleDriftReportPageSize, len(drifts))]
+
+		var description strings.Builder
+		for _, drift := range page {
+			fmt.Fprintf(&description, "**<@%v>**", drift.MemberID)
+			if len(drift.Missing) > 0 {
+				fmt.Fprintf(&description, "\n+ %v", strings.Join(drift.Missing, ", "))
+			}
+			if len(drift.Extra) > 0 {
+				fmt.Fprintf(&description, "\n- %v", strings.Join(drift.Extra, ", "))
+			}
+			description.WriteString("\n")
+		}
+
+		_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+			SetEmbeds(discord.NewEmbedBuilder().
+				SetColor(0xe8b923).
+				SetTitlef("%v (%d-%d of %d)", title, i+1, i+len(page), len(drifts)).
+				SetDescription(description.String()).
+				Build(),
+			).
+			Build(),
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package perscom_events
+
+import (
+	"errors"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+type rosterStatus string
+
+const (
+	rosterStatusActive     rosterStatus = "active"
+	rosterStatusLOA        rosterStatus = "loa"
+	rosterStatusReserves   rosterStatus = "reserves"
+	rosterStatusSuspended  rosterStatus = "suspended"
+	rosterStatusDischarged rosterStatus = "discharged"
+)
+
+var rosterStatuses = []rosterStatus{rosterStatusActive, rosterStatusLOA, rosterStatusReserves, rosterStatusSuspended, rosterStatusDischarged}
+
+type rosterQualification struct {
+	Name    string    `json:"name"`
+	Awarded time.Time `json:"awarded"`
+}
+
+type rosterAward struct {
+	Name      string       `json:"name"`
+	Awarded   time.Time    `json:"awarded"`
+	Citation  string       `json:"citation,omitempty"`
+	Operation string       `json:"operation,omitempty"`
+	AwardedBy snowflake.ID `json:"awarded_by,omitempty"`
+}
+
+// rosterMember is everything we know about a member of the unit. Name is the
+// platform name, I.E. `SSG G. Hydra`.
+type rosterMember struct {
+	DiscordID snowflake.ID `json:"discord_id"`
+	Name      string       `json:"name"`
+	Rank      string       `json:"rank"`
+	RankDate  time.Time    `json:"rank_date"`
+	Unit      string       `json:"unit"`
+	PlayerID  string       `json:"player_id"`
+	// SquadXMLSection is the key of the squad.xml the member asked to be
+	// listed in, when empty it's inferred from their unit.
+	SquadXMLSection string                `json:"squad_xml_section,omitempty"`
+	JoinDate        time.Time             `json:"join_date"`
+	Status          rosterStatus          `json:"status"`
+	Qualifications  []rosterQualification `json:"qualifications"`
+	Awards          []rosterAward         `json:"awards"`
+}
+
+var errRosterMemberNotFound = errors.New("member isn't on the roster")
+
+var roster = newJSONStore("roster", func() map[snowflake.ID]rosterMember {
+	return map[snowflake.ID]rosterMember{}
+})
+
+func (s rosterStatus) String() string {
+	switch s {
+	case rosterStatusActive:
+		return "Active"
+	case rosterStatusLOA:
+		return "Leave of Absence"
+	case rosterStatusReserves:
+		return "Reserves"
+	case rosterStatusSuspended:
+		return "Suspended"
+	case rosterStatusDischarged:
+		return "Discharged"
+	default:
+		return string(s)
+	}
+}
+
+func (m rosterMember) hasQualification(name string) bool {
+	for _, qualification := range m.Qualifications {
+		if qualification.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getRosterMember(discordID snowflake.ID) (rosterMember, error) {
+	var member rosterMember
+	var ok bool
+	err := roster.View(func(members *map[snowflake.ID]rosterMember) {
+		member, ok = (*members)[discordID]
+	})
+
+	if err == nil && !ok {
+		err = errRosterMemberNotFound
+	}
+
+	return member, err
+}
+
+// updateRosterMember applies fn to the member's record, enlisting them as an
+// active member joining today if they aren't on the roster yet. Squad XML is
+// rebuilt whenever the change shows up in it, and the member's billets are
+// vacated when they leave their unit or are discharged.
+func updateRosterMember(discordID snowflake.ID, fn func(member *rosterMember) error) error {
+	var before, after rosterMember
+	err := roster.Update(func(members *map[snowflake.ID]rosterMember) error {
+		member, ok := (*members)[discordID]
+		if !ok {
+			member = rosterMember{
+				DiscordID: discordID,
+				JoinDate:  time.Now().UTC(),
+				Status:    rosterStatusActive,
+			}
+		}
+		before = member
+
+		if err := fn(&member); err != nil {
+			return err
+		}
+
+		(*members)[discordID] = member
+		after = member
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	discharged := after.Status == rosterStatusDischarged && before.Status != rosterStatusDischarged
+	if !strings.EqualFold(before.Unit, after.Unit) || discharged {
+		if err = vacateBillets(discordID); err != nil {
+			slog.Error("error while vacating billets", slog.Any("err", err), slog.Any("member", discordID))
+		}
+	}
+
+	if discharged {
+		if _, err = resolveDischarge(discordID); err != nil {
+			slog.Error("error while resolving discharge", slog.Any("err", err), slog.Any("member", discordID))
+		}
+	}
+
+	if squadXMLEntryChanged(before, after) {
+		if err = regenerateSquadXML(); err != nil {
+			slog.Error("error while regenerating squad xml", slog.Any("err", err), slog.Any("member", discordID))
+		}
+	}
+
+	return nil
+}
+
+// listRosterMembers returns every member on the roster ordered by name.
+func listRosterMembers() ([]rosterMember, error) {
+	var list []rosterMember
+	err := roster.View(func(members *map[snowflake.ID]rosterMember) {
+		list = make([]rosterMember, 0, len(*members))
+		for _, member := range *members {
+			list = append(list, member)
+		}
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, err
+}
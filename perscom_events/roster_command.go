@@ -0,0 +1,156 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"log/slog"
+	"time"
+)
+
+const rosterCommandName = "roster"
+
+var rosterCommand = SlashCommandHandler{
+	Command: discord.SlashCommandCreate{
+		Name:        rosterCommandName,
+		Description: "Manage the personnel roster (staff only)",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "update",
+				Description: "Add a member to the roster or update their record",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to update", Required: true},
+					discord.ApplicationCommandOptionString{Name: "name", Description: "Platform name, I.E. SSG G. Hydra"},
+					discord.ApplicationCommandOptionString{Name: "rank", Description: "Rank", Choices: rankChoices()},
+					discord.ApplicationCommandOptionString{Name: "unit", Description: "Unit or section"},
+					discord.ApplicationCommandOptionString{Name: "player-id", Description: "ArmA 3 player ID"},
+					discord.ApplicationCommandOptionString{Name: "status", Description: "Service status", Choices: rosterStatusChoices()},
+					discord.ApplicationCommandOptionString{Name: "join-date", Description: "Join date as YYYY-MM-DD"},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "qualify",
+				Description: "Add a qualification to a member",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to qualify", Required: true},
+					discord.ApplicationCommandOptionString{Name: "qualification", Description: "Qualification name", Required: true},
+				},
+			},
+			discord.ApplicationCommandOptionSubCommand{
+				Name:        "disqualify",
+				Description: "Remove a qualification from a member",
+				Options: []discord.ApplicationCommandOption{
+					discord.ApplicationCommandOptionUser{Name: "member", Description: "Member to disqualify", Required: true},
+					discord.ApplicationCommandOptionString{Name: "qualification", Description: "Qualification name", Required: true},
+				},
+			},
+		},
+	},
+	EventListeners: []bot.EventListener{rosterCommandEventListener},
+}
+
+var rosterCommandEventListener = bot.NewListenerFunc(func(event *events.ApplicationCommandInteractionCreate) {
+	if event.Data.CommandName() != rosterCommandName {
+		return
+	}
+
+	data := event.SlashCommandInteractionData()
+	if data.SubCommandName == nil {
+		return
+	}
+
+	content := ""
+	if !isStaff(event.Member()) {
+		content = "Only staff can do that."
+	} else {
+		user := data.User("member")
+		var err error
+		switch *data.SubCommandName {
+		case "update":
+			err = updateRosterMember(user.ID, func(member *rosterMember) error {
+				return applyRosterUpdate(member, data)
+			})
+			content = fmt.Sprintf("Updated the roster record for %v.", user.Mention())
+		case "qualify":
+			qualification := data.String("qualification")
+			err = updateRosterMember(user.ID, func(member *rosterMember) error {
+				if !member.hasQualification(qualification) {
+					member.Qualifications = append(member.Qualifications, rosterQualification{qualification, time.Now().UTC()})
+				}
+				return nil
+			})
+			content = fmt.Sprintf("Added %v to %v.", qualification, user.Mention())
+		case "disqualify":
+			qualification := data.String("qualification")
+			err = updateRosterMember(user.ID, func(member *rosterMember) error {
+				for i, existing := range member.Qualifications {
+					if existing.Name == qualification {
+						member.Qualifications = append(member.Qualifications[:i], member.Qualifications[i+1:]...)
+						break
+					}
+				}
+				return nil
+			})
+			content = fmt.Sprintf("Removed %v from %v.", qualification, user.Mention())
+		default:
+			return
+		}
+
+		if err != nil {
+			content = fmt.Sprintf("Couldn't update the roster: %v.", err)
+		}
+	}
+
+	err := event.CreateMessage(discord.NewMessageCreateBuilder().
+		SetEphemeral(true).
+		SetContent(content).
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while creating message", slog.Any("err", err))
+	}
+})
+
+func applyRosterUpdate(member *rosterMember, data discord.SlashCommandInteractionData) error {
+	if name, ok := data.OptString("name"); ok {
+		parsed, err := parsePlatformName(name)
+		if err != nil {
+			return err
+		}
+		member.Name = parsed.String()
+	}
+	if rank, ok := data.OptString("rank"); ok && rank != member.Rank {
+		member.Rank = rank
+		member.RankDate = time.Now().UTC()
+	}
+	if unit, ok := data.OptString("unit"); ok {
+		member.Unit = unit
+	}
+	if playerID, ok := data.OptString("player-id"); ok {
+		member.PlayerID = playerID
+	}
+	if status, ok := data.OptString("status"); ok {
+		member.Status = rosterStatus(status)
+	}
+	if joinDate, ok := data.OptString("join-date"); ok {
+		parsed, err := time.Parse(time.DateOnly, joinDate)
+		if err != nil {
+			return errors.New("join date must be YYYY-MM-DD")
+		}
+		member.JoinDate = parsed
+	}
+
+	return nil
+}
+
+func rosterStatusChoices() []discord.ApplicationCommandOptionChoiceString {
+	choices := make([]discord.ApplicationCommandOptionChoiceString, 0, len(rosterStatuses))
+	for _, status := range rosterStatuses {
+		choices = append(choices, discord.ApplicationCommandOptionChoiceString{Name: status.String(), Value: string(status)})
+	}
+
+	return choices
+}
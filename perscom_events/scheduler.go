@@ -0,0 +1,51 @@
+package perscom_events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var periodicTasks sync.Map
+
+// periodicTaskRuns remembers when each task last ran so restarts don't push
+// back tasks with long intervals.
+var periodicTaskRuns = newJSONStore("periodic_tasks", func() map[string]time.Time {
+	return map[string]time.Time{}
+})
+
+// startPeriodicTask runs fn every interval for as long as the bot is up, right
+// away when it's overdue from before the bot started. Tasks are keyed so a
+// guild becoming ready again after a reconnect doesn't start a second copy.
+func startPeriodicTask(key string, interval time.Duration, fn func()) {
+	if _, running := periodicTasks.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	var last time.Time
+	err := periodicTaskRuns.View(func(runs *map[string]time.Time) {
+		last = (*runs)[key]
+	})
+	if err != nil {
+		slog.Error("error while reading periodic task runs", slog.Any("err", err), slog.String("task", key))
+	}
+
+	go func() {
+		for {
+			if wait := time.Until(last.Add(interval)); wait > 0 {
+				time.Sleep(wait)
+			}
+
+			fn()
+
+			last = time.Now().UTC()
+			err := periodicTaskRuns.Update(func(runs *map[string]time.Time) error {
+				(*runs)[key] = last
+				return nil
+			})
+			if err != nil {
+				slog.Error("error while saving periodic task run", slog.Any("err", err), slog.String("task", key))
+			}
+		}
+	}()
+}
@@ -2,24 +2,27 @@ package perscom_events
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 )
 
 const schoolAndCourseRequestCustomID = "school-and-course-request"
 const selectedCourseCustomID = "selected-course"
-const selectedCourseAvailabilityModalSubmit = "selected-course-availability-modal-submit"
+const courseRequestSubmitCustomID = "course-request-submit"
 
 //go:embed school_and_course_request_description.txt
 var schoolAndCourseDescription string
 
 var schoolAndCourseRequest = ButtonEventHandler{
 	discord.NewPrimaryButton("Schools & Courses", schoolAndCourseRequestCustomID),
-	[]bot.EventListener{schoolAndCourseRequestEventListener, schoolAndCourseRequestSelectionEventListener, schoolAndCourseModalSubmitEventListener},
+	[]bot.EventListener{schoolAndCourseRequestEventListener, schoolAndCourseRequestSelectionEventListener, courseRequestSubmitEventListener},
 }
 
 var schoolAndCourseRequestEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
@@ -31,18 +34,9 @@ var schoolAndCourseRequestEventListener = bot.NewListenerFunc(func(event *events
 					SetTitle("School & Course Descriptions").
 					SetDescription(schoolAndCourseDescription).
 					SetColor(0x5765f2). // Example color
+					SetFields(courseEmbedFields()...).
 					Build()).
-				AddActionRow(discord.NewStringSelectMenu(selectedCourseCustomID, "Select a school or course",
-					discord.NewStringSelectMenuOption("Airborne", "Airborne"),
-					discord.NewStringSelectMenuOption("Air Assault", "Air assault"),
-					discord.NewStringSelectMenuOption("Advanced Infantry Training", "Advanced Infantry Training"),
-					discord.NewStringSelectMenuOption("Ranger School", "Ranger School"),
-					discord.NewStringSelectMenuOption("Combat Life Saver", "Combat Life Saver"),
-					discord.NewStringSelectMenuOption("Drill Instructor Course", "Drill Instructor Course"),
-					discord.NewStringSelectMenuOption("NCO Training & Leadership", "NCO Training & Leadership"),
-					discord.NewStringSelectMenuOption("Squad Designated Marksman (SDM)", "Squad Designated Marksman (SDM)"),
-					discord.NewStringSelectMenuOption("Explosive Ordnance Disposal (EOD)", "Explosive Ordnance Disposal (EOD)"),
-				)).
+				AddActionRow(discord.NewStringSelectMenu(selectedCourseCustomID, "Select a school or course", courseSelectOptions()...)).
 				Build(),
 		)
 
@@ -54,39 +48,120 @@ var schoolAndCourseRequestEventListener = bot.NewListenerFunc(func(event *events
 
 var schoolAndCourseRequestSelectionEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == selectedCourseCustomID {
-		err := event.Modal(discord.NewModalCreateBuilder().
-			SetTitle("Attendee Availability").
-			SetCustomID(selectedCourseAvailabilityModalSubmit + fmt.Sprintf(":%v", event.StringSelectMenuInteractionData().Values[0])).
-			AddActionRow(discord.NewShortTextInput("availability", "Availability")).
+		c, ok := findCourse(event.StringSelectMenuInteractionData().Values[0])
+		if !ok {
+			slog.Error("unknown course", slog.String("course", event.StringSelectMenuInteractionData().Values[0]))
+			return
+		}
+
+		reply := func(content string) {
+			if err := event.CreateMessage(discord.NewMessageCreateBuilder().SetEphemeral(true).SetContent(content).Build()); err != nil {
+				slog.Error("error while creating message", slog.Any("err", err))
+			}
+		}
+
+		member, err := getRosterMember(event.User().ID)
+		if errors.Is(err, errRosterMemberNotFound) {
+			reply("You aren't on the roster yet, ask S1 to add you before requesting a course.")
+			return
+		} else if err != nil {
+			slog.Error("error while reading roster", slog.Any("err", err))
+			reply(fmt.Sprintf("Couldn't read the roster: %v.", err))
+			return
+		}
+
+		now := time.Now().UTC()
+		if member.hasCurrentQualification(c.Name, now) {
+			reply(fmt.Sprintf("You already hold %v.", c.Name))
+			return
+		}
+
+		if missing := missingCourseRequirements(member, c, now); len(missing) > 0 {
+			reply(fmt.Sprintf("You can't request %v yet, you're missing:\n- %v", c.Name, strings.Join(missing, "\n- ")))
+			return
+		}
+
+		availability, err := getMemberAvailability(event.User().ID)
+		if err != nil {
+			slog.Error("error while reading availability", slog.Any("err", err))
+		}
+
+		err = event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent(availabilityContent(availability, c)).
+			SetContainerComponents(availabilityComponents(availability, c)...).
 			Build(),
 		)
 
 		if err != nil {
-			slog.Error("error while updating message", slog.Any("err", err))
+			slog.Error("error while creating message", slog.Any("err", err))
 		}
 	}
 })
 
-var schoolAndCourseModalSubmitEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
-	if strings.Contains(event.ModalSubmitInteraction.Data.CustomID, selectedCourseAvailabilityModalSubmit) {
-		course := ""
-		if split := strings.Split(event.ModalSubmitInteraction.Data.CustomID, ":"); len(split) == 2 {
-			course = split[1]
-		} else {
-			slog.Error("error while splitting custom ID")
+var courseRequestSubmitEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if strings.HasPrefix(event.Data.CustomID(), courseRequestSubmitCustomID+":") {
+		c, ok := findCourse(strings.TrimPrefix(event.Data.CustomID(), courseRequestSubmitCustomID+":"))
+		if !ok {
+			slog.Error("unknown course", slog.String("custom_id", event.Data.CustomID()))
 			return
 		}
 
-		// TODO: Grab availability from req and make forum post
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		if event.GuildID() == nil {
+			return
+		}
+
+		availability, err := getMemberAvailability(event.User().ID)
+		if err != nil {
+			slog.Error("error while reading availability", slog.Any("err", err))
+			err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+				ClearContainerComponents().
+				SetContentf("Couldn't read your availability: %v.", err).
+				Build(),
+			)
+			if err != nil {
+				slog.Error("error while updating message", slog.Any("err", err))
+			}
+			return
+		} else if !availability.complete() {
+			err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+				SetContent(availabilityContent(availability, c)).
+				SetContainerComponents(availabilityComponents(availability, c)...).
+				Build(),
+			)
+			if err != nil {
+				slog.Error("error while updating message", slog.Any("err", err))
+			}
+			return
+		}
+
+		content := ""
+		session, placed, err := submitCourseRequest(event.Client(), *event.GuildID(), courseRequestRecord{
+			Course:       c.Key,
+			MemberID:     event.User().ID,
+			Availability: availability.summary(),
+		})
+		if errors.Is(err, errCourseAlreadyRequested) {
+			content = fmt.Sprintf("You've already requested \"%v\", you'll hear from S4 once a class is scheduled.", c.Name)
+		} else if err != nil {
+			slog.Error("error while submitting course request", slog.Any("err", err))
+			content = fmt.Sprintf("Couldn't submit your request for \"%v\": %v.", c.Name, err)
+		} else if !placed {
+			content = fmt.Sprintf("Submitted your request for \"%v\", you'll be invited once a class is scheduled.", c.Name)
+		} else if slices.Contains(session.Waitlist, event.User().ID) {
+			content = fmt.Sprintf("Submitted your request for \"%v\". The next class on <t:%d:F> is full, you're number %d on its waitlist.", c.Name, session.Start.Unix(), len(session.Waitlist))
+		} else {
+			content = fmt.Sprintf("Submitted your request for \"%v\", you're in the next class on <t:%d:F>.", c.Name, session.Start.Unix())
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearEmbeds().
 			ClearContainerComponents().
-			SetContentf("Submitted your request for \"%v\".", course).
+			SetContent(content).
 			Build())
 
 		if err != nil {
 			slog.Error("error while updating message", slog.Any("err", err))
 		}
-
 	}
 })
@@ -2,55 +2,98 @@ package perscom_events
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
 	"log/slog"
+	"regexp"
+	"strings"
 )
 
 const squadXMLCustomID = "squad-xml-button"
+const squadXMLSectionCustomID = "squad-xml-section"
 const squadXMLCreateModalCustomID = "squad-xml-modal"
 const squadXMLSubmitModalCustomID = "squad-xml-modal-submit"
 
+var squadXMLBaseURL = envString("squad_xml_base_url", "https://72ndairborne.com/squadxml")
+
+// Steam64 IDs of individual accounts are 17 digits starting with 7656119
+var steam64IDPattern = regexp.MustCompile(`^7656119\d{10}$`)
+
 //go:embed squad_xml_description.txt
 var squadXMLDescription string
 
 var squadXML = ButtonEventHandler{
 	discord.NewPrimaryButton("Squad XML", squadXMLCustomID),
-	[]bot.EventListener{squadXMLEventListener, squadXMLModalRequestEventListener, squadXMLModalSubmissionEventListener},
+	[]bot.EventListener{squadXMLEventListener, squadXMLSectionEventListener, squadXMLModalRequestEventListener, squadXMLModalSubmissionEventListener},
 }
 
 var squadXMLEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == squadXMLCustomID {
-		//TODO: Submit the squad XML request in the S1 Forum
-
-		err := event.CreateMessage(
-			discord.NewMessageCreateBuilder().
-				SetEphemeral(true).
-				SetEmbeds(discord.NewEmbedBuilder().
-					SetTitle("Squad XML Request Instructions").
-					SetDescription(squadXMLDescription).
-					SetColor(0x5765f2).
-					Build(),
-				).
-				AddActionRow(discord.NewPrimaryButton("Add Name & Player ID", squadXMLCreateModalCustomID)).
+		section := squadXMLSections[0]
+		if member, err := getRosterMember(event.User().ID); err == nil {
+			section = memberSquadXMLSection(member)
+		} else if !errors.Is(err, errRosterMemberNotFound) {
+			slog.Error("error while reading roster", slog.Any("err", err))
+		}
+
+		builder := discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetEmbeds(discord.NewEmbedBuilder().
+				SetTitle("Squad XML Request Instructions").
+				SetDescription(squadXMLDescription).
+				SetColor(0x5765f2).
 				Build(),
-		)
+			)
 
-		if err != nil {
+		for _, row := range squadXMLRequestComponents(section) {
+			builder.AddActionRow(row...)
+		}
+
+		if err := event.CreateMessage(builder.Build()); err != nil {
 			slog.Error("error while creating message", slog.Any("err", err))
 		}
 	}
 })
 
+// squadXMLSectionEventListener remembers the selected section by re-rendering
+// the select with it as the default and carrying it on the modal button.
+var squadXMLSectionEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if event.Data.CustomID() == squadXMLSectionCustomID {
+		section, ok := findSquadXMLSection(event.StringSelectMenuInteractionData().Values[0])
+		if !ok {
+			slog.Error("unknown squad XML section", slog.String("section", event.StringSelectMenuInteractionData().Values[0]))
+			return
+		}
+
+		builder := discord.NewMessageUpdateBuilder().ClearContainerComponents()
+		for _, row := range squadXMLRequestComponents(section) {
+			builder.AddActionRow(row...)
+		}
+
+		if err := event.UpdateMessage(builder.Build()); err != nil {
+			slog.Error("error while updating message", slog.Any("err", err))
+		}
+	}
+})
+
 var squadXMLModalRequestEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
-	if event.Data.CustomID() == squadXMLCreateModalCustomID {
+	if strings.HasPrefix(event.Data.CustomID(), squadXMLCreateModalCustomID+":") {
+		section := strings.TrimPrefix(event.Data.CustomID(), squadXMLCreateModalCustomID+":")
+		defaults := getModalDefaults(event.User().ID)
 		err := event.Modal(
 			discord.NewModalCreateBuilder().
 				SetTitle("Squad XML Request").
-				SetCustomID(squadXMLSubmitModalCustomID).
-				AddActionRow(discord.NewShortTextInput("name", "Name")).
-				AddActionRow(discord.NewShortTextInput("player_id", "Player ID")).
+				SetCustomID(squadXMLSubmitModalCustomID + ":" + section).
+				AddActionRow(discord.NewShortTextInput("name", "Name").WithValue(defaults.Name)).
+				AddActionRow(discord.NewShortTextInput("player_id", "Player ID").
+					WithValue(defaults.PlayerID).
+					WithMinLength(17).
+					WithMaxLength(17).
+					WithPlaceholder("7656119XXXXXXXXXX")).
 				Build())
 
 		if err != nil {
@@ -60,12 +103,38 @@ var squadXMLModalRequestEventListener = bot.NewListenerFunc(func(event *events.C
 })
 
 var squadXMLModalSubmissionEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
-	if event.ModalSubmitInteraction.Data.CustomID == squadXMLSubmitModalCustomID {
-		//TODO: Make forum post for S1 to fulfil
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+	if strings.HasPrefix(event.ModalSubmitInteraction.Data.CustomID, squadXMLSubmitModalCustomID+":") {
+		content := ""
+		playerID := strings.TrimSpace(event.Data.Text("player_id"))
+		section, ok := findSquadXMLSection(strings.TrimPrefix(event.ModalSubmitInteraction.Data.CustomID, squadXMLSubmitModalCustomID+":"))
+		name, err := validateNameInput(event.User().ID, event.Data.Text("name"))
+		if err == nil && !ok {
+			err = errors.New("unknown squad section")
+		}
+		if err == nil {
+			err = validateSquadXMLPlayerID(event.User().ID, playerID)
+		}
+		if err == nil && event.GuildID() == nil {
+			err = errors.New("requests can only be made from the server")
+		}
+		if err == nil {
+			saveModalDefaults(event.User().ID, modalDefaults{Name: name, PlayerID: playerID})
+
+			if err = submitSquadXMLRequest(event.Client(), *event.GuildID(), event.User().ID, name, playerID, section.Key, "Member request"); err != nil {
+				slog.Error("error while submitting squad XML request", slog.Any("err", err))
+			}
+		}
+
+		if err != nil {
+			content = fmt.Sprintf("Couldn't submit your Squad XML request: %v.", err)
+		} else {
+			content = fmt.Sprintf("Submitted your Squad XML request for the %v squad. Paste `%v` into the Squad URL section of your ArmA3 Profile.", section.Name, squadXMLURL(section))
+		}
+
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearContainerComponents().
 			ClearEmbeds().
-			SetContent("Submitted your Squad XML request.").
+			SetContent(content).
 			Build(),
 		)
 
@@ -74,3 +143,58 @@ var squadXMLModalSubmissionEventListener = bot.NewListenerFunc(func(event *event
 		}
 	}
 })
+
+func squadXMLRequestComponents(selected squadXMLSection) [][]discord.InteractiveComponent {
+	options := make([]discord.StringSelectMenuOption, 0, len(squadXMLSections))
+	for _, section := range squadXMLSections {
+		options = append(options, discord.NewStringSelectMenuOption(section.Name, section.Key).
+			WithDescription(squadXMLURL(section)).
+			WithDefault(section.Key == selected.Key))
+	}
+
+	return [][]discord.InteractiveComponent{
+		{discord.NewStringSelectMenu(squadXMLSectionCustomID, "Select your section...", options...)},
+		{discord.NewPrimaryButton("Add Name & Player ID", squadXMLCreateModalCustomID+":"+selected.Key)},
+	}
+}
+
+func squadXMLURL(section squadXMLSection) string {
+	return fmt.Sprintf("%v/%v/squad.xml", squadXMLBaseURL, section.Key)
+}
+
+// validateSquadXMLPlayerID makes sure the player ID is a Steam64 ID that no
+// other member has claimed on the roster or in a pending request.
+func validateSquadXMLPlayerID(memberID snowflake.ID, playerID string) error {
+	if !steam64IDPattern.MatchString(playerID) {
+		return fmt.Errorf("%q isn't a Steam64 ID, it should be the 17 digit number starting with 7656119 shown in your ArmA3 profile", playerID)
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.PlayerID == playerID && member.DiscordID != memberID {
+			return errors.New("that Player ID is already claimed by another member, contact S1 if it's yours")
+		}
+	}
+
+	claimed := false
+	err = squadXMLQueue.View(func(queue *squadXMLQueueData) {
+		for _, request := range queue.Pending {
+			if request.PlayerID == playerID && request.MemberID != memberID {
+				claimed = true
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if claimed {
+		return errors.New("that Player ID is already in another member's pending request, contact S1 if it's yours")
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package perscom_events
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// squadXMLSection is one of the squad.xml files we host. Key is the path
+// segment of its URL, I.E. `airborne` for `/squadxml/airborne/squad.xml`.
+type squadXMLSection struct {
+	Key     string
+	Name    string
+	Nick    string
+	Title   string
+	Email   string
+	Web     string
+	Picture string
+}
+
+var squadXMLSections = []squadXMLSection{
+	{"airborne", "Platoon", "72nd", "72nd Airborne", "s1@72ndairborne.com", "https://72ndairborne.com", "logo.paa"},
+	{"aviation", "ACE", "72nd ACE", "72nd Airborne ACE", "s1@72ndairborne.com", "https://72ndairborne.com", "logo.paa"},
+	{"oda", "ODA", "SFOD-A 072", "SFOD-A 072", "s1@72ndairborne.com", "https://72ndairborne.com", "logo.paa"},
+}
+
+type squadXMLDocument struct {
+	XMLName xml.Name         `xml:"squad"`
+	Nick    string           `xml:"nick,attr"`
+	Name    string           `xml:"name"`
+	Email   string           `xml:"email"`
+	Web     string           `xml:"web"`
+	Picture string           `xml:"picture"`
+	Title   string           `xml:"title"`
+	Members []squadXMLMember `xml:"member"`
+}
+
+type squadXMLMember struct {
+	ID     string `xml:"id,attr"`
+	Nick   string `xml:"nick,attr"`
+	Name   string `xml:"name"`
+	Email  string `xml:"email"`
+	ICQ    string `xml:"icq"`
+	Remark string `xml:"remark"`
+}
+
+// squadXMLFile is a generated squad.xml along with what's needed to answer
+// conditional requests for it.
+type squadXMLFile struct {
+	Content      []byte
+	ETag         string
+	LastModified time.Time
+}
+
+const squadXMLHeader = `<?xml version="1.0"?>
+<!DOCTYPE squad SYSTEM "squad.dtd">
+<?xml-stylesheet href="squad.xsl?" type="text/xsl"?>
+`
+
+var (
+	squadXMLFilesMu sync.RWMutex
+	squadXMLFiles   = map[string]squadXMLFile{}
+)
+
+func findSquadXMLSection(key string) (squadXMLSection, bool) {
+	for _, section := range squadXMLSections {
+		if section.Key == key {
+			return section, true
+		}
+	}
+
+	return squadXMLSection{}, false
+}
+
+// squadXMLSectionForUnit picks the squad file for a roster unit. Anyone who
+// isn't in ACE or the ODA is part of the platoon.
+func squadXMLSectionForUnit(unit string) squadXMLSection {
+	words := strings.FieldsFunc(strings.ToLower(unit), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		switch word {
+		case "ace", "aviation":
+			return squadXMLSections[1]
+		case "oda", "sfod":
+			return squadXMLSections[2]
+		}
+	}
+
+	return squadXMLSections[0]
+}
+
+func memberSquadXMLSection(member rosterMember) squadXMLSection {
+	if section, ok := findSquadXMLSection(member.SquadXMLSection); ok {
+		return section
+	}
+
+	return squadXMLSectionForUnit(member.Unit)
+}
+
+func generateSquadXML(section squadXMLSection, members []rosterMember) ([]byte, error) {
+	document := squadXMLDocument{
+		Nick:    section.Nick,
+		Name:    section.Title,
+		Email:   section.Email,
+		Web:     section.Web,
+		Picture: section.Picture,
+		Title:   section.Title,
+	}
+
+	for _, member := range members {
+		if member.PlayerID == "" || member.Status == rosterStatusDischarged || member.Status == rosterStatusSuspended {
+			continue
+		}
+		if memberSquadXMLSection(member).Key != section.Key {
+			continue
+		}
+
+		remark := member.Rank
+		if r, _, ok := findRank(member.Rank); ok {
+			remark = r.Name
+		}
+
+		document.Members = append(document.Members, squadXMLMember{
+			ID:     member.PlayerID,
+			Nick:   member.Name,
+			Name:   "N/A",
+			Email:  "N/A",
+			ICQ:    "N/A",
+			Remark: remark,
+		})
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(squadXMLHeader)
+
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "\t")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+
+	return buffer.Bytes(), nil
+}
+
+// squadXMLEntryChanged reports whether a change to a member's record changes
+// what squad.xml says about them.
+func squadXMLEntryChanged(before rosterMember, after rosterMember) bool {
+	return before.Name != after.Name ||
+		before.PlayerID != after.PlayerID ||
+		before.Rank != after.Rank ||
+		before.Status != after.Status ||
+		memberSquadXMLSection(before).Key != memberSquadXMLSection(after).Key
+}
+
+// regenerateSquadXML rebuilds every section from the roster. A file only gets
+// a new ETag and modification time when its content actually changes, so
+// clients polling it keep getting 304s until then.
+func regenerateSquadXML() error {
+	// Held while reading the roster too so concurrent updates can't publish
+	// an older roster over a newer one
+	squadXMLFilesMu.Lock()
+	defer squadXMLFilesMu.Unlock()
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return err
+	}
+
+	for _, section := range squadXMLSections {
+		content, err := generateSquadXML(section, members)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(content)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		if existing, ok := squadXMLFiles[section.Key]; ok && existing.ETag == etag {
+			continue
+		}
+
+		squadXMLFiles[section.Key] = squadXMLFile{
+			Content:      content,
+			ETag:         etag,
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+	}
+
+	return nil
+}
+
+func getSquadXMLFile(key string) (squadXMLFile, bool) {
+	squadXMLFilesMu.RLock()
+	defer squadXMLFilesMu.RUnlock()
+
+	file, ok := squadXMLFiles[key]
+	return file, ok
+}
@@ -0,0 +1,213 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const squadXMLApproveCustomID = "squad-xml-approve"
+const squadXMLRejectCustomID = "squad-xml-reject"
+
+var errSquadXMLRequestNotPending = errors.New("the request has already been reviewed")
+
+// squadXMLRequest is a pending change to a member's Squad XML entry waiting
+// for S1 to fulfil it.
+type squadXMLRequest struct {
+	ID        int          `json:"id"`
+	MemberID  snowflake.ID `json:"member_id"`
+	Name      string       `json:"name"`
+	PlayerID  string       `json:"player_id"`
+	Section   string       `json:"section"`
+	Reason    string       `json:"reason"`
+	Submitted time.Time    `json:"submitted"`
+}
+
+type squadXMLQueueData struct {
+	NextID  int               `json:"next_id"`
+	Pending []squadXMLRequest `json:"pending"`
+}
+
+var squadXMLQueue = newJSONStore("squad_xml_queue", func() squadXMLQueueData {
+	return squadXMLQueueData{NextID: 1}
+})
+
+// queueSquadXMLUpdate adds a request to the queue, replacing any request
+// still pending for the same member so S1 only ever sees the latest one.
+func queueSquadXMLUpdate(memberID snowflake.ID, name string, playerID string, section string, reason string) (squadXMLRequest, error) {
+	var request squadXMLRequest
+	err := squadXMLQueue.Update(func(queue *squadXMLQueueData) error {
+		request = squadXMLRequest{
+			ID:        queue.NextID,
+			MemberID:  memberID,
+			Name:      name,
+			PlayerID:  playerID,
+			Section:   section,
+			Reason:    reason,
+			Submitted: time.Now().UTC(),
+		}
+		queue.NextID++
+
+		pending := queue.Pending[:0]
+		for _, existing := range queue.Pending {
+			if existing.MemberID != memberID {
+				pending = append(pending, existing)
+			}
+		}
+		queue.Pending = append(pending, request)
+
+		return nil
+	})
+
+	return request, err
+}
+
+// submitSquadXMLRequest queues the request and posts it to S1 for approval.
+func submitSquadXMLRequest(client bot.Client, guildID snowflake.ID, memberID snowflake.ID, name string, playerID string, section string, reason string) error {
+	request, err := queueSquadXMLUpdate(memberID, name, playerID, section, reason)
+	if err != nil {
+		return err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetEmbeds(squadXMLRequestEmbed(request, "Pending")).
+		AddActionRow(
+			discord.NewSuccessButton("Approve", fmt.Sprintf("%v:%d", squadXMLApproveCustomID, request.ID)),
+			discord.NewDangerButton("Reject", fmt.Sprintf("%v:%d", squadXMLRejectCustomID, request.ID)),
+		).
+		Build(),
+	)
+
+	return err
+}
+
+func squadXMLRequestEmbed(request squadXMLRequest, status string) discord.Embed {
+	return discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Squad XML Request #%d", request.ID).
+		AddField("Member", fmt.Sprintf("<@%v>", request.MemberID), true).
+		AddField("Name", orNone(request.Name), true).
+		AddField("Player ID", orNone(request.PlayerID), true).
+		AddField("Section", orNone(request.Section), true).
+		AddField("Reason", orNone(request.Reason), true).
+		AddField("Status", status, true).
+		SetTimestamp(request.Submitted).
+		Build()
+}
+
+var squadXMLReviewEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	customID := event.Data.CustomID()
+	approve := strings.HasPrefix(customID, squadXMLApproveCustomID+":")
+	if !approve && !strings.HasPrefix(customID, squadXMLRejectCustomID+":") {
+		return
+	}
+
+	if !isStaff(event.Member()) {
+		err := event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContent("Only staff can do that.").
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	requestID, err := strconv.Atoi(customID[strings.Index(customID, ":")+1:])
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	status := fmt.Sprintf("Rejected by %v", event.User().Mention())
+	request, err := reviewSquadXMLRequest(requestID, approve)
+	if err != nil {
+		err = event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContentf("Couldn't review the Squad XML request: %v.", err).
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	if approve {
+		status = fmt.Sprintf("Approved by %v", event.User().Mention())
+	}
+
+	err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		SetEmbeds(squadXMLRequestEmbed(request, status)).
+		ClearContainerComponents().
+		Build(),
+	)
+
+	if err != nil {
+		slog.Error("error while updating message", slog.Any("err", err))
+	}
+})
+
+// reviewSquadXMLRequest takes the request off the queue. Approved requests are
+// written to the member's roster record and the squad files are regenerated.
+func reviewSquadXMLRequest(requestID int, approve bool) (squadXMLRequest, error) {
+	var request squadXMLRequest
+	found := false
+	err := squadXMLQueue.View(func(queue *squadXMLQueueData) {
+		for _, pending := range queue.Pending {
+			if pending.ID == requestID {
+				request, found = pending, true
+			}
+		}
+	})
+	if err != nil {
+		return request, err
+	}
+	if !found {
+		return request, errSquadXMLRequestNotPending
+	}
+
+	if approve {
+		if _, err = getRosterMember(request.MemberID); err != nil {
+			return request, err
+		}
+
+		err = updateRosterMember(request.MemberID, func(member *rosterMember) error {
+			member.Name = request.Name
+			member.PlayerID = request.PlayerID
+			member.SquadXMLSection = request.Section
+			return nil
+		})
+		if err != nil {
+			return request, err
+		}
+	}
+
+	err = squadXMLQueue.Update(func(queue *squadXMLQueueData) error {
+		pending := queue.Pending[:0]
+		for _, existing := range queue.Pending {
+			if existing.ID != requestID {
+				pending = append(pending, existing)
+			}
+		}
+		queue.Pending = pending
+		return nil
+	})
+
+	return request, err
+}
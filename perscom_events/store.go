@@ -0,0 +1,121 @@
+package perscom_events
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var dataDirectory = envString("perscom_data_dir", "data")
+
+// jsonStore keeps a single document of type T in memory and persists it to
+// a JSON file in the data directory after every update.
+type jsonStore[T any] struct {
+	mu     sync.Mutex
+	name   string
+	init   func() T
+	data   T
+	loaded bool
+}
+
+func newJSONStore[T any](name string, init func() T) *jsonStore[T] {
+	return &jsonStore[T]{name: name, init: init}
+}
+
+func (s *jsonStore[T]) path() string {
+	return filepath.Join(dataDirectory, s.name+".json")
+}
+
+func (s *jsonStore[T]) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	s.data = s.init()
+	raw, err := os.ReadFile(s.path())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err == nil {
+		if err = json.Unmarshal(raw, &s.data); err != nil {
+			return err
+		}
+	}
+
+	s.loaded = true
+	return nil
+}
+
+// View calls fn with the current document. fn must not keep references to
+// the document after it returns.
+func (s *jsonStore[T]) View(fn func(data *T)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	fn(&s.data)
+	return nil
+}
+
+// Update calls fn with a copy of the current document and, if fn returns nil,
+// writes the copy to disk and makes it current. A failed fn or write leaves
+// the document as it was. The write goes through a temporary file so a crash
+// never leaves a half written document behind.
+func (s *jsonStore[T]) Update(fn func(data *T) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	draft, err := s.copy()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(&draft); err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(draft, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dataDirectory, 0o755); err != nil {
+		return err
+	}
+
+	tmp := s.path() + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, s.path()); err != nil {
+		return err
+	}
+
+	s.data = draft
+	return nil
+}
+
+// copy deep copies the document by round tripping it through JSON, the same
+// way it's stored.
+func (s *jsonStore[T]) copy() (T, error) {
+	var draft T
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return draft, err
+	}
+
+	err = json.Unmarshal(raw, &draft)
+	return draft, err
+}
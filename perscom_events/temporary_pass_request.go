@@ -2,6 +2,7 @@ package perscom_events
 
 import (
 	_ "embed"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
@@ -43,22 +44,22 @@ var temporaryPassRequestEventListener = bot.NewListenerFunc(func(event *events.C
 
 var temporaryPassRequestSubmitEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == temporaryPassRequestSubmitCustomID {
-		//TODO: Submit TPR
+		nextOp := nextOpWindow(time.Now().UTC())
 
-		// Ensure today's time is set to noon (12:00 PM) UTC
-		today := time.Now().UTC()
-		today = time.Date(today.Year(), today.Month(), today.Day(), 23, 0, 0, 0, time.UTC)
+		message := discord.NewMessageUpdateBuilder().
+			ClearEmbeds().
+			ClearContainerComponents().
+			SetContentf("Submitted your temporary pass request for the operation <t:%d:R>.", nextOp.Start.Unix())
 
-		offset := (6 - int(today.Weekday()) + 7) % 7 // Calculate days until next Saturday
-		nextSaturday := today.AddDate(0, 0, offset)  // Add the offset to today's date to get next Saturday
+		err := recordTemporaryPass(event.User().ID, nextOp)
+		if err != nil {
+			slog.Error("error while recording temporary pass", slog.Any("err", err))
+			message.SetContentf("Couldn't submit your temporary pass request: %v.", err)
+		} else {
+			go notifyChainOfCommand(event.Client(), event.User().ID, fmt.Sprintf("submitted a temporary pass for the operation <t:%d:F>", nextOp.Start.Unix()))
+		}
 
-		err := event.UpdateMessage(
-			discord.NewMessageUpdateBuilder().
-				ClearEmbeds().
-				ClearContainerComponents().
-				SetContentf("Submitted your temporary pass request for the operation <t:%d:R>.", nextSaturday.Unix()).
-				Build(),
-		)
+		err = event.UpdateMessage(message.Build())
 
 		if err != nil {
 			slog.Error("error while updating message", slog.Any("err", err))
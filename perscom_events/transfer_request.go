@@ -2,14 +2,17 @@ package perscom_events
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/events"
 	"log/slog"
+	"strings"
 )
 
 const transferRequestCustomID = "transfer-request"
-const transferRequestModalCustomID = "transfer-request-modal"
+const transferRequestUnitCustomID = "transfer-request-unit"
 const transferRequestModalSubmitCustomID = "transfer-request-modal-submit"
 
 //go:embed transfer_request_description.txt
@@ -17,53 +20,128 @@ var transferRequestDescription string
 
 var transferRequest = ButtonEventHandler{
 	Button:         discord.NewPrimaryButton("Transfer", transferRequestCustomID),
-	EventListeners: []bot.EventListener{transferRequestEventListener, transferRequestModalEventListener, transferRequestModalSubmitEventListener},
+	EventListeners: []bot.EventListener{transferRequestEventListener, transferRequestUnitEventListener, transferRequestModalSubmitEventListener, transferReviewEventListener},
 }
 
 var transferRequestEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
 	if event.Data.CustomID() == transferRequestCustomID {
-		err := event.CreateMessage(discord.NewMessageCreateBuilder().
-			SetEphemeral(true).
-			SetEmbeds(discord.NewEmbedBuilder().
-				SetTitle("Transfer Request").
-				SetColor(0x5765f2).
-				SetDescription(transferRequestDescription).
-				Build(),
-			).
-			AddActionRow(discord.NewPrimaryButton("Add Current & Desired", transferRequestModalCustomID)).
-			Build(),
-		)
+		builder := discord.NewMessageCreateBuilder().SetEphemeral(true)
 
-		if err != nil {
+		member, err := getRosterMember(event.User().ID)
+		if errors.Is(err, errRosterMemberNotFound) {
+			builder.SetContent("You aren't on the roster yet, ask S1 to add you before requesting a transfer.")
+		} else if err != nil {
+			slog.Error("error while reading roster", slog.Any("err", err))
+			builder.SetContentf("Couldn't read the roster: %v.", err)
+		} else if members, err := listRosterMembers(); err != nil {
+			slog.Error("error while reading roster", slog.Any("err", err))
+			builder.SetContentf("Couldn't read the roster: %v.", err)
+		} else {
+			builder.
+				SetEmbeds(discord.NewEmbedBuilder().
+					SetTitle("Transfer Request").
+					SetColor(0x5765f2).
+					SetDescription(transferRequestDescription).
+					AddField("Current Unit", orNone(member.Unit), true).
+					Build(),
+				).
+				AddActionRow(discord.NewStringSelectMenu(transferRequestUnitCustomID, "Desired unit", transferUnitOptions(member, members)...))
+		}
+
+		if err = event.CreateMessage(builder.Build()); err != nil {
 			slog.Error("error while creating message", slog.Any("err", err))
 		}
 	}
 })
 
-var transferRequestModalEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
-	if event.Data.CustomID() == transferRequestModalCustomID {
-		err := event.Modal(discord.NewModalCreateBuilder().
-			SetTitle("Unit Transfer Request").
-			SetCustomID(transferRequestModalSubmitCustomID).
-			AddActionRow(discord.NewShortTextInput("from", "Current")).
-			AddActionRow(discord.NewShortTextInput("to", "Desired")).
-			Build(),
-		)
+// transferUnitOptions lists every unit but the member's own, along with how
+// full it is and what it takes to join.
+func transferUnitOptions(member rosterMember, members []rosterMember) []discord.StringSelectMenuOption {
+	options := make([]discord.StringSelectMenuOption, 0, len(orbatUnits))
+	for _, unit := range orbatUnits {
+		if strings.EqualFold(member.Unit, unit.Name) {
+			continue
+		}
+
+		var details []string
+		if unit.Capacity > 0 {
+			details = append(details, fmt.Sprintf("%d/%d members", unitStrength(unit.Name, members), unit.Capacity))
+		}
+		if len(unit.Qualifications) > 0 {
+			details = append(details, "requires "+strings.Join(unit.Qualifications, ", "))
+		}
+
+		option := discord.NewStringSelectMenuOption(unit.Name, unit.Key)
+		if len(details) > 0 {
+			option = option.WithDescription(strings.Join(details, ", "))
+		}
+		options = append(options, option)
+	}
+
+	return options
+}
+
+var transferRequestUnitEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	if event.Data.CustomID() == transferRequestUnitCustomID {
+		unit, ok := findOrbatUnit(event.StringSelectMenuInteractionData().Values[0])
+		if !ok {
+			slog.Error("unknown unit", slog.String("unit", event.StringSelectMenuInteractionData().Values[0]))
+			return
+		}
+
+		// Catch what we can before asking for a reason, submitting checks again
+		member, err := getRosterMember(event.User().ID)
+		var members []rosterMember
+		if err == nil {
+			members, err = listRosterMembers()
+		}
+		if err == nil {
+			err = checkUnitEligibility(member, unit, members)
+		}
 
 		if err != nil {
-			slog.Error("error while creating modal", slog.Any("err", err))
+			err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
+				ClearEmbeds().
+				ClearContainerComponents().
+				SetContentf("Couldn't request a transfer to %v: %v.", unit.Name, err).
+				Build(),
+			)
+		} else {
+			err = event.Modal(discord.NewModalCreateBuilder().
+				SetTitle(fmt.Sprintf("Transfer to %v", unit.Name)).
+				SetCustomID(transferRequestModalSubmitCustomID + ":" + unit.Key).
+				AddActionRow(discord.NewParagraphTextInput("reason", "Reason").WithRequired(false)).
+				Build(),
+			)
+		}
+
+		if err != nil {
+			slog.Error("error while responding to unit selection", slog.Any("err", err))
 		}
 	}
 })
 
 var transferRequestModalSubmitEventListener = bot.NewListenerFunc(func(event *events.ModalSubmitInteractionCreate) {
-	if event.ModalSubmitInteraction.Data.CustomID == transferRequestModalSubmitCustomID {
-		// TODO: Create channel and pass data
+	if strings.HasPrefix(event.ModalSubmitInteraction.Data.CustomID, transferRequestModalSubmitCustomID+":") {
+		if event.GuildID() == nil {
+			return
+		}
+
+		unitKey := strings.TrimPrefix(event.ModalSubmitInteraction.Data.CustomID, transferRequestModalSubmitCustomID+":")
+		content := ""
+		transfer, err := submitTransferRequest(event.Client(), *event.GuildID(), event.User().ID, unitKey, strings.TrimSpace(event.Data.Text("reason")))
+		if err != nil {
+			content = fmt.Sprintf("Couldn't submit your transfer request: %v.", err)
+		} else if transfer.From == "" {
+			content = fmt.Sprintf("Submitted your transfer request to %v, it needs approval from its leader.", transfer.To)
+		} else {
+			content = fmt.Sprintf("Submitted your transfer request from %v to %v, it needs approval from the leaders of both units.", transfer.From, transfer.To)
+		}
 
-		err := event.UpdateMessage(discord.NewMessageUpdateBuilder().
+		err = event.UpdateMessage(discord.NewMessageUpdateBuilder().
 			ClearContainerComponents().
 			ClearEmbeds().
-			SetContent("Submitted your transfer request.").
+			SetContent(content).
 			Build(),
 		)
 
@@ -0,0 +1,466 @@
+package perscom_events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const transferApproveCustomID = "transfer-approve"
+const transferDenyCustomID = "transfer-deny"
+
+type transferSide string
+
+const (
+	transferLosing  transferSide = "losing"
+	transferGaining transferSide = "gaining"
+)
+
+var errTransferNotPending = errors.New("the transfer has already been reviewed")
+
+// transferRecord is a transfer waiting on the leaders of the unit the member
+// is leaving and the unit they're joining. The leaders are picked when the
+// request is made, a vacant leader is left as zero and staff approve in their
+// place. Members without a unit have nothing to leave, so only the gaining
+// side has to approve.
+type transferRecord struct {
+	ID                int          `json:"id"`
+	MemberID          snowflake.ID `json:"member_id"`
+	From              string       `json:"from"`
+	To                string       `json:"to"`
+	Reason            string       `json:"reason"`
+	Submitted         time.Time    `json:"submitted"`
+	LosingLeader      snowflake.ID `json:"losing_leader,omitempty"`
+	GainingLeader     snowflake.ID `json:"gaining_leader,omitempty"`
+	LosingApprovedBy  snowflake.ID `json:"losing_approved_by,omitempty"`
+	GainingApprovedBy snowflake.ID `json:"gaining_approved_by,omitempty"`
+}
+
+type transferQueueData struct {
+	NextID  int              `json:"next_id"`
+	Pending []transferRecord `json:"pending"`
+}
+
+var transferQueue = newJSONStore("transfers", func() transferQueueData {
+	return transferQueueData{NextID: 1}
+})
+
+var transferReviewEventListener = bot.NewListenerFunc(func(event *events.ComponentInteractionCreate) {
+	action, args, _ := strings.Cut(event.Data.CustomID(), ":")
+	if action != transferApproveCustomID && action != transferDenyCustomID {
+		return
+	}
+
+	rawID, side, _ := strings.Cut(args, ":")
+	transferID, err := strconv.Atoi(rawID)
+	if err != nil {
+		slog.Error("error while parsing custom ID", slog.Any("err", err))
+		return
+	}
+
+	if event.GuildID() == nil {
+		return
+	}
+
+	var transfer transferRecord
+	var status string
+	if action == transferApproveCustomID {
+		transfer, status, err = approveTransfer(event.Client(), *event.GuildID(), transferID, transferSide(side), event.Member())
+	} else {
+		transfer, err = denyTransfer(transferID, event.Member())
+		status = fmt.Sprintf("Denied by %v", event.User().Mention())
+	}
+
+	if err != nil {
+		err = event.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEphemeral(true).
+			SetContentf("Couldn't review the transfer: %v.", err).
+			Build(),
+		)
+
+		if err != nil {
+			slog.Error("error while creating message", slog.Any("err", err))
+		}
+		return
+	}
+
+	message := discord.NewMessageUpdateBuilder().SetEmbeds(transferEmbed(transfer, status))
+	if transfer.completed() || action == transferDenyCustomID {
+		message.ClearContainerComponents()
+	}
+
+	if err = event.UpdateMessage(message.Build()); err != nil {
+		slog.Error("error while updating message", slog.Any("err", err))
+	}
+})
+
+func (t transferRecord) leader(side transferSide) snowflake.ID {
+	if side == transferLosing {
+		return t.LosingLeader
+	}
+
+	return t.GainingLeader
+}
+
+func (t transferRecord) unit(side transferSide) string {
+	if side == transferLosing {
+		return t.From
+	}
+
+	return t.To
+}
+
+func (t transferRecord) approvedBy(side transferSide) snowflake.ID {
+	if side == transferLosing {
+		return t.LosingApprovedBy
+	}
+
+	return t.GainingApprovedBy
+}
+
+func (t transferRecord) completed() bool {
+	return (t.From == "" || t.LosingApprovedBy != 0) && t.GainingApprovedBy != 0
+}
+
+// canReview reports whether the member may act for a side of the transfer,
+// being its leader or, when there's no leader, staff.
+func (t transferRecord) canReview(side transferSide, member *discord.ResolvedMember) bool {
+	if member == nil {
+		return false
+	}
+
+	if leader := t.leader(side); leader != 0 {
+		return member.User.ID == leader
+	}
+
+	return isStaff(member)
+}
+
+// submitTransferRequest checks the member can join the unit and posts the
+// request to S1 for both leaders to approve.
+func submitTransferRequest(client bot.Client, guildID snowflake.ID, memberID snowflake.ID, unitKey string, reason string) (transferRecord, error) {
+	unit, ok := findOrbatUnit(unitKey)
+	if !ok {
+		return transferRecord{}, fmt.Errorf("unknown unit %q", unitKey)
+	}
+
+	member, err := getRosterMember(memberID)
+	if err != nil {
+		return transferRecord{}, err
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return transferRecord{}, err
+	}
+
+	if err = checkUnitEligibility(member, unit, members); err != nil {
+		return transferRecord{}, err
+	}
+
+	transfer := transferRecord{
+		MemberID:  memberID,
+		From:      member.Unit,
+		To:        unit.Name,
+		Reason:    reason,
+		Submitted: time.Now().UTC(),
+	}
+	if transfer.From != "" {
+		if transfer.LosingLeader, err = unitLeader(transfer.From, memberID); err != nil {
+			return transfer, err
+		}
+	}
+	if transfer.GainingLeader, err = unitLeader(transfer.To, memberID); err != nil {
+		return transfer, err
+	}
+
+	err = transferQueue.Update(func(queue *transferQueueData) error {
+		for _, pending := range queue.Pending {
+			if pending.MemberID == memberID {
+				return fmt.Errorf("you already have a transfer to %v pending", pending.To)
+			}
+		}
+
+		transfer.ID = queue.NextID
+		queue.NextID++
+		queue.Pending = append(queue.Pending, transfer)
+		return nil
+	})
+	if err != nil {
+		return transfer, err
+	}
+
+	// Nobody would ever see the request, let the member try again
+	discard := func(err error) (transferRecord, error) {
+		if removeErr := removePendingTransfer(transfer.ID); removeErr != nil {
+			slog.Error("error while discarding transfer", slog.Any("err", removeErr))
+		}
+		return transfer, err
+	}
+
+	channelID, err := findGuildChannel(client, guildID, s1ChannelName)
+	if err != nil {
+		return discard(err)
+	}
+
+	var mentions []string
+	buttons := make([]discord.InteractiveComponent, 0, 3)
+	if transfer.From != "" {
+		mentions = append(mentions, leaderMention(transfer.LosingLeader, transfer.From))
+		buttons = append(buttons, discord.NewSuccessButton(buttonLabel("Approve for "+transfer.From), fmt.Sprintf("%v:%d:%v", transferApproveCustomID, transfer.ID, transferLosing)))
+	}
+	mentions = append(mentions, leaderMention(transfer.GainingLeader, transfer.To))
+	buttons = append(buttons,
+		discord.NewSuccessButton(buttonLabel("Approve for "+transfer.To), fmt.Sprintf("%v:%d:%v", transferApproveCustomID, transfer.ID, transferGaining)),
+		discord.NewDangerButton("Deny", fmt.Sprintf("%v:%d", transferDenyCustomID, transfer.ID)),
+	)
+
+	_, err = client.Rest().CreateMessage(channelID, discord.NewMessageCreateBuilder().
+		SetContentf("%v, a transfer needs your approval.", strings.Join(mentions, " and ")).
+		SetEmbeds(transferEmbed(transfer, "Pending")).
+		AddActionRow(buttons...).
+		Build(),
+	)
+	if err != nil {
+		return discard(err)
+	}
+
+	return transfer, nil
+}
+
+func leaderMention(leader snowflake.ID, unitName string) string {
+	if leader == 0 {
+		return fmt.Sprintf("staff (%v has no leader)", unitName)
+	}
+
+	return fmt.Sprintf("<@%v>", leader)
+}
+
+func transferEmbed(transfer transferRecord, status string) discord.Embed {
+	approval := func(approvedBy snowflake.ID) string {
+		if approvedBy == 0 {
+			return "Pending"
+		}
+		return fmt.Sprintf("<@%v>", approvedBy)
+	}
+
+	builder := discord.NewEmbedBuilder().
+		SetColor(0x5765f2).
+		SetTitlef("Transfer Request #%d", transfer.ID).
+		AddField("Member", fmt.Sprintf("<@%v>", transfer.MemberID), true).
+		AddField("From", orNone(transfer.From), true).
+		AddField("To", transfer.To, true).
+		AddField("Reason", orNone(transfer.Reason), false)
+
+	if transfer.From != "" {
+		builder.AddField("Losing Approval", approval(transfer.LosingApprovedBy), true)
+	}
+
+	return builder.
+		AddField("Gaining Approval", approval(transfer.GainingApprovedBy), true).
+		AddField("Status", status, true).
+		SetTimestamp(transfer.Submitted).
+		Build()
+}
+
+func findPendingTransfer(transferID int) (transferRecord, error) {
+	var transfer transferRecord
+	found := false
+	err := transferQueue.View(func(queue *transferQueueData) {
+		for _, pending := range queue.Pending {
+			if pending.ID == transferID {
+				transfer, found = pending, true
+			}
+		}
+	})
+
+	if err == nil && !found {
+		err = errTransferNotPending
+	}
+
+	return transfer, err
+}
+
+func removePendingTransfer(transferID int) error {
+	return transferQueue.Update(func(queue *transferQueueData) error {
+		pending := queue.Pending[:0]
+		for _, existing := range queue.Pending {
+			if existing.ID != transferID {
+				pending = append(pending, existing)
+			}
+		}
+		queue.Pending = pending
+		return nil
+	})
+}
+
+// approveTransfer records one side's approval and, once both sides approved,
+// moves the member. The transfer is taken off the queue in the same update
+// that completes it, so two leaders approving at once can't both move the
+// member. The returned status describes where the transfer stands.
+func approveTransfer(client bot.Client, guildID snowflake.ID, transferID int, side transferSide, reviewer *discord.ResolvedMember) (transferRecord, string, error) {
+	if side != transferLosing && side != transferGaining {
+		return transferRecord{}, "", fmt.Errorf("unknown side %q", side)
+	}
+
+	var transfer transferRecord
+	var previous snowflake.ID
+	claimed := false
+	err := transferQueue.Update(func(queue *transferQueueData) error {
+		i := slices.IndexFunc(queue.Pending, func(pending transferRecord) bool {
+			return pending.ID == transferID
+		})
+		if i < 0 {
+			return errTransferNotPending
+		}
+
+		transfer = queue.Pending[i]
+		if !transfer.canReview(side, reviewer) {
+			return fmt.Errorf("only %v can approve for that unit", leaderMention(transfer.leader(side), transfer.unit(side)))
+		}
+
+		previous = transfer.approvedBy(side)
+		transfer.setApproval(side, reviewer.User.ID)
+		queue.Pending[i] = transfer
+		if transfer.completed() {
+			queue.Pending = slices.Delete(queue.Pending, i, i+1)
+			claimed = true
+		}
+		return nil
+	})
+	if err != nil {
+		return transfer, "", err
+	}
+
+	if !claimed {
+		return transfer, "Waiting on the other unit", nil
+	}
+
+	warnings, err := completeTransfer(client, guildID, transfer)
+	if err != nil {
+		// Nothing was applied, put it back without this approval so it can be
+		// given again once it's sorted out
+		restored := transfer
+		restored.setApproval(side, previous)
+		restoreErr := transferQueue.Update(func(queue *transferQueueData) error {
+			i := slices.IndexFunc(queue.Pending, func(pending transferRecord) bool {
+				return pending.ID > restored.ID
+			})
+			if i < 0 {
+				i = len(queue.Pending)
+			}
+			queue.Pending = slices.Insert(queue.Pending, i, restored)
+			return nil
+		})
+		if restoreErr != nil {
+			slog.Error("error while restoring transfer", slog.Any("err", restoreErr), slog.Int("transfer", transferID))
+		}
+		return transfer, "", err
+	}
+
+	status := "Transferred"
+	if len(warnings) > 0 {
+		status += ", however:\n- " + strings.Join(warnings, "\n- ")
+	}
+
+	return transfer, status, nil
+}
+
+// setApproval records who approved the transfer for one side, zero taking the
+// approval back.
+func (t *transferRecord) setApproval(side transferSide, reviewerID snowflake.ID) {
+	if side == transferLosing {
+		t.LosingApprovedBy = reviewerID
+	} else {
+		t.GainingApprovedBy = reviewerID
+	}
+}
+
+func denyTransfer(transferID int, reviewer *discord.ResolvedMember) (transferRecord, error) {
+	transfer, err := findPendingTransfer(transferID)
+	if err != nil {
+		return transfer, err
+	}
+
+	if !transfer.canReview(transferLosing, reviewer) && !transfer.canReview(transferGaining, reviewer) && !isStaff(reviewer) {
+		return transfer, errors.New("only the leaders of either unit or staff can deny it")
+	}
+
+	return transfer, removePendingTransfer(transferID)
+}
+
+// completeTransfer moves the member of a transfer already taken off the queue
+// to the new unit on the roster, which also vacates their billets and rebuilds
+// the squad files, then swaps their unit role. An error means nothing was
+// applied. Like promotions, anything that goes wrong after the roster was
+// updated is returned as a warning.
+func completeTransfer(client bot.Client, guildID snowflake.ID, transfer transferRecord) ([]string, error) {
+	unit, ok := findOrbatUnitByName(transfer.To)
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q", transfer.To)
+	}
+
+	members, err := listRosterMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	// The unit may have filled up while the leaders were deciding
+	err = updateRosterMember(transfer.MemberID, func(member *rosterMember) error {
+		if err := checkUnitEligibility(*member, unit, members); err != nil {
+			return err
+		}
+
+		member.Unit = unit.Name
+		member.SquadXMLSection = squadXMLSectionForUnit(unit.Name).Key
+		switch {
+		case unit.Name == statusRoleNames[rosterStatusReserves]:
+			member.Status = rosterStatusReserves
+		case member.Status == rosterStatusReserves:
+			member.Status = rosterStatusActive
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	warn := func(message string, err error) {
+		slog.Error(message, slog.Any("err", err), slog.Any("member", transfer.MemberID))
+		warnings = append(warnings, fmt.Sprintf("%v: %v", message, err))
+	}
+
+	if err = swapUnitRoles(client, guildID, transfer.MemberID, transfer.From, transfer.To); err != nil {
+		warn("error while updating unit roles", err)
+	}
+	return warnings, nil
+}
+
+func swapUnitRoles(client bot.Client, guildID snowflake.ID, memberID snowflake.ID, from string, to string) error {
+	roleIDs, err := getGuildRoleIDs(client, guildID)
+	if err != nil {
+		return err
+	}
+
+	if roleID, ok := roleIDs[from]; ok && from != "" {
+		if err = client.Rest().RemoveMemberRole(guildID, memberID, roleID); err != nil {
+			return err
+		}
+	}
+
+	roleID, ok := roleIDs[to]
+	if !ok {
+		return fmt.Errorf("role %q not found", to)
+	}
+
+	return client.Rest().AddMemberRole(guildID, memberID, roleID)
+}
@@ -0,0 +1,179 @@
+package perscom_events
+
+import (
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Voice channels are the fallback for when the game server can't be polled.
+// Members in any of the op voice channels during the op window are credited
+// the time they spent there.
+var (
+	opVoiceChannelNames    = envList("op_voice_channels")
+	voiceAttendanceMinimum = time.Duration(envInt("voice_attendance_minutes", 90)) * time.Minute
+)
+
+// voiceSessions holds when each member in an op voice channel joined it, or
+// when their time there was last credited.
+var voiceSessions = struct {
+	sync.Mutex
+	joined map[snowflake.ID]time.Time
+}{joined: map[snowflake.ID]time.Time{}}
+
+var voiceAttendanceGuildReadyListener = bot.NewListenerFunc(func(event *events.GuildReady) {
+	if len(opVoiceChannelNames) == 0 {
+		return
+	}
+
+	// Anyone already in an op channel when the bot connects is only credited
+	// from now on
+	now := time.Now().UTC()
+	event.Client().Caches().VoiceStatesForEach(event.GuildID, func(state discord.VoiceState) {
+		if isOpVoiceChannel(event.Client(), state.ChannelID) {
+			startVoiceSession(state.UserID, now)
+		}
+	})
+})
+
+var voiceAttendanceEventListener = bot.NewListenerFunc(func(event *events.GuildVoiceStateUpdate) {
+	if len(opVoiceChannelNames) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	wasIn := isOpVoiceChannel(event.Client(), event.OldVoiceState.ChannelID)
+	isIn := isOpVoiceChannel(event.Client(), event.VoiceState.ChannelID)
+
+	switch {
+	case !wasIn && isIn:
+		startVoiceSession(event.VoiceState.UserID, now)
+	case wasIn && !isIn:
+		if err := endVoiceSession(event.VoiceState.UserID, now); err != nil {
+			slog.Error("error while recording voice attendance", slog.Any("err", err))
+		}
+	}
+})
+
+func isOpVoiceChannel(client bot.Client, channelID *snowflake.ID) bool {
+	if channelID == nil {
+		return false
+	}
+
+	channel, ok := client.Caches().Channel(*channelID)
+	if !ok {
+		return false
+	}
+
+	for _, name := range opVoiceChannelNames {
+		if strings.EqualFold(channel.Name(), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func startVoiceSession(memberID snowflake.ID, now time.Time) {
+	voiceSessions.Lock()
+	defer voiceSessions.Unlock()
+
+	if _, ok := voiceSessions.joined[memberID]; !ok {
+		voiceSessions.joined[memberID] = now
+	}
+}
+
+func endVoiceSession(memberID snowflake.ID, now time.Time) error {
+	voiceSessions.Lock()
+	joined, ok := voiceSessions.joined[memberID]
+	delete(voiceSessions.joined, memberID)
+	voiceSessions.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return creditVoiceTime(map[snowflake.ID]time.Time{memberID: joined}, now)
+}
+
+// flushVoiceSessions credits the time of everyone still in an op channel up to
+// now, so nothing is lost when the op ends or the bot restarts mid-op.
+func flushVoiceSessions(now time.Time) error {
+	voiceSessions.Lock()
+	sessions := make(map[snowflake.ID]time.Time, len(voiceSessions.joined))
+	for memberID, joined := range voiceSessions.joined {
+		sessions[memberID] = joined
+		voiceSessions.joined[memberID] = now
+	}
+	voiceSessions.Unlock()
+
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	return creditVoiceTime(sessions, now)
+}
+
+// voiceSessionsOverlap reports whether anyone still in an op channel joined it
+// before the op ended, and so has time left to be credited for it.
+func voiceSessionsOverlap(window opWindow) bool {
+	voiceSessions.Lock()
+	defer voiceSessions.Unlock()
+
+	for _, joined := range voiceSessions.joined {
+		if joined.Before(window.End) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markVoiceTracked records that the bot was watching the op voice channels
+// while the op ran, so members who never joined them count as absent.
+func markVoiceTracked(window opWindow) error {
+	return attendanceStore.Update(func(ops *map[string]opAttendance) error {
+		op := getOpAttendance(*ops, window)
+		if op.VoiceTracked {
+			return nil
+		}
+
+		op.VoiceTracked = true
+		(*ops)[window.ID] = op
+		return nil
+	})
+}
+
+// creditVoiceTime adds the part of each session that overlaps an op window to
+// the member's voice time for that op.
+func creditVoiceTime(sessions map[snowflake.ID]time.Time, now time.Time) error {
+	return attendanceStore.Update(func(ops *map[string]opAttendance) error {
+		for memberID, joined := range sessions {
+			for window := nextOpWindow(joined); window.Start.Before(now); window = nextOpWindow(window.End) {
+				start, end := window.Start, window.End
+				if joined.After(start) {
+					start = joined
+				}
+				if now.Before(end) {
+					end = now
+				}
+
+				overlap := end.Sub(start)
+				if overlap <= 0 {
+					continue
+				}
+
+				op := getOpAttendance(*ops, window)
+				op.Voice[memberID] += int(overlap.Seconds())
+				(*ops)[window.ID] = op
+			}
+		}
+
+		return nil
+	})
+}